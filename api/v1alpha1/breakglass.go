@@ -0,0 +1,61 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BreakGlassAnnotation on a PolicyController suspends image policy
+// enforcement cluster-wide until it expires. Its value is a BreakGlass
+// encoded as JSON. The admission-webhook-controller only admits it when
+// requestedBy names the user that sets it.
+const BreakGlassAnnotation = "rhtas.charts.redhat.com/break-glass"
+
+// MaxBreakGlassDuration bounds how far in the future a break-glass may
+// expire.
+const MaxBreakGlassDuration = 24 * time.Hour
+
+// BreakGlassMode selects how enforcement is suspended.
+type BreakGlassMode string
+
+const (
+	// BreakGlassIgnore sets the failurePolicy of the policy webhooks to
+	// Ignore, so that admission no longer depends on the webhook being
+	// reachable. Images the webhook can verify are still enforced, so this
+	// covers webhook outages only, not trust material that makes the webhook
+	// deny images.
+	BreakGlassIgnore BreakGlassMode = "Ignore"
+	// BreakGlassWarn additionally switches every ClusterImagePolicy to warn
+	// mode, so that images failing verification are admitted with a warning.
+	BreakGlassWarn BreakGlassMode = "Warn"
+)
+
+// BreakGlass records who suspended enforcement, why and until when.
+type BreakGlass struct {
+	Mode        BreakGlassMode `json:"mode"`
+	Reason      string         `json:"reason"`
+	RequestedBy string         `json:"requestedBy"`
+	ExpiresAt   metav1.Time    `json:"expiresAt"`
+}
+
+// ParseBreakGlass decodes the BreakGlassAnnotation of a PolicyController. It
+// returns nil if the annotation is not set.
+func ParseBreakGlass(pc *PolicyController) (*BreakGlass, error) {
+	value, ok := pc.Annotations[BreakGlassAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	bg := &BreakGlass{}
+	if err := json.Unmarshal([]byte(value), bg); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", BreakGlassAnnotation, err)
+	}
+	return bg, nil
+}
+
+// Active reports whether the break-glass has not expired at now.
+func (bg *BreakGlass) Active(now time.Time) bool {
+	return bg != nil && now.Before(bg.ExpiresAt.Time)
+}
@@ -0,0 +1,79 @@
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MaxImagePolicyExceptionDuration bounds how far in the future an
+// ImagePolicyException may expire.
+const MaxImagePolicyExceptionDuration = 72 * time.Hour
+
+// ImagePolicyExceptionApproveVerb is the verb on imagepolicyexceptions that
+// users need to approve an exception.
+const ImagePolicyExceptionApproveVerb = "approve"
+
+// ImagePolicyExceptionSpec records who asked for an exemption of the
+// namespace from image policy enforcement, who approved it and until when it
+// holds. The requester creates the exception and another user approves it by
+// setting the approver; nothing else can be changed. Delete the exception to
+// end it early and create a new one to extend it.
+// +kubebuilder:validation:XValidation:rule="!has(self.approver) || self.approver != self.requester",message="approver must differ from requester"
+// +kubebuilder:validation:XValidation:rule="self.reason == oldSelf.reason && self.requester == oldSelf.requester && self.expiresAt == oldSelf.expiresAt",message="only the approver can be set once created"
+// +kubebuilder:validation:XValidation:rule="!has(oldSelf.approver) || (has(self.approver) && self.approver == oldSelf.approver)",message="approver cannot be changed once set"
+type ImagePolicyExceptionSpec struct {
+	// Reason explains why the namespace is exempted, for example the
+	// incident it is needed for.
+	// +kubebuilder:validation:MinLength=1
+	Reason string `json:"reason"`
+	// Requester is the user who asked for the exception.
+	// +kubebuilder:validation:MinLength=1
+	Requester string `json:"requester"`
+	// Approver is the user who approved the exception. The exception has no
+	// effect until it is set.
+	// +kubebuilder:validation:MinLength=1
+	// +optional
+	Approver string `json:"approver,omitempty"`
+	// ExpiresAt is when enforcement is restored.
+	ExpiresAt metav1.Time `json:"expiresAt"`
+}
+
+// ImagePolicyExceptionStatus reports whether the exception is in effect.
+type ImagePolicyExceptionStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ConditionExempted is True while the namespace of an ImagePolicyException
+// is exempted from image policy enforcement.
+const ConditionExempted = "Exempted"
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Requester",type=string,JSONPath=`.spec.requester`
+// +kubebuilder:printcolumn:name="Approver",type=string,JSONPath=`.spec.approver`
+// +kubebuilder:printcolumn:name="Expires",type=date,JSONPath=`.spec.expiresAt`
+// +kubebuilder:printcolumn:name="Exempted",type=string,JSONPath=`.status.conditions[?(@.type=="Exempted")].status`
+
+// ImagePolicyException temporarily exempts its namespace from image policy
+// enforcement.
+type ImagePolicyException struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ImagePolicyExceptionSpec   `json:"spec"`
+	Status ImagePolicyExceptionStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ImagePolicyExceptionList contains a list of ImagePolicyException
+type ImagePolicyExceptionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ImagePolicyException `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ImagePolicyException{}, &ImagePolicyExceptionList{})
+}
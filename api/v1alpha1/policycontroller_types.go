@@ -0,0 +1,306 @@
+package v1alpha1
+
+import (
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// PolicyControllerSpec defines the desired state of PolicyController.
+// The helm-operator passes spec.policy-controller to the policy-controller
+// subchart as its values, so the field names below mirror the chart's
+// values.yaml. Only the values that RHTAS configures or validates are
+// modelled; anything else is still accepted by the CRD and rendered by the
+// chart, but is not visible through these types.
+type PolicyControllerSpec struct {
+	PolicyController PolicyControllerValues `json:"policy-controller,omitempty"`
+
+	// WebhookDrift is read by the operator and ignored by the chart.
+	// +optional
+	WebhookDrift WebhookDriftSpec `json:"webhookDrift,omitempty"`
+
+	// CircuitBreaker is read by the operator and ignored by the chart. The
+	// circuit breaker is enabled when it is set.
+	// +optional
+	CircuitBreaker *CircuitBreakerSpec `json:"circuitBreaker,omitempty"`
+
+	// UpgradeMaintenance is read by the operator and ignored by the chart.
+	// Upgrade maintenance is enabled when it is set.
+	// +optional
+	UpgradeMaintenance *UpgradeMaintenanceSpec `json:"upgradeMaintenance,omitempty"`
+
+	// Profile is read by the operator and ignored by the chart. It selects
+	// the defaults and requirements applied to the helm values.
+	// +optional
+	Profile DeploymentProfile `json:"profile,omitempty"`
+}
+
+// DeploymentProfile selects the defaults and requirements for the webhook
+// Deployment.
+// +kubebuilder:validation:Enum=dev;production
+type DeploymentProfile string
+
+const (
+	// ProfileDev keeps the lightweight chart defaults: a single replica and
+	// no PodDisruptionBudget. This is the default.
+	ProfileDev DeploymentProfile = "dev"
+	// ProfileProduction defaults and requires a highly available webhook:
+	// at least two replicas spread across nodes, a PodDisruptionBudget and
+	// resource requests.
+	ProfileProduction DeploymentProfile = "production"
+)
+
+// WebhookDriftMode selects how the operator reacts when the live policy
+// webhook configurations no longer match the PolicyController spec.
+// +kubebuilder:validation:Enum=Restore;Report
+type WebhookDriftMode string
+
+const (
+	// WebhookDriftRestore reverts the webhook configurations to the declared
+	// state. This is the default.
+	WebhookDriftRestore WebhookDriftMode = "Restore"
+	// WebhookDriftReport only reports the drift in status and Events.
+	WebhookDriftReport WebhookDriftMode = "Report"
+)
+
+// WebhookDriftSpec configures the webhook configuration drift reconciler.
+type WebhookDriftSpec struct {
+	// Mode defaults to Restore when unset.
+	// +optional
+	Mode WebhookDriftMode `json:"mode,omitempty"`
+}
+
+// CircuitBreakerSpec configures the policy webhooks to fail open while the
+// webhook Deployment is unavailable, so that a crash-looping webhook does not
+// block every pod in the enforced namespaces, including its own replacement.
+type CircuitBreakerSpec struct {
+	// GracePeriod is how long the webhook Deployment may be unavailable
+	// before the failurePolicy is switched to Ignore. Defaults to 5m.
+	// +optional
+	GracePeriod *metav1.Duration `json:"gracePeriod,omitempty"`
+}
+
+// UpgradeMaintenanceSpec is the relaxed enforcement profile applied while an
+// OpenShift cluster upgrade or a MachineConfigPool update is in progress.
+// Fields that are not set keep their configured value.
+type UpgradeMaintenanceSpec struct {
+	// Warn switches every ClusterImagePolicy to warn mode.
+	// +optional
+	Warn bool `json:"warn,omitempty"`
+	// FailurePolicy of the policy webhooks.
+	// +kubebuilder:validation:Enum=Fail;Ignore
+	// +optional
+	FailurePolicy *admissionregistrationv1.FailurePolicyType `json:"failurePolicy,omitempty"`
+	// TimeoutSeconds of the policy webhooks.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=30
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+	// ReplicaCount of the webhook Deployment. It only raises the configured
+	// replica count.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ReplicaCount *int32 `json:"replicaCount,omitempty"`
+}
+
+// PolicyControllerValues are the policy-controller subchart values.
+type PolicyControllerValues struct {
+	Cosign        CosignValues        `json:"cosign,omitempty"`
+	Webhook       WebhookValues       `json:"webhook,omitempty"`
+	LeasesCleanup LeasesCleanupValues `json:"leasescleanup,omitempty"`
+
+	// CommonNodeSelector is applied to every pod rendered by the chart.
+	CommonNodeSelector map[string]string `json:"commonNodeSelector,omitempty"`
+}
+
+// CosignValues configures the policy.rhtas.com admission webhook.
+type CosignValues struct {
+	// CosignPub is a base64 encoded public key.
+	CosignPub string `json:"cosignPub,omitempty"`
+	// WebhookName is the name of the validating and mutating webhook
+	// configurations that enforce image policies.
+	WebhookName           string                      `json:"webhookName,omitempty"`
+	WebhookTimeoutSeconds CosignWebhookTimeoutSeconds `json:"webhookTimeoutSeconds,omitempty"`
+}
+
+// CosignWebhookTimeoutSeconds overrides the timeouts of the policy webhooks.
+type CosignWebhookTimeoutSeconds struct {
+	Mutating   *int32 `json:"mutating,omitempty"`
+	Validating *int32 `json:"validating,omitempty"`
+}
+
+// WebhookValues configures the policy-controller webhook Deployment and the
+// webhook configurations it manages.
+type WebhookValues struct {
+	Name         string         `json:"name,omitempty"`
+	ReplicaCount *int32         `json:"replicaCount,omitempty"`
+	Image        ImageValues    `json:"image,omitempty"`
+	Env          EnvValues      `json:"env,omitempty"`
+	EnvFrom      EnvFromValues  `json:"envFrom,omitempty"`
+	Resources    ResourceValues `json:"resources,omitempty"`
+	// ExtraArgs are passed to the webhook binary as -key=value flags.
+	ExtraArgs         map[string]apiextensionsv1.JSON            `json:"extraArgs,omitempty"`
+	FailurePolicy     *admissionregistrationv1.FailurePolicyType `json:"failurePolicy,omitempty"`
+	NamespaceSelector *metav1.LabelSelector                      `json:"namespaceSelector,omitempty"`
+
+	// Affinity of the webhook pods. The chart spreads the pods across nodes
+	// when it is empty.
+	Affinity            *corev1.Affinity          `json:"affinity,omitempty"`
+	PodDisruptionBudget PodDisruptionBudgetValues `json:"podDisruptionBudget,omitempty"`
+	ServiceAccount      ServiceAccountValues      `json:"serviceAccount,omitempty"`
+	RegistryCaBundle    RegistryCaBundleValues    `json:"registryCaBundle,omitempty"`
+
+	WebhookNames          WebhookNamesValues          `json:"webhookNames,omitempty"`
+	WebhookTimeoutSeconds WebhookTimeoutSecondsValues `json:"webhookTimeoutSeconds,omitempty"`
+
+	PriorityClass                string `json:"priorityClass,omitempty"`
+	AutomountServiceAccountToken *bool  `json:"automountServiceAccountToken,omitempty"`
+}
+
+// ImageValues is an image reference split the way the chart expects it.
+// Version is either a tag or, when it starts with "sha256:", a digest.
+type ImageValues struct {
+	Repository string            `json:"repository,omitempty"`
+	Version    string            `json:"version,omitempty"`
+	PullPolicy corev1.PullPolicy `json:"pullPolicy,omitempty"`
+}
+
+// EnvValues maps an environment variable to its value. The chart quotes
+// the values, so numbers and booleans are accepted as well and kept as the
+// text they were written as.
+type EnvValues map[string]string
+
+// EnvFromValues names the ConfigMaps and Secrets exposed to the webhook
+// container through envFrom.
+type EnvFromValues struct {
+	ConfigMaps []string `json:"configmaps,omitempty"`
+	Secrets    []string `json:"secrets,omitempty"`
+}
+
+// ResourceValues holds container resources as the raw strings found in the
+// chart values. The leasescleanup defaults use empty strings, which the
+// chart skips but which are not valid quantities.
+type ResourceValues struct {
+	Limits   ResourceListValues `json:"limits,omitempty"`
+	Requests ResourceListValues `json:"requests,omitempty"`
+}
+
+// ResourceListValues maps a resource name to its unparsed quantity.
+// Quantities written as numbers, such as cpu: 1, are kept as their text.
+type ResourceListValues map[corev1.ResourceName]string
+
+// PodDisruptionBudgetValues configures the webhook PodDisruptionBudget.
+type PodDisruptionBudgetValues struct {
+	Enabled        bool                `json:"enabled,omitempty"`
+	MinAvailable   *intstr.IntOrString `json:"minAvailable,omitempty"`
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// ServiceAccountValues configures the webhook ServiceAccount.
+type ServiceAccountValues struct {
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Create      *bool             `json:"create,omitempty"`
+	Name        string            `json:"name,omitempty"`
+}
+
+// RegistryCaBundleValues references a ConfigMap key holding additional CA
+// certificates used when talking to registries.
+type RegistryCaBundleValues struct {
+	Name string `json:"name,omitempty"`
+	Key  string `json:"key,omitempty"`
+}
+
+// WebhookNamesValues are the names of the ClusterImagePolicy webhooks.
+type WebhookNamesValues struct {
+	Defaulting string `json:"defaulting,omitempty"`
+	Validating string `json:"validating,omitempty"`
+}
+
+// WebhookTimeoutSecondsValues overrides the timeouts of the ClusterImagePolicy webhooks.
+type WebhookTimeoutSecondsValues struct {
+	Defaulting *int32 `json:"defaulting,omitempty"`
+	Validating *int32 `json:"validating,omitempty"`
+}
+
+// LeasesCleanupValues configured the post-delete Job that removed webhook
+// leases.
+//
+// Deprecated: the operator removes the leases itself when the
+// PolicyController is deleted, and these values are ignored.
+type LeasesCleanupValues struct {
+	PriorityClass                string         `json:"priorityClass,omitempty"`
+	Image                        ImageValues    `json:"image,omitempty"`
+	Resources                    ResourceValues `json:"resources,omitempty"`
+	AutomountServiceAccountToken *bool          `json:"automountServiceAccountToken,omitempty"`
+}
+
+// PolicyControllerStatus defines the observed state of PolicyController.
+// The helm-operator owns this status and reports the release through
+// conditions and deployedRelease.
+type PolicyControllerStatus struct {
+	Conditions      []metav1.Condition `json:"conditions,omitempty"`
+	DeployedRelease *DeployedRelease   `json:"deployedRelease,omitempty"`
+}
+
+// Condition types reported by the admission-webhook-controller next to the
+// release conditions of the helm-operator.
+const (
+	// ConditionWebhookAvailable is True when the policy webhook Deployment is
+	// available and its webhook configurations are registered.
+	ConditionWebhookAvailable = "WebhookAvailable"
+	// ConditionPoliciesLoaded is True when every ClusterImagePolicy is ready
+	// and compiled into the config-image-policies ConfigMap.
+	ConditionPoliciesLoaded = "PoliciesLoaded"
+	// ConditionTrustRootsReady is True when every TrustRoot is ready and
+	// compiled into the config-sigstore-keys ConfigMap.
+	ConditionTrustRootsReady = "TrustRootsReady"
+	// ConditionWebhookConfigurationSynced is True when the policy webhook
+	// configurations match the PolicyController spec.
+	ConditionWebhookConfigurationSynced = "WebhookConfigurationSynced"
+	// ConditionCleanupComplete reports the progress of the uninstall cleanup
+	// while the PolicyController is being deleted.
+	ConditionCleanupComplete = "CleanupComplete"
+	// ConditionBreakGlass is True while image policy enforcement is
+	// suspended by the break-glass annotation.
+	ConditionBreakGlass = "BreakGlass"
+	// ConditionCircuitBreakerOpen is True while the policy webhooks fail open
+	// because the webhook Deployment has been unavailable for longer than the
+	// grace period of the circuit breaker.
+	ConditionCircuitBreakerOpen = "CircuitBreakerOpen"
+	// ConditionUpgradeMaintenance is True while the upgrade maintenance
+	// profile is applied.
+	ConditionUpgradeMaintenance = "UpgradeMaintenance"
+)
+
+// DeployedRelease is the helm release that was last installed or upgraded.
+type DeployedRelease struct {
+	Name     string `json:"name,omitempty"`
+	Manifest string `json:"manifest,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// PolicyController is the Schema for the policycontrollers API
+type PolicyController struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PolicyControllerSpec   `json:"spec,omitempty"`
+	Status PolicyControllerStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PolicyControllerList contains a list of PolicyController
+type PolicyControllerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PolicyController `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PolicyController{}, &PolicyControllerList{})
+}
@@ -0,0 +1,215 @@
+package v1alpha1
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	sigsjson "sigs.k8s.io/json"
+)
+
+// The CRD preserves the spec as it is written, and helm renders a quoted
+// number or boolean the same way as an unquoted one, so a PolicyController
+// may hold values such as replicaCount: "2" that do not decode into the typed
+// fields. Failing the decode would break every reader of the type, including
+// the informers of the operator and the admission webhook that is needed to
+// correct the value, so the spec is decoded leniently instead.
+
+var unmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// policyControllerSpec is PolicyControllerSpec without its UnmarshalJSON.
+type policyControllerSpec PolicyControllerSpec
+
+// UnmarshalJSON converts the scalars of the spec to the type of their field
+// before it decodes them: quoted numbers and booleans are parsed, and numbers
+// and booleans of string fields are kept as the text they were written as.
+// Values that cannot be converted are dropped; ValidateSpecValues reports
+// them.
+func (s *PolicyControllerSpec) UnmarshalJSON(data []byte) error {
+	value, err := decodeNumbers(data)
+	if err != nil {
+		return err
+	}
+	value, _, _ = convertValue(value, reflect.TypeFor[policyControllerSpec](), field.NewPath("spec"))
+	if data, err = json.Marshal(value); err != nil {
+		return err
+	}
+	return sigsjson.UnmarshalCaseSensitivePreserveInts(data, (*policyControllerSpec)(s))
+}
+
+// ValidateSpecValues reports the values of a serialized spec that do not fit
+// the type of their field, and that PolicyControllerSpec.UnmarshalJSON
+// therefore drops.
+func ValidateSpecValues(data []byte) field.ErrorList {
+	path := field.NewPath("spec")
+	value, err := decodeNumbers(data)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, string(data), err.Error())}
+	}
+	_, _, errs := convertValue(value, reflect.TypeFor[policyControllerSpec](), path)
+	return errs
+}
+
+// decodeNumbers decodes JSON keeping numbers as the text they were written
+// as.
+func decodeNumbers(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// convertValue converts a decoded JSON value to fit a field of type t. It
+// returns false if the value does not fit and has to be dropped. Types that
+// decode themselves are left alone.
+func convertValue(value interface{}, t reflect.Type, path *field.Path) (interface{}, bool, field.ErrorList) {
+	if value == nil {
+		return nil, true, nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return value, true, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false, field.ErrorList{field.Invalid(path, value, "must be an object")}
+		}
+		fields := jsonFields(t)
+		var errs field.ErrorList
+		for key, v := range object {
+			if ft, ok := fields[key]; ok {
+				errs = append(errs, convertEntry(object, key, v, ft, path.Child(key))...)
+			}
+		}
+		return object, true, errs
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false, field.ErrorList{field.Invalid(path, value, "must be an object")}
+		}
+		var errs field.ErrorList
+		for key, v := range object {
+			errs = append(errs, convertEntry(object, key, v, t.Elem(), path.Key(key))...)
+		}
+		return object, true, errs
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return value, true, nil
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, false, field.ErrorList{field.Invalid(path, value, "must be an array")}
+		}
+		var errs field.ErrorList
+		for i, item := range items {
+			converted, _, itemErrs := convertValue(item, t.Elem(), path.Index(i))
+			items[i] = converted
+			errs = append(errs, itemErrs...)
+		}
+		return items, true, errs
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			return v, true, nil
+		case json.Number:
+			return v.String(), true, nil
+		case bool:
+			return strconv.FormatBool(v), true, nil
+		}
+		return nil, false, field.ErrorList{field.Invalid(path, value, "must be a string, number or boolean")}
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			return v, true, nil
+		case string:
+			if v == "true" || v == "false" {
+				return v == "true", true, nil
+			}
+		}
+		return nil, false, field.ErrorList{field.Invalid(path, value, "must be a boolean")}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := parseInt(value, t.Bits()); ok {
+			return json.Number(strconv.FormatInt(n, 10)), true, nil
+		}
+		return nil, false, field.ErrorList{field.Invalid(path, value, "must be an integer")}
+	}
+	return value, true, nil
+}
+
+// convertEntry converts the value of an object key in place, or removes the
+// key if the value does not fit.
+func convertEntry(object map[string]interface{}, key string, value interface{}, t reflect.Type, path *field.Path) field.ErrorList {
+	converted, ok, errs := convertValue(value, t, path)
+	if ok {
+		object[key] = converted
+	} else {
+		delete(object, key)
+	}
+	return errs
+}
+
+// parseInt parses a number or a quoted number as an integer of the given
+// size. Numbers written with a fraction, such as 2.0, are accepted if they
+// are whole.
+func parseInt(value interface{}, bits int) (int64, bool) {
+	var text string
+	switch v := value.(type) {
+	case json.Number:
+		text = v.String()
+	case string:
+		text = strings.TrimSpace(v)
+	default:
+		return 0, false
+	}
+	if n, err := strconv.ParseInt(text, 10, bits); err == nil {
+		return n, true
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || f != math.Trunc(f) || f < -math.Exp2(float64(bits-1)) || f >= math.Exp2(float64(bits-1)) {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// jsonFields maps the JSON names of the fields of a struct to their types,
+// including the fields of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" && f.Anonymous {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for name, ft := range jsonFields(embedded) {
+					fields[name] = ft
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
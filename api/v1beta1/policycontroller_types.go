@@ -0,0 +1,124 @@
+package v1beta1
+
+import (
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+)
+
+// ValuesAnnotation holds the v1alpha1 helm values that have no v1beta1
+// field, so that converting an object to v1beta1 and back is lossless.
+const ValuesAnnotation = "rhtas.charts.redhat.com/policy-controller-values"
+
+// TrustMode selects where the policy-controller webhook obtains Sigstore
+// trust material.
+// +kubebuilder:validation:Enum=TrustRoots;PublicGoodTUF
+type TrustMode string
+
+const (
+	// TrustModeTrustRoots only trusts material configured through TrustRoot
+	// resources. This is the RHTAS default (disable-tuf: true).
+	TrustModeTrustRoots TrustMode = "TrustRoots"
+	// TrustModePublicGoodTUF additionally trusts the Sigstore public good
+	// instance through its TUF repository.
+	TrustModePublicGoodTUF TrustMode = "PublicGoodTUF"
+)
+
+// PolicyControllerSpec defines the desired state of PolicyController.
+type PolicyControllerSpec struct {
+	Trust       TrustSpec       `json:"trust,omitempty"`
+	Enforcement EnforcementSpec `json:"enforcement,omitempty"`
+	Webhook     WebhookSpec     `json:"webhook,omitempty"`
+
+	// Profile selects the defaults and requirements for the webhook
+	// Deployment. Defaults to dev.
+	// +optional
+	Profile v1alpha1.DeploymentProfile `json:"profile,omitempty"`
+}
+
+// TrustSpec configures the trust material used to verify signatures.
+type TrustSpec struct {
+	// Mode defaults to TrustRoots when unset.
+	// +optional
+	Mode TrustMode `json:"mode,omitempty"`
+}
+
+// EnforcementSpec configures which admission requests are verified and what
+// happens when the policy webhook cannot be reached.
+type EnforcementSpec struct {
+	// FailurePolicy of the policy webhook configurations.
+	// +kubebuilder:validation:Enum=Fail;Ignore
+	// +optional
+	FailurePolicy *admissionregistrationv1.FailurePolicyType `json:"failurePolicy,omitempty"`
+
+	// NamespaceSelector selects the namespaces in which images are verified.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// WebhookDrift selects whether drift of the policy webhook configurations
+	// is restored or only reported. Defaults to Restore.
+	// +optional
+	WebhookDrift v1alpha1.WebhookDriftMode `json:"webhookDrift,omitempty"`
+
+	// CircuitBreaker switches the policy webhooks to fail open while the
+	// webhook Deployment is unavailable. Disabled when unset.
+	// +optional
+	CircuitBreaker *v1alpha1.CircuitBreakerSpec `json:"circuitBreaker,omitempty"`
+
+	// UpgradeMaintenance relaxes enforcement while an OpenShift cluster
+	// upgrade is in progress. Disabled when unset.
+	// +optional
+	UpgradeMaintenance *v1alpha1.UpgradeMaintenanceSpec `json:"upgradeMaintenance,omitempty"`
+}
+
+// WebhookSpec configures the availability of the policy webhook Deployment.
+type WebhookSpec struct {
+	// Replicas of the webhook Deployment.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// PodDisruptionBudget of the webhook Deployment.
+	// +optional
+	PodDisruptionBudget *PodDisruptionBudgetSpec `json:"podDisruptionBudget,omitempty"`
+
+	// PriorityClassName of the webhook pods.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+}
+
+// PodDisruptionBudgetSpec configures the webhook PodDisruptionBudget.
+type PodDisruptionBudgetSpec struct {
+	Enabled bool `json:"enabled"`
+	// +optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// PolicyController is the Schema for the policycontrollers API
+type PolicyController struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PolicyControllerSpec            `json:"spec,omitempty"`
+	Status v1alpha1.PolicyControllerStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PolicyControllerList contains a list of PolicyController
+type PolicyControllerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PolicyController `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PolicyController{}, &PolicyControllerList{})
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// NewClient returns a client for the cluster of the current kubeconfig
+// context that knows the PolicyController types.
+func NewClient() (client.Client, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return client.New(cfg, client.Options{Scheme: scheme})
+}
+
+// BreakGlass suspends image policy enforcement by setting the break-glass
+// annotation of a PolicyController on behalf of the current user, or ends a
+// break-glass early with --end.
+func BreakGlass(ctx context.Context, c client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("break-glass", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var (
+		mode      = flags.String("mode", string(v1alpha1.BreakGlassWarn), "Warn switches every ClusterImagePolicy to warn mode and sets the failurePolicy of the policy webhooks to Ignore. Ignore only sets the failurePolicy, which admits pods while the webhook is unreachable but not images it denies, so it covers outages, not broken trust material.")
+		reason    = flags.String("reason", "", "Why enforcement is suspended, for example an incident reference. Required unless --end is set.")
+		duration  = flags.Duration("duration", time.Hour, fmt.Sprintf("How long enforcement is suspended, at most %s.", v1alpha1.MaxBreakGlassDuration))
+		name      = flags.String("name", "", "Name of the PolicyController. Defaults to the only PolicyController in the namespace.")
+		namespace = flags.String("namespace", constants.PolicyControllerInstallNs, "Namespace of the PolicyController.")
+		end       = flags.Bool("end", false, "Restore enforcement before the break-glass expires.")
+	)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: admission-webhook-controller break-glass --reason REASON [--mode Warn|Ignore] [--duration 1h]")
+		fmt.Fprintln(stderr, "       admission-webhook-controller break-glass --end")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return ExitError
+	}
+	if flags.NArg() > 0 || (!*end && *reason == "") {
+		flags.Usage()
+		return ExitError
+	}
+
+	pc, err := findPolicyController(ctx, c, *namespace, *name)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitError
+	}
+	patch := client.MergeFrom(pc.DeepCopy())
+	if *end {
+		if _, ok := pc.Annotations[v1alpha1.BreakGlassAnnotation]; !ok {
+			fmt.Fprintf(stderr, "PolicyController %s/%s has no break-glass\n", pc.Namespace, pc.Name)
+			return ExitError
+		}
+		delete(pc.Annotations, v1alpha1.BreakGlassAnnotation)
+		if err := c.Patch(ctx, pc, patch); err != nil {
+			fmt.Fprintf(stderr, "unable to end the break-glass: %v\n", err)
+			return ExitError
+		}
+		fmt.Fprintf(stdout, "Ended the break-glass of PolicyController %s/%s; image policy enforcement is restored.\n", pc.Namespace, pc.Name)
+		return ExitAllowed
+	}
+
+	review := &authenticationv1.SelfSubjectReview{}
+	if err := c.Create(ctx, review); err != nil {
+		fmt.Fprintf(stderr, "unable to determine the current user: %v\n", err)
+		return ExitError
+	}
+	bg := v1alpha1.BreakGlass{
+		Mode:        v1alpha1.BreakGlassMode(*mode),
+		Reason:      *reason,
+		RequestedBy: review.Status.UserInfo.Username,
+		ExpiresAt:   metav1.NewTime(time.Now().Add(*duration).UTC().Truncate(time.Second)),
+	}
+	value, err := json.Marshal(bg)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitError
+	}
+	if pc.Annotations == nil {
+		pc.Annotations = map[string]string{}
+	}
+	pc.Annotations[v1alpha1.BreakGlassAnnotation] = string(value)
+	if err := c.Patch(ctx, pc, patch); err != nil {
+		fmt.Fprintf(stderr, "unable to break glass: %v\n", err)
+		return ExitError
+	}
+	fmt.Fprintf(stdout, "Image policy enforcement of PolicyController %s/%s is suspended in %s mode until %s.\n",
+		pc.Namespace, pc.Name, bg.Mode, bg.ExpiresAt.Format(time.RFC3339))
+	return ExitAllowed
+}
+
+func findPolicyController(ctx context.Context, c client.Client, namespace, name string) (*v1alpha1.PolicyController, error) {
+	if name != "" {
+		pc := &v1alpha1.PolicyController{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, pc); err != nil {
+			return nil, fmt.Errorf("unable to get PolicyController %s/%s: %w", namespace, name, err)
+		}
+		return pc, nil
+	}
+	list := &v1alpha1.PolicyControllerList{}
+	if err := c.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("unable to list PolicyControllers: %w", err)
+	}
+	if len(list.Items) != 1 {
+		return nil, fmt.Errorf("found %d PolicyControllers in namespace %q; select one with --name", len(list.Items), namespace)
+	}
+	return &list.Items[0], nil
+}
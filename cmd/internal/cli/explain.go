@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	"sigs.k8s.io/yaml"
+)
+
+// Exit codes of the subcommands.
+const (
+	ExitAllowed  = 0
+	ExitRejected = 1
+	ExitError    = 2
+)
+
+// stringList is a flag that may be repeated.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// Explain evaluates an image against ClusterImagePolicy and TrustRoot
+// manifests and prints which policies match it, which authorities were tried
+// and why each of them passed or failed. It returns ExitRejected if the
+// policy-controller would deny the image.
+func Explain(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var files stringList
+	flags.Var(&files, "f", "ClusterImagePolicy and TrustRoot manifest file or directory. May be repeated.")
+	flags.Var(&files, "filename", "Same as -f.")
+	var (
+		image         = flags.String("image", "", "Image reference to evaluate.")
+		ociLayout     = flags.String("oci-layout", "", "Read the image and its signatures from this OCI image layout instead of the registry.")
+		noMatchPolicy = flags.String("no-match-policy", string(policy.NoMatchDeny), "Outcome for images that no policy matches: allow, warn or deny.")
+		output        = flags.String("o", "text", "Output format: text or yaml.")
+	)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: admission-webhook-controller explain --image IMAGE -f MANIFESTS [flags]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return ExitError
+	}
+	if *image == "" && flags.NArg() == 1 {
+		*image = flags.Arg(0)
+	}
+	if *image == "" || len(files) == 0 {
+		flags.Usage()
+		return ExitError
+	}
+	switch policy.NoMatchPolicy(*noMatchPolicy) {
+	case policy.NoMatchAllow, policy.NoMatchWarn, policy.NoMatchDeny:
+	default:
+		fmt.Fprintf(stderr, "invalid --no-match-policy %q\n", *noMatchPolicy)
+		return ExitError
+	}
+
+	manifests, err := LoadManifests(nil, files...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitError
+	}
+	verifier := &policy.RegistryVerifier{}
+	if *ociLayout != "" {
+		if verifier.Registry, err = layoutRegistry(*ociLayout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return ExitError
+		}
+	}
+	evaluator := &policy.Evaluator{
+		Policies:      manifests.Policies,
+		TrustRoots:    manifests.TrustRoots,
+		NoMatchPolicy: policy.NoMatchPolicy(*noMatchPolicy),
+		Verifier:      verifier,
+	}
+	explanation := evaluator.Explain(ctx, *image, policy.Pods)
+
+	switch *output {
+	case "yaml":
+		data, err := yaml.Marshal(explanation)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return ExitError
+		}
+		_, _ = stdout.Write(data)
+	default:
+		PrintExplanation(stdout, explanation)
+	}
+	if explanation.Verdict == policy.Rejected {
+		return ExitRejected
+	}
+	return ExitAllowed
+}
+
+func layoutRegistry(dir string) (*policy.Layout, error) {
+	path, err := layout.FromPath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("OCI layout %s: %w", dir, err)
+	}
+	return &policy.Layout{Path: path}, nil
+}
+
+// PrintExplanation prints an explanation for humans.
+func PrintExplanation(w io.Writer, explanation policy.Explanation) {
+	fmt.Fprintf(w, "Image:   %s\nVerdict: %s\n", explanation.Image, explanation.Verdict)
+	if explanation.Message != "" {
+		fmt.Fprintf(w, "         %s\n", explanation.Message)
+	}
+	for _, p := range explanation.Policies {
+		fmt.Fprintf(w, "\nClusterImagePolicy %s (%s)\n", p.Name, p.Mode)
+		if !p.Matched {
+			fmt.Fprintln(w, "  no glob matches the image")
+			continue
+		}
+		if p.Glob != "" {
+			fmt.Fprintf(w, "  matched by glob %q\n", p.Glob)
+		}
+		if len(p.Authorities) == 0 {
+			fmt.Fprintf(w, "  %s: %s\n", p.Verdict, p.Message)
+			continue
+		}
+		fmt.Fprintf(w, "  %s\n", p.Verdict)
+		passed := false
+		for _, a := range p.Authorities {
+			fmt.Fprintf(w, "  authority %s: %s: %s\n", a.Name, a.Verdict, a.Message)
+			for _, at := range a.Attestations {
+				fmt.Fprintf(w, "    attestation %s (%s): %s: %s\n", at.Name, at.PredicateType, at.Verdict, at.Message)
+			}
+			passed = passed || a.Verdict == policy.Allowed
+		}
+		// The message of a policy repeats its authorities unless the policy
+		// itself failed after an authority passed.
+		if !passed || p.Verdict == policy.Allowed {
+			continue
+		}
+		fmt.Fprintf(w, "  %s\n", p.Message)
+	}
+}
@@ -0,0 +1,140 @@
+// Package cli implements the subcommands of the admission-webhook-controller
+// binary. Most of them evaluate image policies outside of a cluster;
+// break-glass acts on the PolicyController of the current kubeconfig context
+// and policy preview reads its Pods.
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Manifests are the ClusterImagePolicies and TrustRoots read from files.
+type Manifests struct {
+	Policies   []policy.ClusterImagePolicy
+	TrustRoots map[string]policy.TrustRoot
+}
+
+// LoadManifests reads the ClusterImagePolicies and TrustRoots of YAML or JSON
+// files, which may hold several documents. Directories are read
+// non-recursively. Files ending in .tpl, such as the templates of the e2e
+// tests, are rendered with the values first. Other kinds of objects are
+// skipped. TrustRoots are taken as ready since they have no status.
+func LoadManifests(values map[string]string, paths ...string) (*Manifests, error) {
+	manifests := &Manifests{TrustRoots: map[string]policy.TrustRoot{}}
+	files, err := manifestFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Ext(file) == ".tpl" {
+			if data, err = render(file, data, values); err != nil {
+				return nil, err
+			}
+		}
+		if err := manifests.add(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	sort.SliceStable(manifests.Policies, func(i, j int) bool {
+		return manifests.Policies[i].Name < manifests.Policies[j].Name
+	})
+	return manifests, nil
+}
+
+func manifestFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			switch filepath.Ext(strings.TrimSuffix(entry.Name(), ".tpl")) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+	}
+	return files, nil
+}
+
+// render executes a template with the functions the e2e tests provide.
+func render(file string, data []byte, values map[string]string) ([]byte, error) {
+	tpl, err := template.New(filepath.Base(file)).
+		Funcs(template.FuncMap{"nindent": nindent}).
+		Option("missingkey=error").
+		Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func nindent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n"+pad)
+}
+
+func (m *Manifests) add(data []byte) error {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(strings.NewReader(string(data)), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if obj.Object == nil || obj.GetAPIVersion() == "" {
+			continue
+		}
+		group := obj.GroupVersionKind().Group
+		if group != "policy.sigstore.dev" {
+			continue
+		}
+		switch obj.GetKind() {
+		case "ClusterImagePolicy":
+			cip, err := policy.FromUnstructured(obj)
+			if err != nil {
+				return err
+			}
+			m.Policies = append(m.Policies, cip)
+		case "TrustRoot":
+			tr, err := policy.TrustRootFromUnstructured(obj, true)
+			if err != nil {
+				return err
+			}
+			m.TrustRoots[tr.Name] = tr
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	"sigs.k8s.io/yaml"
+)
+
+// Outcome is the expected admission decision of a test case.
+type Outcome string
+
+const (
+	// Admit passes for Allowed and Warned images.
+	Admit Outcome = "admit"
+	// Deny passes for Rejected images.
+	Deny Outcome = "deny"
+)
+
+// Suite is a policy test suite file. Paths are relative to the file.
+type Suite struct {
+	// Manifests are the ClusterImagePolicy and TrustRoot files or
+	// directories under test.
+	Manifests []string `json:"manifests"`
+	// Values render the manifests ending in .tpl.
+	Values        map[string]string    `json:"values,omitempty"`
+	NoMatchPolicy policy.NoMatchPolicy `json:"noMatchPolicy,omitempty"`
+	// OCILayout holds the fixture images of the tests that name none.
+	OCILayout string     `json:"ociLayout,omitempty"`
+	Tests     []TestCase `json:"tests"`
+
+	path string
+}
+
+// TestCase expects an admission decision for a fixture image.
+type TestCase struct {
+	Name      string  `json:"name"`
+	Image     string  `json:"image"`
+	OCILayout string  `json:"ociLayout,omitempty"`
+	Expect    Outcome `json:"expect"`
+}
+
+// TestResult is the outcome of a test case. Images that could not be fully
+// evaluated fail, since the policy-controller may decide either way.
+type TestResult struct {
+	Name        string
+	Passed      bool
+	Message     string
+	Explanation policy.Explanation
+}
+
+// LoadSuite reads and validates a suite file.
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	suite := &Suite{path: path}
+	if err := yaml.UnmarshalStrict(data, suite); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var errs []error
+	if len(suite.Manifests) == 0 {
+		errs = append(errs, errors.New("manifests: required"))
+	}
+	switch suite.NoMatchPolicy {
+	case "", policy.NoMatchAllow, policy.NoMatchWarn, policy.NoMatchDeny:
+	default:
+		errs = append(errs, fmt.Errorf("noMatchPolicy: unsupported value %q", suite.NoMatchPolicy))
+	}
+	names := map[string]bool{}
+	for i, test := range suite.Tests {
+		field := fmt.Sprintf("tests[%d]", i)
+		switch {
+		case test.Name == "":
+			errs = append(errs, fmt.Errorf("%s.name: required", field))
+		case names[test.Name]:
+			errs = append(errs, fmt.Errorf("%s.name: duplicate test %q", field, test.Name))
+		}
+		names[test.Name] = true
+		if test.Image == "" {
+			errs = append(errs, fmt.Errorf("%s.image: required", field))
+		}
+		if test.Expect != Admit && test.Expect != Deny {
+			errs = append(errs, fmt.Errorf("%s.expect: must be %q or %q", field, Admit, Deny))
+		}
+		// Tests never reach out to a registry.
+		if test.OCILayout == "" && suite.OCILayout == "" {
+			errs = append(errs, fmt.Errorf("%s.ociLayout: required when the suite has no ociLayout", field))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return suite, nil
+}
+
+func (s *Suite) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(s.path), path)
+}
+
+// Run runs the tests whose name matches the filter, or all of them if it is
+// nil. Every test gets its own evaluator, since fixtures of different
+// layouts may share image references.
+func (s *Suite) Run(ctx context.Context, filter *regexp.Regexp) ([]TestResult, error) {
+	paths := make([]string, len(s.Manifests))
+	for i, path := range s.Manifests {
+		paths[i] = s.resolve(path)
+	}
+	manifests, err := LoadManifests(s.Values, paths...)
+	if err != nil {
+		return nil, err
+	}
+	noMatchPolicy := s.NoMatchPolicy
+	if noMatchPolicy == "" {
+		noMatchPolicy = policy.NoMatchDeny
+	}
+
+	var results []TestResult
+	for _, test := range s.Tests {
+		if filter != nil && !filter.MatchString(test.Name) {
+			continue
+		}
+		dir := test.OCILayout
+		if dir == "" {
+			dir = s.OCILayout
+		}
+		registry, err := layoutRegistry(s.resolve(dir))
+		if err != nil {
+			return nil, fmt.Errorf("test %q: %w", test.Name, err)
+		}
+		evaluator := &policy.Evaluator{
+			Policies:      manifests.Policies,
+			TrustRoots:    manifests.TrustRoots,
+			NoMatchPolicy: noMatchPolicy,
+			Verifier:      &policy.RegistryVerifier{Registry: registry},
+		}
+		explanation := evaluator.Explain(ctx, test.Image, policy.Pods)
+
+		result := TestResult{Name: test.Name, Explanation: explanation}
+		switch explanation.Verdict {
+		case policy.Unknown:
+			result.Message = "image could not be fully evaluated"
+		case policy.Rejected:
+			result.Passed = test.Expect == Deny
+			result.Message = fmt.Sprintf("expected %s, got %s", test.Expect, explanation.Verdict)
+		default:
+			result.Passed = test.Expect == Admit
+			result.Message = fmt.Sprintf("expected %s, got %s", test.Expect, explanation.Verdict)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Policy runs the policy subcommands.
+func Policy(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	switch {
+	case len(args) > 0 && args[0] == "test":
+		return PolicyTest(ctx, args[1:], stdout, stderr)
+	case len(args) > 0 && args[0] == "preview":
+		c, err := NewClient()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return ExitError
+		}
+		return PolicyPreview(ctx, c, args[1:], stdout, stderr)
+	}
+	fmt.Fprintln(stderr, "Usage: admission-webhook-controller policy test [flags] SUITE...")
+	fmt.Fprintln(stderr, "       admission-webhook-controller policy preview [flags] GLOB")
+	return ExitError
+}
+
+// PolicyTest runs policy test suites against their fixture images and
+// returns ExitRejected if a test fails.
+func PolicyTest(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("policy test", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var (
+		run     = flags.String("run", "", "Only run the tests whose name matches this regular expression.")
+		verbose = flags.Bool("v", false, "Explain the decision of passing tests too.")
+	)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: admission-webhook-controller policy test [flags] SUITE...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return ExitError
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return ExitError
+	}
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			fmt.Fprintf(stderr, "invalid --run: %v\n", err)
+			return ExitError
+		}
+	}
+
+	passed, failed := 0, 0
+	for _, path := range flags.Args() {
+		suite, err := LoadSuite(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return ExitError
+		}
+		results, err := suite.Run(ctx, filter)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+			return ExitError
+		}
+		fmt.Fprintf(stdout, "%s:\n", path)
+		for _, result := range results {
+			if result.Passed {
+				passed++
+				fmt.Fprintf(stdout, "  PASS: %s\n", result.Name)
+			} else {
+				failed++
+				fmt.Fprintf(stdout, "  FAIL: %s: %s\n", result.Name, result.Message)
+			}
+			if !result.Passed || *verbose {
+				var explanation strings.Builder
+				PrintExplanation(&explanation, result.Explanation)
+				fmt.Fprintln(stdout, nindent(4, explanation.String()))
+			}
+		}
+	}
+	fmt.Fprintln(stdout, strings.Repeat("-", 80))
+	fmt.Fprintf(stdout, "PASS: %d/%d\n", passed, passed+failed)
+	if failed > 0 {
+		fmt.Fprintf(stdout, "FAIL: %d/%d\n", failed, passed+failed)
+		return ExitRejected
+	}
+	return ExitAllowed
+}
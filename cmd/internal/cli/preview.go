@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxListedPods bounds the Pods printed for an image.
+const maxListedPods = 3
+
+// PolicyPreview lists the images of the running Pods that a glob matches,
+// together with the Pods that run them. It returns ExitRejected if the glob
+// matches none of them.
+func PolicyPreview(ctx context.Context, c client.Reader, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("policy preview", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	namespace := flags.String("namespace", "", "Only list the Pods of this namespace. Defaults to all namespaces.")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: admission-webhook-controller policy preview [--namespace NAMESPACE] GLOB")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return ExitError
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return ExitError
+	}
+	glob := flags.Arg(0)
+	if err := policy.ValidateGlob(glob); err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitError
+	}
+	if reason := policy.UnmatchableGlob(glob); reason != "" {
+		fmt.Fprintf(stderr, "warning: glob %q %s\n", glob, reason)
+	}
+
+	pods := &corev1.PodList{}
+	if err := c.List(ctx, pods, client.InNamespace(*namespace)); err != nil {
+		fmt.Fprintf(stderr, "unable to list Pods: %v\n", err)
+		return ExitError
+	}
+	matches := map[string][]string{}
+	running := 0
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		running++
+		for _, image := range controller.PodImages(&pod.Spec) {
+			matched, err := policy.MatchGlob(glob, image)
+			if err != nil {
+				fmt.Fprintf(stderr, "%s/%s: %v\n", pod.Namespace, pod.Name, err)
+				continue
+			}
+			if matched {
+				matches[image] = append(matches[image], pod.Namespace+"/"+pod.Name)
+			}
+		}
+	}
+	if len(matches) == 0 {
+		fmt.Fprintf(stdout, "Glob %q matches none of the images of %d running %s.\n", glob, running, plural(running, "Pod"))
+		return ExitRejected
+	}
+
+	images := make([]string, 0, len(matches))
+	for image := range matches {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	fmt.Fprintf(stdout, "Glob %q matches %d %s:\n", glob, len(images), plural(len(images), "image"))
+	for _, image := range images {
+		names := matches[image]
+		sort.Strings(names)
+		listed := strings.Join(names[:min(len(names), maxListedPods)], ", ")
+		if len(names) > maxListedPods {
+			listed += fmt.Sprintf(" and %d more", len(names)-maxListedPods)
+		}
+		fmt.Fprintf(stdout, "  %s (%d %s: %s)\n", image, len(names), plural(len(names), "Pod"), listed)
+	}
+	return ExitAllowed
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return noun
+	}
+	return noun + "s"
+}
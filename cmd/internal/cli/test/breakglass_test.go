@@ -0,0 +1,73 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/cli"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestBreakGlass(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	pc := &v1alpha1.PolicyController{ObjectMeta: metav1.ObjectMeta{Name: "policycontroller-sample", Namespace: constants.PolicyControllerInstallNs}}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(pc).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if review, ok := obj.(*authenticationv1.SelfSubjectReview); ok {
+					review.Status.UserInfo.Username = "oncall@example.com"
+					return nil
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).
+		Build()
+
+	var stdout, stderr bytes.Buffer
+	code := cli.BreakGlass(ctx, c, []string{"--reason", "INC-42", "--duration", "2h"}, &stdout, &stderr)
+	require.Equal(t, cli.ExitAllowed, code, stderr.String())
+	require.Contains(t, stdout.String(), "is suspended in Warn mode until")
+
+	updated := &v1alpha1.PolicyController{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(pc), updated))
+	bg, err := v1alpha1.ParseBreakGlass(updated)
+	require.NoError(t, err)
+	require.Equal(t, v1alpha1.BreakGlassWarn, bg.Mode)
+	require.Equal(t, "INC-42", bg.Reason)
+	require.Equal(t, "oncall@example.com", bg.RequestedBy)
+	require.WithinDuration(t, time.Now().Add(2*time.Hour), bg.ExpiresAt.Time, time.Minute)
+
+	stdout.Reset()
+	code = cli.BreakGlass(ctx, c, []string{"--end"}, &stdout, &stderr)
+	require.Equal(t, cli.ExitAllowed, code, stderr.String())
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(pc), updated))
+	require.NotContains(t, updated.Annotations, v1alpha1.BreakGlassAnnotation)
+
+	stderr.Reset()
+	require.Equal(t, cli.ExitError, cli.BreakGlass(ctx, c, []string{"--end"}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), "has no break-glass")
+
+	stderr.Reset()
+	require.Equal(t, cli.ExitError, cli.BreakGlass(ctx, c, nil, &stdout, &stderr))
+	require.Contains(t, stderr.String(), "Usage:")
+
+	stderr.Reset()
+	require.Equal(t, cli.ExitError, cli.BreakGlass(ctx, c, []string{"--reason", "x", "--namespace", "default"}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), `found 0 PolicyControllers in namespace "default"`)
+}
@@ -0,0 +1,136 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/securesign/policy-controller-operator/cmd/internal/cli"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	"github.com/securesign/policy-controller-operator/cmd/internal/testutil"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+const keyPolicy = `apiVersion: policy.sigstore.dev/v1beta1
+kind: ClusterImagePolicy
+metadata:
+  name: signed
+spec:
+  images:
+    - glob: "registry.example.com/**"
+  authorities:
+    - name: release-key
+      key:
+        data: |
+%s
+---
+apiVersion: policy.sigstore.dev/v1alpha1
+kind: ClusterImagePolicy
+metadata:
+  name: quay
+spec:
+  images:
+    - glob: "quay.io/**"
+  authorities:
+    - static:
+        action: pass
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+`
+
+func TestExplain(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ociLayout := signedLayout(t, "registry.example.com/app:v1", key)
+
+	explain := func(signer *ecdsa.PrivateKey, args ...string) (int, string) {
+		manifests := filepath.Join(t.TempDir(), "policies.yaml")
+		require.NoError(t, os.WriteFile(manifests, fmt.Appendf(nil, keyPolicy, indent(testutil.EncodePublicKey(t, signer.Public()), "          ")), 0o600))
+		var stdout, stderr bytes.Buffer
+		code := cli.Explain(context.Background(), append([]string{"-f", manifests, "--oci-layout", ociLayout}, args...), &stdout, &stderr)
+		require.Empty(t, stderr.String())
+		return code, stdout.String()
+	}
+
+	code, out := explain(key, "--image", "registry.example.com/app:v1")
+	require.Equal(t, cli.ExitAllowed, code)
+	require.Contains(t, out, "Verdict: Allowed")
+	require.Contains(t, out, `matched by glob "registry.example.com/**"`)
+	require.Contains(t, out, "authority release-key: Allowed: signature verified")
+	require.Contains(t, out, "ClusterImagePolicy quay (enforce)\n  no glob matches the image")
+
+	code, out = explain(other, "registry.example.com/app:v1")
+	require.Equal(t, cli.ExitRejected, code)
+	require.Contains(t, out, "authority release-key: Rejected: ")
+	require.Contains(t, out, "signature does not match the key")
+
+	code, out = explain(key, "-o", "yaml", "--no-match-policy", "warn", "docker.io/library/busybox")
+	require.Equal(t, cli.ExitAllowed, code)
+	var explanation policy.Explanation
+	require.NoError(t, yaml.Unmarshal([]byte(out), &explanation))
+	require.Equal(t, policy.Warned, explanation.Verdict)
+	require.Equal(t, "no matching policies", explanation.Message)
+}
+
+func TestExplainUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, cli.ExitError, cli.Explain(context.Background(), []string{"--image", "quay.io/app"}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), "Usage: admission-webhook-controller explain")
+
+	stderr.Reset()
+	require.Equal(t, cli.ExitError, cli.Explain(context.Background(), []string{"-f", "missing.yaml", "quay.io/app"}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), "missing.yaml")
+}
+
+// signedLayout writes an OCI layout with an image signed by the key the way
+// `cosign sign --key` does.
+func signedLayout(t *testing.T, ref string, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	dir := t.TempDir()
+	path, err := layout.Write(dir, empty.Index)
+	require.NoError(t, err)
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	require.NoError(t, path.AppendImage(img, layout.WithAnnotations(map[string]string{policy.RefNameAnnotation: ref})))
+
+	name, _, _ := strings.Cut(ref, ":")
+	payload := fmt.Appendf(nil, `{"critical":{"identity":{"docker-reference":%q},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, name, digest)
+	sum := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	require.NoError(t, err)
+	sig, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       static.NewLayer(payload, types.MediaType("application/vnd.dev.cosign.simplesigning.v1+json")),
+		Annotations: map[string]string{policy.SignatureAnnotation: base64.StdEncoding.EncodeToString(signature)},
+	})
+	require.NoError(t, err)
+	tag := strings.Replace(digest.String(), ":", "-", 1) + ".sig"
+	require.NoError(t, path.AppendImage(sig, layout.WithAnnotations(map[string]string{policy.RefNameAnnotation: tag})))
+	return dir
+}
+
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n"+prefix)
+}
@@ -0,0 +1,168 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/securesign/policy-controller-operator/cmd/internal/cli"
+	"github.com/securesign/policy-controller-operator/cmd/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const policyTemplate = `apiVersion: policy.sigstore.dev/v1beta1
+kind: ClusterImagePolicy
+metadata:
+  name: {{ .CIP_NAME }}
+spec:
+  images:
+    - glob: "{{ .TEST_IMAGE_PREFIX }}**"
+  authorities:
+    - key:
+        data: |-
+{{ nindent 10 .PUBLIC_KEY }}
+`
+
+const suite = `manifests:
+  - policies
+values:
+  CIP_NAME: signed
+  TEST_IMAGE_PREFIX: registry.example.com/
+  PUBLIC_KEY: |
+%s
+ociLayout: %s
+tests:
+  - name: signed image is admitted
+    image: registry.example.com/app:v1
+    expect: admit
+  - name: image signed by another key is denied
+    image: registry.example.com/app:v1
+    ociLayout: %s
+    expect: deny
+  - name: unmatched image is denied
+    image: quay.io/app:v1
+    expect: deny
+  - name: unmatched image is admitted
+    image: quay.io/app:v1
+    expect: admit
+`
+
+func TestPolicyTest(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "policies"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "policies", "signed.yaml.tpl"), []byte(policyTemplate), 0o600))
+	path := filepath.Join(dir, "suite.yaml")
+	require.NoError(t, os.WriteFile(path, fmt.Appendf(nil, suite,
+		indent(testutil.EncodePublicKey(t, key.Public()), "    "),
+		signedLayout(t, "registry.example.com/app:v1", key),
+		signedLayout(t, "registry.example.com/app:v1", other),
+	), 0o600))
+
+	loaded, err := cli.LoadSuite(path)
+	require.NoError(t, err)
+	results, err := loaded.Run(context.Background(), nil)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	for _, result := range results[:3] {
+		require.True(t, result.Passed, "%s: %s", result.Name, result.Message)
+	}
+	require.False(t, results[3].Passed)
+	require.Equal(t, "expected admit, got Rejected", results[3].Message)
+
+	results, err = loaded.Run(context.Background(), regexp.MustCompile("signed image"))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	var stdout, stderr bytes.Buffer
+	code := cli.Policy(context.Background(), []string{"test", path}, &stdout, &stderr)
+	require.Equal(t, cli.ExitRejected, code, stderr.String())
+	require.Contains(t, stdout.String(), "  PASS: signed image is admitted\n")
+	require.Contains(t, stdout.String(), "  FAIL: unmatched image is admitted: expected admit, got Rejected\n")
+	require.Contains(t, stdout.String(), "    Verdict: Rejected\n")
+	require.Contains(t, stdout.String(), "PASS: 3/4\nFAIL: 1/4\n")
+
+	stdout.Reset()
+	code = cli.Policy(context.Background(), []string{"test", "--run", "denied", path}, &stdout, &stderr)
+	require.Equal(t, cli.ExitAllowed, code, stderr.String())
+	require.Contains(t, stdout.String(), "PASS: 2/2\n")
+}
+
+func TestLoadSuiteValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "suite.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`noMatchPolicy: reject
+tests:
+  - name: a
+    image: quay.io/app
+    expect: allow
+  - name: a
+`), 0o600))
+
+	_, err := cli.LoadSuite(path)
+	require.ErrorContains(t, err, "manifests: required")
+	require.ErrorContains(t, err, `noMatchPolicy: unsupported value "reject"`)
+	require.ErrorContains(t, err, `tests[0].expect: must be "admit" or "deny"`)
+	require.ErrorContains(t, err, "tests[0].ociLayout: required when the suite has no ociLayout")
+	require.ErrorContains(t, err, `tests[1].name: duplicate test "a"`)
+	require.ErrorContains(t, err, "tests[1].image: required")
+
+	require.NoError(t, os.WriteFile(path, []byte("manifests: [a]\nunknown: true\n"), 0o600))
+	_, err = cli.LoadSuite(path)
+	require.ErrorContains(t, err, `unknown field "unknown"`)
+}
+
+func TestLoadManifestsE2ETemplates(t *testing.T) {
+	customResources := filepath.Join("..", "..", "..", "..", "test", "utils", "custom_resources")
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	values := map[string]string{
+		"CIP_NAME":             "e2e",
+		"TEST_IMAGE_PREFIX":    "registry.example.com/e2e/",
+		"TEST_IMAGE":           "registry.example.com/e2e/app",
+		"FULCIO_URL":           "https://fulcio.example.com",
+		"TRUST_ROOT_REF":       "byok",
+		"OIDC_ISSUER_URL":      "https://keycloak.example.com/realms/trusted-artifact-signer",
+		"OIDC_ISSUER_SUBJECT":  "jdoe@redhat.com",
+		"REKOR_URL":            "https://rekor.example.com",
+		"TRUST_ROOT_NAME":      "byok",
+		"FULCIO_ORG_NAME":      "Red Hat",
+		"FULCIO_COMMON_NAME":   "fulcio",
+		"FULCIO_CERT_CHAIN":    "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----",
+		"CTLOG_URL":            "https://ctlog.example.com",
+		"CTLOG_HASH_ALGORITHM": "sha-256",
+		"CTFE_PUBLIC_KEY":      testutil.EncodePublicKey(t, key.Public()),
+		"REKOR_HASH_ALGORITHM": "sha-256",
+		"REKOR_PUBLIC_KEY":     testutil.EncodePublicKey(t, key.Public()),
+		"TSA_ORG_NAME":         "Red Hat",
+		"TSA_COMMON_NAME":      "tsa",
+		"TSA_URL":              "https://tsa.example.com",
+		"TSA_CERT_CHAIN":       "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----",
+	}
+
+	manifests, err := cli.LoadManifests(values,
+		filepath.Join(customResources, "cluster_image_policies", "common_cluster_image_policy.yaml.tpl"),
+		filepath.Join(customResources, "trust_roots", "byok_trust_root.yaml.tpl"),
+	)
+	require.NoError(t, err)
+	require.Len(t, manifests.Policies, 1)
+	require.Equal(t, "e2e", manifests.Policies[0].Name)
+	require.Equal(t, "registry.example.com/e2e/**", manifests.Policies[0].Spec.Images[0].Glob)
+	require.Len(t, manifests.Policies[0].Spec.Authorities[0].Attestations, 2)
+	require.Contains(t, manifests.TrustRoots, "byok")
+	require.Equal(t, testutil.EncodePublicKey(t, key.Public()), manifests.TrustRoots["byok"].SigstoreKeys.TLogs[0].PublicKey+"\n")
+
+	delete(values, "CIP_NAME")
+	_, err = cli.LoadManifests(values, filepath.Join(customResources, "cluster_image_policies"))
+	require.ErrorContains(t, err, `map has no entry for key "CIP_NAME"`)
+}
@@ -0,0 +1,81 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/securesign/policy-controller-operator/cmd/internal/cli"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPolicyPreview(t *testing.T) {
+	pod := func(namespace, name string, phase corev1.PodPhase, images ...string) *corev1.Pod {
+		p := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+		for _, image := range images {
+			p.Spec.Containers = append(p.Spec.Containers, corev1.Container{Name: name, Image: image})
+		}
+		return p
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		pod("team-a", "web-1", corev1.PodRunning, "nginx:1.27", "quay.io/securesign/cli:latest"),
+		pod("team-a", "web-2", corev1.PodRunning, "docker.io/library/nginx:1.27"),
+		pod("team-b", "web-1", corev1.PodPending, "nginx:1.27"),
+		pod("team-b", "job-1", corev1.PodSucceeded, "quay.io/securesign/team/cli"),
+	).Build()
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout []string
+		stderr string
+	}{
+		{
+			name: "Docker Hub images",
+			args: []string{"*"},
+			code: cli.ExitAllowed,
+			stdout: []string{
+				`Glob "*" matches 2 images:`,
+				"  docker.io/library/nginx:1.27 (1 Pod: team-a/web-2)",
+				"  nginx:1.27 (2 Pods: team-a/web-1, team-b/web-1)",
+			},
+		},
+		{
+			name:   "namespace",
+			args:   []string{"--namespace", "team-b", "quay.io/securesign/**"},
+			code:   cli.ExitRejected,
+			stdout: []string{`Glob "quay.io/securesign/**" matches none of the images of 1 running Pod.`},
+		},
+		{
+			name:   "unmatchable glob",
+			args:   []string{"docker.io/nginx"},
+			code:   cli.ExitRejected,
+			stderr: `warning: glob "docker.io/nginx" only matches the image written exactly as "docker.io/nginx"`,
+		},
+		{
+			name:   "malformed glob",
+			args:   []string{"quay.io/[a-z]+"},
+			code:   cli.ExitError,
+			stderr: `invalid glob "quay.io/[a-z]+"`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := cli.PolicyPreview(context.Background(), c, tc.args, &stdout, &stderr)
+			require.Equal(t, tc.code, code, stderr.String())
+			for _, line := range tc.stdout {
+				require.Contains(t, stdout.String(), line)
+			}
+			require.Contains(t, stderr.String(), tc.stderr)
+		})
+	}
+}
@@ -8,3 +8,88 @@ const (
 	PolicyControllerKind       = "PolicyController"
 	PolicyControllerInstallNs  = "policy-controller-operator"
 )
+
+// Resources rendered by the policy-controller chart.
+const (
+	DefaultWebhookName     = "policy.rhtas.com"
+	DefaultDefaultingName  = "defaulting.clusterimagepolicy.rhtas.com"
+	DefaultValidatingName  = "validating.clusterimagepolicy.rhtas.com"
+	ImagePoliciesConfigMap = "config-image-policies"
+	SigstoreKeysConfigMap  = "config-sigstore-keys"
+	PolicyConfigMap        = "config-policy-controller"
+	NoMatchPolicyKey       = "no-match-policy"
+	HelmReleaseLabel       = "app.kubernetes.io/instance"
+)
+
+// DefaultWebhookImageRepository is the webhook.image.repository of the chart
+// values. Its default webhook.image.version is a digest.
+const DefaultWebhookImageRepository = "registry.redhat.io/rhtas/policy-controller-rhel9"
+
+// ImageReferencePolicyConfigMap configures which image references a
+// PolicyController may set. The policy applies its defaults when it does not
+// exist.
+const ImageReferencePolicyConfigMap = "image-reference-policy"
+
+// AuditReportConfigMap holds the summary of the audit of the running
+// workloads against the installed policies. The audit of every namespace is
+// written to its own ConfigMap, named after the namespace with this prefix
+// and labelled with AuditNamespaceLabel.
+const (
+	AuditReportConfigMap = "policy-audit-report"
+	AuditNamespaceLabel  = "rhtas.charts.redhat.com/audit-namespace"
+)
+
+// ExemptedLabelsAnnotation records on a namespace the labels an
+// ImagePolicyException removed, so that they can be restored on expiry.
+const ExemptedLabelsAnnotation = "policy.rhtas.com/exempted-labels"
+
+// Custom resources served by the policy-controller webhook.
+const (
+	SigstorePolicyGroup    = "policy.sigstore.dev"
+	SigstorePolicyVersion  = "v1alpha1"
+	ClusterImagePolicyKind = "ClusterImagePolicy"
+	TrustRootKind          = "TrustRoot"
+)
+
+// Leader election leases that belong to the operator rather than to the
+// policy-controller webhook.
+const (
+	OperatorLeaderElectionID          = "policy-controller-operator"
+	WebhookControllerLeaderElectionID = "admission-webhook-controller.rhtas.charts.redhat.com"
+)
+
+// WebhookLeasePrefix prefixes the names of the leader election leases of the
+// policy-controller webhook. Its reconcilers name their leases
+// <component>.<reconciler>.<bucket>-of-<buckets>, and the component of the
+// webhook is policy-controller.
+const WebhookLeasePrefix = "policy-controller."
+
+// BreakGlassModeAnnotation records on a ClusterImagePolicy the mode it had
+// before a break-glass switched it to warn, so that it can be restored.
+const BreakGlassModeAnnotation = "policy.rhtas.com/break-glass-mode"
+
+// AllowAnyIdentityAnnotation set to "true" on a ClusterImagePolicy allows
+// keyless authorities without identities, which accept signatures of any
+// identity the Fulcio CA issued a certificate to.
+const AllowAnyIdentityAnnotation = "policy.rhtas.com/allow-any-identity"
+
+// MaintenanceReplicaCountAnnotation records on a PolicyController the
+// webhook.replicaCount it had before upgrade maintenance raised it, so that it
+// can be restored. It is empty when the value was not set.
+const MaintenanceReplicaCountAnnotation = "rhtas.charts.redhat.com/maintenance-replica-count"
+
+// MaintenanceTimeoutSecondsAnnotation records on a PolicyController, as JSON,
+// the cosign.webhookTimeoutSeconds it had before upgrade maintenance
+// overrode them, so that they can be restored.
+const MaintenanceTimeoutSecondsAnnotation = "rhtas.charts.redhat.com/maintenance-timeout-seconds"
+
+// DeclaredFailurePolicyAnnotation records on a PolicyController the
+// webhook.failurePolicy it had before break-glass, the circuit breaker or
+// upgrade maintenance overrode it, so that it can be restored. It is empty
+// when the value was not set.
+const DeclaredFailurePolicyAnnotation = "rhtas.charts.redhat.com/declared-failure-policy"
+
+// AppliedFailurePolicyAnnotation records on a PolicyController the
+// webhook.failurePolicy the operator set while it is overridden, so that a
+// value changed since is recognized as the declared one.
+const AppliedFailurePolicyAnnotation = "rhtas.charts.redhat.com/applied-failure-policy"
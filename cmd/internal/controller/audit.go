@@ -0,0 +1,518 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	kauth "github.com/google/go-containerregistry/pkg/authn/kubernetes"
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	"golang.org/x/time/rate"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/yaml"
+)
+
+// AuditSummaryKey is the key of the report summary in the
+// policy-audit-report ConfigMap, and AuditReportKey the key of the report of
+// a namespace in its own ConfigMap. A single ConfigMap would exceed the size
+// limit of an object on clusters with many workloads.
+const (
+	AuditSummaryKey = "summary"
+	AuditReportKey  = "report"
+)
+
+// Bounds of the registry requests of an audit. Images that are not verified
+// before the audit times out are reported as Unknown.
+const (
+	auditTimeout  = 10 * time.Minute
+	verifyTimeout = 30 * time.Second
+	// verifyRate is the number of signature and attestation verifications
+	// per second, each of which makes a few registry requests.
+	verifyRate = 5
+)
+
+// listPageSize is the number of objects listed per request.
+const listPageSize = 500
+
+// AuditReportName is the name of the ConfigMap holding the audit of a
+// namespace.
+func AuditReportName(namespace string) string {
+	return constants.AuditReportConfigMap + "-" + namespace
+}
+
+// AuditSummary counts the audited workloads by verdict.
+type AuditSummary struct {
+	GeneratedAt metav1.Time            `json:"generatedAt"`
+	Policies    int                    `json:"policies"`
+	Namespaces  int                    `json:"namespaces"`
+	Workloads   map[policy.Verdict]int `json:"workloads"`
+}
+
+// NamespaceAudit is the audit of the workloads of a namespace.
+type NamespaceAudit struct {
+	Namespace string `json:"namespace"`
+	// Enforced is true if the policy webhook already selects the namespace.
+	Enforced  bool            `json:"enforced"`
+	Workloads []WorkloadAudit `json:"workloads"`
+}
+
+// WorkloadAudit is the audit of a workload. Its verdict is the worst verdict
+// of its images.
+type WorkloadAudit struct {
+	Kind    string               `json:"kind"`
+	Name    string               `json:"name"`
+	Verdict policy.Verdict       `json:"verdict"`
+	Images  []policy.ImageResult `json:"images"`
+}
+
+// AuditReconciler evaluates the images of the running Pods, Deployments,
+// ReplicaSets, StatefulSets, DaemonSets, Jobs and CronJobs against the
+// installed ClusterImagePolicies and TrustRoots. It writes the audit of every
+// namespace into a ConfigMap of its own and a summary into the
+// policy-audit-report ConfigMap, so that the workloads the webhook would
+// reject are known before a namespace is labelled for enforcement.
+type AuditReconciler struct {
+	client.Client
+	// APIReader lists the workloads of the cluster without caching them.
+	APIReader client.Reader
+	// Verifier verifies the signatures of the images. Defaults to verifying
+	// them in their registries with the image pull secrets of the workload,
+	// its ServiceAccount and the policy webhook, as the webhook does.
+	Verifier policy.Verifier
+	// Interval between two audits. Changed policies are audited right away.
+	Interval time.Duration
+}
+
+func (r *AuditReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	enqueueAll := enqueueAllPolicyControllers(r.Client)
+	changed := builder.WithPredicates(predicate.GenerationChangedPredicate{})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("policycontroller-audit").
+		For(&v1alpha1.PolicyController{}, changed).
+		Watches(newUnstructured(ClusterImagePolicyGVK), enqueueAll, changed).
+		Watches(newUnstructured(TrustRootGVK), enqueueAll, changed).
+		Complete(r)
+}
+
+// workload is an object that runs containers.
+type workload struct {
+	kind     string
+	resource schema.GroupVersionResource
+	meta     metav1.ObjectMeta
+	spec     corev1.PodSpec
+}
+
+func (r *AuditReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	pc := &v1alpha1.PolicyController{}
+	if err := r.Get(ctx, req.NamespacedName, pc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !pc.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	evaluator, err := LoadEvaluator(ctx, r.Client, pc.Namespace, r.Verifier)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	declared := DeclaredNamespaceSelector(pc)
+	enforced, err := metav1.LabelSelectorAsSelector(&declared)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("invalid namespaceSelector: %w", err)
+	}
+	var namespaces []corev1.Namespace
+	if err := listPages(ctx, r.APIReader, &corev1.NamespaceList{}, nil, func(list client.ObjectList) {
+		namespaces = append(namespaces, list.(*corev1.NamespaceList).Items...)
+	}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to list namespaces: %w", err)
+	}
+	workloads, err := listWorkloads(ctx, r.APIReader)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	audits := map[string]*NamespaceAudit{}
+	for _, ns := range namespaces {
+		audits[ns.Name] = &NamespaceAudit{Namespace: ns.Name, Enforced: enforced.Matches(labels.Set(ns.Labels))}
+	}
+	summary := AuditSummary{
+		GeneratedAt: metav1.Now(),
+		Policies:    len(evaluator.Policies),
+		Workloads:   map[policy.Verdict]int{},
+	}
+	auditCtx, cancel := context.WithTimeout(ctx, auditTimeout)
+	defer cancel()
+	limiter := rate.NewLimiter(verifyRate, verifyRate)
+	evaluators := map[string]*policy.Evaluator{}
+	var webhookSecrets []corev1.Secret
+	if r.Verifier == nil {
+		if webhookSecrets, err = WebhookPullSecrets(ctx, r.Client, pc); err != nil {
+			return ctrl.Result{}, fmt.Errorf("unable to read the image pull secrets of the policy webhook: %w", err)
+		}
+	}
+	for _, w := range workloads {
+		audit, ok := audits[w.meta.Namespace]
+		if !ok {
+			continue
+		}
+		workloadEvaluator, err := r.evaluator(ctx, w, evaluator, limiter, webhookSecrets, evaluators)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		result := WorkloadAudit{Kind: w.kind, Name: w.meta.Name, Verdict: policy.Allowed}
+		resource := policy.Resource{GroupVersionResource: w.resource, Labels: w.meta.Labels}
+		for _, image := range PodImages(&w.spec) {
+			imageResult := workloadEvaluator.Evaluate(auditCtx, image, resource)
+			result.Verdict = policy.Worst(result.Verdict, imageResult.Verdict)
+			result.Images = append(result.Images, imageResult)
+		}
+		audit.Workloads = append(audit.Workloads, result)
+		summary.Workloads[result.Verdict]++
+	}
+
+	written := map[string]bool{}
+	for name, audit := range audits {
+		if len(audit.Workloads) == 0 {
+			continue
+		}
+		out, err := yaml.Marshal(audit)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.writeReport(ctx, pc, AuditReportName(name), map[string]string{constants.AuditNamespaceLabel: name}, map[string]string{AuditReportKey: string(out)}); err != nil {
+			return ctrl.Result{}, err
+		}
+		written[AuditReportName(name)] = true
+		summary.Namespaces++
+	}
+	out, err := yaml.Marshal(summary)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.writeReport(ctx, pc, constants.AuditReportConfigMap, nil, map[string]string{AuditSummaryKey: string(out)}); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.deleteStaleReports(ctx, pc.Namespace, written); err != nil {
+		return ctrl.Result{}, err
+	}
+	log.Info("audited workloads", "namespaces", summary.Namespaces, "workloads", summary.Workloads)
+
+	return ctrl.Result{RequeueAfter: r.Interval}, nil
+}
+
+// evaluator returns a copy of the loaded evaluator that verifies the images
+// of a workload with its image pull secrets. Workloads that share their
+// ServiceAccount and image pull secrets share an evaluator, and with it the
+// verifications of their images; the credentials of other workloads may not
+// grant access to the same images.
+func (r *AuditReconciler) evaluator(ctx context.Context, w workload, loaded *policy.Evaluator, limiter *rate.Limiter, webhookSecrets []corev1.Secret, evaluators map[string]*policy.Evaluator) (*policy.Evaluator, error) {
+	key := ""
+	if r.Verifier == nil {
+		key = w.meta.Namespace + "/" + w.spec.ServiceAccountName
+		for _, ref := range w.spec.ImagePullSecrets {
+			key += "/" + ref.Name
+		}
+	}
+	if evaluator, ok := evaluators[key]; ok {
+		return evaluator, nil
+	}
+
+	verifier := r.Verifier
+	if verifier == nil {
+		secrets, err := PodPullSecrets(ctx, r.APIReader, w.meta.Namespace, &w.spec)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the image pull secrets of %s %s/%s: %w", w.kind, w.meta.Namespace, w.meta.Name, err)
+		}
+		keychain, err := kauth.NewFromPullSecrets(ctx, append(secrets, webhookSecrets...))
+		if err != nil {
+			return nil, err
+		}
+		verifier = &policy.RegistryVerifier{Registry: &policy.Remote{Keychain: keychain}}
+	}
+	evaluator := *loaded
+	evaluator.Verifier = &boundedVerifier{verifier: verifier, limiter: limiter}
+	evaluators[key] = &evaluator
+	return &evaluator, nil
+}
+
+// writeReport creates or updates a report ConfigMap owned by the
+// PolicyController.
+func (r *AuditReconciler) writeReport(ctx context.Context, pc *v1alpha1.PolicyController, name string, labels, data map[string]string) error {
+	report := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: pc.Namespace, Name: name}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, report, func() error {
+		report.Labels = labels
+		report.Data = data
+		return controllerutil.SetControllerReference(pc, report, r.Scheme())
+	}); err != nil {
+		return fmt.Errorf("unable to write audit report %s: %w", name, err)
+	}
+	return nil
+}
+
+// deleteStaleReports deletes the reports of namespaces that were deleted or
+// no longer run workloads.
+func (r *AuditReconciler) deleteStaleReports(ctx context.Context, namespace string, written map[string]bool) error {
+	reports := &corev1.ConfigMapList{}
+	if err := r.List(ctx, reports, client.InNamespace(namespace), client.HasLabels{constants.AuditNamespaceLabel}); err != nil {
+		return fmt.Errorf("unable to list audit reports: %w", err)
+	}
+	for i := range reports.Items {
+		if written[reports.Items[i].Name] {
+			continue
+		}
+		if err := r.Delete(ctx, &reports.Items[i]); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("unable to delete audit report %s: %w", reports.Items[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// boundedVerifier limits the rate of the verifications of an audit and the
+// time each of them may take. Verifications that time out are not
+// evaluated rather than rejected. The verifiers of an audit share the
+// limiter.
+type boundedVerifier struct {
+	verifier policy.Verifier
+	limiter  *rate.Limiter
+}
+
+func (v *boundedVerifier) VerifySignature(ctx context.Context, image string, authority policy.Authority, trust *policy.TrustedMaterial) error {
+	return v.bound(ctx, func(ctx context.Context) error {
+		return v.verifier.VerifySignature(ctx, image, authority, trust)
+	})
+}
+
+func (v *boundedVerifier) VerifyAttestation(ctx context.Context, image string, authority policy.Authority, trust *policy.TrustedMaterial, predicateType string) error {
+	return v.bound(ctx, func(ctx context.Context) error {
+		return v.verifier.VerifyAttestation(ctx, image, authority, trust, predicateType)
+	})
+}
+
+func (v *boundedVerifier) bound(ctx context.Context, verify func(context.Context) error) error {
+	if err := v.limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("%w: the audit timed out before the image was verified", policy.ErrNotEvaluated)
+	}
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+	err := verify(ctx)
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("%w: verification timed out: %v", policy.ErrNotEvaluated, err)
+	}
+	return err
+}
+
+// LoadEvaluator loads the installed policies, the readiness of the TrustRoots
+// and the no-match-policy of the webhook installed in the namespace.
+func LoadEvaluator(ctx context.Context, c client.Reader, namespace string, verifier policy.Verifier) (*policy.Evaluator, error) {
+	log := logf.FromContext(ctx)
+	evaluator := &policy.Evaluator{
+		TrustRoots:    map[string]policy.TrustRoot{},
+		NoMatchPolicy: policy.NoMatchDeny,
+		Verifier:      verifier,
+	}
+
+	cips := &unstructured.UnstructuredList{}
+	cips.SetGroupVersionKind(ClusterImagePolicyGVK.GroupVersion().WithKind(ClusterImagePolicyGVK.Kind + "List"))
+	if err := c.List(ctx, cips); err != nil {
+		return nil, err
+	}
+	for i := range cips.Items {
+		cip, err := policy.FromUnstructured(&cips.Items[i])
+		if err != nil {
+			log.Error(err, "skipping ClusterImagePolicy")
+			continue
+		}
+		evaluator.Policies = append(evaluator.Policies, cip)
+	}
+
+	trustRoots := &unstructured.UnstructuredList{}
+	trustRoots.SetGroupVersionKind(TrustRootGVK.GroupVersion().WithKind(TrustRootGVK.Kind + "List"))
+	if err := c.List(ctx, trustRoots); err != nil {
+		return nil, err
+	}
+	for i := range trustRoots.Items {
+		tr, err := policy.TrustRootFromUnstructured(&trustRoots.Items[i], IsReady(&trustRoots.Items[i]))
+		if err != nil {
+			log.Error(err, "skipping TrustRoot")
+			continue
+		}
+		evaluator.TrustRoots[tr.Name] = tr
+	}
+
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: constants.PolicyConfigMap}, cm); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	} else if value := cm.Data[constants.NoMatchPolicyKey]; value != "" {
+		evaluator.NoMatchPolicy = policy.NoMatchPolicy(value)
+	}
+	return evaluator, nil
+}
+
+// CountWorkloads counts the workloads of a namespace that the policy webhook
+// admits.
+func CountWorkloads(ctx context.Context, reader client.Reader, namespace string) (int, error) {
+	workloads, err := listWorkloads(ctx, reader, client.InNamespace(namespace))
+	return len(workloads), err
+}
+
+// listedKinds are the kinds of workloads listWorkloads lists.
+var listedKinds = map[schema.GroupKind]bool{
+	{Group: appsv1.GroupName, Kind: "Deployment"}:  true,
+	{Group: appsv1.GroupName, Kind: "ReplicaSet"}:  true,
+	{Group: appsv1.GroupName, Kind: "StatefulSet"}: true,
+	{Group: appsv1.GroupName, Kind: "DaemonSet"}:   true,
+	{Group: batchv1.GroupName, Kind: "Job"}:        true,
+	{Group: batchv1.GroupName, Kind: "CronJob"}:    true,
+}
+
+// ownedByListedKind reports whether the controller of an object is a
+// workload that listWorkloads lists, and that therefore covers its images.
+func ownedByListedKind(obj metav1.Object) bool {
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil {
+		return false
+	}
+	gv, err := schema.ParseGroupVersion(owner.APIVersion)
+	return err == nil && listedKinds[gv.WithKind(owner.Kind).GroupKind()]
+}
+
+// listWorkloads lists the objects the policy webhook admits. Objects that
+// are created by another listed workload, such as the ReplicaSets of a
+// Deployment and their Pods, are left out, as that workload already covers
+// their images. Objects created by any other controller, such as an
+// operator, are listed.
+func listWorkloads(ctx context.Context, reader client.Reader, opts ...client.ListOption) ([]workload, error) {
+	var workloads []workload
+
+	if err := listPages(ctx, reader, &corev1.PodList{}, opts, func(list client.ObjectList) {
+		for _, p := range list.(*corev1.PodList).Items {
+			if ownedByListedKind(&p) || p.Status.Phase == corev1.PodSucceeded || p.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			workloads = append(workloads, workload{"Pod", corev1.SchemeGroupVersion.WithResource("pods"), p.ObjectMeta, p.Spec})
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list Pods: %w", err)
+	}
+
+	if err := listPages(ctx, reader, &appsv1.DeploymentList{}, opts, func(list client.ObjectList) {
+		for _, d := range list.(*appsv1.DeploymentList).Items {
+			workloads = append(workloads, workload{"Deployment", appsv1.SchemeGroupVersion.WithResource("deployments"), d.ObjectMeta, d.Spec.Template.Spec})
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list Deployments: %w", err)
+	}
+
+	if err := listPages(ctx, reader, &appsv1.ReplicaSetList{}, opts, func(list client.ObjectList) {
+		for _, rs := range list.(*appsv1.ReplicaSetList).Items {
+			if ownedByListedKind(&rs) {
+				continue
+			}
+			workloads = append(workloads, workload{"ReplicaSet", appsv1.SchemeGroupVersion.WithResource("replicasets"), rs.ObjectMeta, rs.Spec.Template.Spec})
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list ReplicaSets: %w", err)
+	}
+
+	if err := listPages(ctx, reader, &appsv1.StatefulSetList{}, opts, func(list client.ObjectList) {
+		for _, s := range list.(*appsv1.StatefulSetList).Items {
+			workloads = append(workloads, workload{"StatefulSet", appsv1.SchemeGroupVersion.WithResource("statefulsets"), s.ObjectMeta, s.Spec.Template.Spec})
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list StatefulSets: %w", err)
+	}
+
+	if err := listPages(ctx, reader, &appsv1.DaemonSetList{}, opts, func(list client.ObjectList) {
+		for _, d := range list.(*appsv1.DaemonSetList).Items {
+			workloads = append(workloads, workload{"DaemonSet", appsv1.SchemeGroupVersion.WithResource("daemonsets"), d.ObjectMeta, d.Spec.Template.Spec})
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list DaemonSets: %w", err)
+	}
+
+	if err := listPages(ctx, reader, &batchv1.JobList{}, opts, func(list client.ObjectList) {
+		for _, j := range list.(*batchv1.JobList).Items {
+			if ownedByListedKind(&j) {
+				continue
+			}
+			workloads = append(workloads, workload{"Job", batchv1.SchemeGroupVersion.WithResource("jobs"), j.ObjectMeta, j.Spec.Template.Spec})
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list Jobs: %w", err)
+	}
+
+	if err := listPages(ctx, reader, &batchv1.CronJobList{}, opts, func(list client.ObjectList) {
+		for _, c := range list.(*batchv1.CronJobList).Items {
+			workloads = append(workloads, workload{"CronJob", batchv1.SchemeGroupVersion.WithResource("cronjobs"), c.ObjectMeta, c.Spec.JobTemplate.Spec.Template.Spec})
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list CronJobs: %w", err)
+	}
+
+	sort.SliceStable(workloads, func(i, j int) bool {
+		if workloads[i].kind != workloads[j].kind {
+			return workloads[i].kind < workloads[j].kind
+		}
+		return workloads[i].meta.Name < workloads[j].meta.Name
+	})
+	return workloads, nil
+}
+
+// listPages lists the objects in pages of listPageSize, and calls visit
+// with every page.
+func listPages(ctx context.Context, reader client.Reader, list client.ObjectList, opts []client.ListOption, visit func(client.ObjectList)) error {
+	continueToken := ""
+	for {
+		pageOpts := append([]client.ListOption{client.Limit(listPageSize), client.Continue(continueToken)}, opts...)
+		if err := reader.List(ctx, list, pageOpts...); err != nil {
+			return err
+		}
+		visit(list)
+		if continueToken = list.GetContinue(); continueToken == "" {
+			return nil
+		}
+	}
+}
+
+// PodImages returns the distinct images of all containers of a Pod spec.
+func PodImages(spec *corev1.PodSpec) []string {
+	var images []string
+	seen := map[string]bool{}
+	add := func(image string) {
+		if image != "" && !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+	for _, c := range spec.InitContainers {
+		add(c.Image)
+	}
+	for _, c := range spec.Containers {
+		add(c.Image)
+	}
+	for _, c := range spec.EphemeralContainers {
+		add(c.Image)
+	}
+	return images
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Reasons of the BreakGlass condition and break-glass Events.
+const (
+	ReasonBreakGlassActivated = "Activated"
+	ReasonBreakGlassEnded     = "Ended"
+	ReasonInvalidBreakGlass   = "InvalidAnnotation"
+)
+
+// policyModesNote is added to the condition of a break-glass in Warn mode.
+// The mode of a ClusterImagePolicy belongs to whoever applies the policy, so
+// a GitOps tool that syncs it puts it back in enforce mode.
+const policyModesNote = "ClusterImagePolicy modes are switched to warn in the cluster, and tools such as Argo CD or Flux that sync the policies revert them to enforce unless syncing is paused"
+
+// BreakGlassReconciler suspends image policy enforcement while the
+// break-glass annotation of a PolicyController has not expired. It sets
+// webhook.failurePolicy of the PolicyController to Ignore and, in Warn mode,
+// switches every ClusterImagePolicy to warn mode. Once the break-glass
+// expires or the annotation is removed, the declared failurePolicy and the
+// original policy modes are restored and the expired annotation is removed. The BreakGlass
+// condition and Events record who suspended enforcement and why.
+type BreakGlassReconciler struct {
+	client.Client
+	Recorder events.EventRecorder
+}
+
+func (r *BreakGlassReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	enqueueAll := enqueueAllPolicyControllers(r.Client)
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("policycontroller-breakglass").
+		For(&v1alpha1.PolicyController{}).
+		Watches(newUnstructured(ClusterImagePolicyGVK), enqueueAll).
+		Complete(r)
+}
+
+func (r *BreakGlassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	pc := &v1alpha1.PolicyController{}
+	if err := r.Get(ctx, req.NamespacedName, pc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !pc.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	bg, parseErr := v1alpha1.ParseBreakGlass(pc)
+	now := time.Now()
+	active := parseErr == nil && bg.Active(now)
+	previous := meta.FindStatusCondition(pc.Status.Conditions, v1alpha1.ConditionBreakGlass)
+	wasActive := previous != nil && previous.Status == metav1.ConditionTrue
+
+	if err := applyFailurePolicy(ctx, r.Client, pc); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: time.Second}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if err := applyPolicyModes(ctx, r.Client, policiesWarn(pc)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	condition := metav1.Condition{Type: v1alpha1.ConditionBreakGlass, Status: metav1.ConditionFalse}
+	switch {
+	case parseErr != nil:
+		condition.Reason = ReasonInvalidBreakGlass
+		condition.Message = parseErr.Error()
+	case active:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = ReasonBreakGlassActivated
+		condition.Message = fmt.Sprintf("%s mode requested by %s until %s: %s", bg.Mode, bg.RequestedBy, bg.ExpiresAt.UTC().Format(time.RFC3339), bg.Reason)
+		if bg.Mode == v1alpha1.BreakGlassWarn {
+			condition.Message += "; " + policyModesNote
+		}
+	case bg != nil:
+		condition.Reason = ReasonExpired
+		condition.Message = fmt.Sprintf("%s mode requested by %s expired at %s: %s", bg.Mode, bg.RequestedBy, bg.ExpiresAt.UTC().Format(time.RFC3339), bg.Reason)
+	case wasActive:
+		condition.Reason = ReasonBreakGlassEnded
+		condition.Message = "break-glass annotation was removed before it expired; " + previous.Message
+	default:
+		return ctrl.Result{}, nil
+	}
+
+	switch {
+	case active && (!wasActive || previous.Message != condition.Message):
+		log.Info("image policy enforcement suspended", "mode", bg.Mode, "requestedBy", bg.RequestedBy, "reason", bg.Reason, "expiresAt", bg.ExpiresAt)
+		r.Recorder.Eventf(pc, nil, corev1.EventTypeWarning, ReasonBreakGlassActivated, "BreakGlass", "image policy enforcement suspended: %s", condition.Message)
+	case !active && wasActive:
+		log.Info("image policy enforcement restored", "reason", condition.Reason)
+		r.Recorder.Eventf(pc, nil, corev1.EventTypeNormal, ReasonRestored, "BreakGlass", "image policy enforcement restored: %s", condition.Message)
+	}
+
+	result, err := setConditions(ctx, r.Client, pc, condition)
+	if err != nil || !result.IsZero() {
+		return result, err
+	}
+
+	if active {
+		return ctrl.Result{RequeueAfter: bg.ExpiresAt.Sub(now) + time.Second}, nil
+	}
+	if bg != nil && parseErr == nil {
+		// The condition keeps the record of the expired break-glass.
+		patch := client.MergeFromWithOptions(pc.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		delete(pc.Annotations, v1alpha1.BreakGlassAnnotation)
+		if err := r.Patch(ctx, pc, patch); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{RequeueAfter: time.Second}, nil
+			}
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+	return ctrl.Result{}, nil
+}
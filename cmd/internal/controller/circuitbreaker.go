@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// DefaultCircuitBreakerGracePeriod is used when the circuit breaker of a
+// PolicyController sets no grace period.
+const DefaultCircuitBreakerGracePeriod = 5 * time.Minute
+
+// Reasons of the CircuitBreakerOpen condition and circuit breaker Events.
+const (
+	ReasonWebhookHealthy     = "WebhookHealthy"
+	ReasonWithinGracePeriod  = "WithinGracePeriod"
+	ReasonWebhookUnavailable = "WebhookUnavailable"
+	ReasonDisabled           = "Disabled"
+)
+
+var (
+	circuitBreakerOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "policy_controller_circuit_breaker_open",
+		Help: "1 while the policy webhooks of the PolicyController fail open because the webhook Deployment is unavailable.",
+	}, []string{"namespace", "name"})
+	circuitBreakerTrips = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "policy_controller_circuit_breaker_trips_total",
+		Help: "Number of times the policy webhooks of the PolicyController were switched to fail open.",
+	}, []string{"namespace", "name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(circuitBreakerOpen, circuitBreakerTrips)
+}
+
+// CircuitBreakerReconciler switches the policy webhooks of a PolicyController
+// with spec.circuitBreaker to fail open, by setting webhook.failurePolicy to
+// Ignore, once the webhook Deployment has been unavailable for longer than
+// the grace period, and restores the declared failurePolicy once it is
+// available again. The open state is kept in the CircuitBreakerOpen
+// condition.
+type CircuitBreakerReconciler struct {
+	client.Client
+	Recorder events.EventRecorder
+}
+
+func (r *CircuitBreakerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("policycontroller-circuitbreaker").
+		For(&v1alpha1.PolicyController{}).
+		Watches(&appsv1.Deployment{}, enqueueAllPolicyControllers(r.Client)).
+		Complete(r)
+}
+
+func (r *CircuitBreakerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	pc := &v1alpha1.PolicyController{}
+	if err := r.Get(ctx, req.NamespacedName, pc); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			circuitBreakerOpen.DeleteLabelValues(req.Namespace, req.Name)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !pc.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	previous := meta.FindStatusCondition(pc.Status.Conditions, v1alpha1.ConditionCircuitBreakerOpen)
+	wasOpen := previous != nil && previous.Status == metav1.ConditionTrue
+	condition := metav1.Condition{Type: v1alpha1.ConditionCircuitBreakerOpen, Status: metav1.ConditionFalse}
+	var requeueAfter time.Duration
+
+	if spec := pc.Spec.CircuitBreaker; spec == nil {
+		if previous == nil {
+			return ctrl.Result{}, nil
+		}
+		condition.Reason = ReasonDisabled
+		condition.Message = "circuit breaker is disabled"
+	} else {
+		gracePeriod := DefaultCircuitBreakerGracePeriod
+		if spec.GracePeriod != nil {
+			gracePeriod = spec.GracePeriod.Duration
+		}
+		name, since, err := r.unavailableSince(ctx, pc)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		unavailable := time.Since(since)
+		switch {
+		case since.IsZero():
+			condition.Reason = ReasonWebhookHealthy
+			condition.Message = "webhook Deployment is available"
+		case unavailable >= gracePeriod:
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = ReasonWebhookUnavailable
+			condition.Message = fmt.Sprintf("Deployment %q has been unavailable since %s; the policy webhooks fail open", name, since.UTC().Format(time.RFC3339))
+		default:
+			condition.Reason = ReasonWithinGracePeriod
+			condition.Message = fmt.Sprintf("Deployment %q has been unavailable since %s; the policy webhooks fail open after %s", name, since.UTC().Format(time.RFC3339), gracePeriod)
+			requeueAfter = gracePeriod - unavailable + time.Second
+		}
+	}
+
+	open := condition.Status == metav1.ConditionTrue
+	if wasOpen && open {
+		// Keep the time the circuit breaker tripped.
+		condition.Message = previous.Message
+	}
+	result, err := setConditions(ctx, r.Client, pc, condition)
+	if err != nil || !result.IsZero() {
+		return result, err
+	}
+	if err := applyFailurePolicy(ctx, r.Client, pc); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: time.Second}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	switch {
+	case open && !wasOpen:
+		log.Info("policy webhooks fail open", "reason", condition.Message)
+		circuitBreakerTrips.WithLabelValues(pc.Namespace, pc.Name).Inc()
+		r.Recorder.Eventf(pc, nil, corev1.EventTypeWarning, ReasonWebhookUnavailable, "CircuitBreaker", "%s", condition.Message)
+	case !open && wasOpen:
+		log.Info("policy webhooks fail closed again", "reason", condition.Reason)
+		r.Recorder.Eventf(pc, nil, corev1.EventTypeNormal, ReasonRestored, "CircuitBreaker", "restored the declared failurePolicy of the policy webhooks: %s", condition.Message)
+	}
+	if open {
+		circuitBreakerOpen.WithLabelValues(pc.Namespace, pc.Name).Set(1)
+	} else {
+		circuitBreakerOpen.WithLabelValues(pc.Namespace, pc.Name).Set(0)
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// unavailableSince returns the first unavailable webhook Deployment of the
+// helm release and when it became unavailable. The time is zero when every
+// Deployment is available or none has been deployed yet.
+func (r *CircuitBreakerReconciler) unavailableSince(ctx context.Context, pc *v1alpha1.PolicyController) (string, time.Time, error) {
+	deployments := &appsv1.DeploymentList{}
+	if err := r.List(ctx, deployments, client.InNamespace(pc.Namespace), client.MatchingLabels{constants.HelmReleaseLabel: pc.Name}); err != nil {
+		return "", time.Time{}, err
+	}
+	for _, d := range deployments.Items {
+		if deploymentAvailable(&d) {
+			continue
+		}
+		since := d.CreationTimestamp.Time
+		for _, c := range d.Status.Conditions {
+			if c.Type == appsv1.DeploymentAvailable && !c.LastTransitionTime.IsZero() {
+				since = c.LastTransitionTime.Time
+			}
+		}
+		return d.Name, since, nil
+	}
+	return "", time.Time{}, nil
+}
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// CleanupFinalizer keeps a PolicyController until the resources that
+	// outlive its helm release have been removed.
+	CleanupFinalizer = "rhtas.charts.redhat.com/cleanup"
+	// HelmUninstallFinalizer is added by the helm-operator, which removes it
+	// once the release has been uninstalled.
+	HelmUninstallFinalizer = "helm.sdk.operatorframework.io/uninstall-release"
+)
+
+// Reasons of the CleanupComplete condition.
+const (
+	ReasonWaitingForUninstall = "WaitingForUninstall"
+	ReasonCleanupFailed       = "CleanupFailed"
+	ReasonCleanedUp           = "CleanedUp"
+)
+
+// CleanupReconciler removes what the policy-controller leaves behind when its
+// PolicyController is deleted: the leader election leases of the webhook,
+// webhook configurations and the ConfigMaps the webhook generates. It runs
+// after the helm-operator has uninstalled the release, so that a still
+// running webhook cannot recreate them.
+type CleanupReconciler struct {
+	client.Client
+	// APIReader lists leases without caching them, as the webhook renews
+	// them every few seconds.
+	APIReader client.Reader
+}
+
+func (r *CleanupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("policycontroller-cleanup").
+		For(&v1alpha1.PolicyController{}).
+		Complete(r)
+}
+
+func (r *CleanupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	pc := &v1alpha1.PolicyController{}
+	if err := r.Get(ctx, req.NamespacedName, pc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if pc.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(pc, CleanupFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		patch := client.MergeFromWithOptions(pc.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		controllerutil.AddFinalizer(pc, CleanupFinalizer)
+		return ctrl.Result{}, client.IgnoreNotFound(r.Patch(ctx, pc, patch))
+	}
+
+	if !controllerutil.ContainsFinalizer(pc, CleanupFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	if controllerutil.ContainsFinalizer(pc, HelmUninstallFinalizer) {
+		return setConditions(ctx, r.Client, pc, metav1.Condition{
+			Type:    v1alpha1.ConditionCleanupComplete,
+			Status:  metav1.ConditionFalse,
+			Reason:  ReasonWaitingForUninstall,
+			Message: fmt.Sprintf("waiting for helm release %q to be uninstalled", pc.Name),
+		})
+	}
+
+	removed, err := r.cleanup(ctx, pc)
+	if err != nil {
+		if _, condErr := setConditions(ctx, r.Client, pc, metav1.Condition{
+			Type:    v1alpha1.ConditionCleanupComplete,
+			Status:  metav1.ConditionFalse,
+			Reason:  ReasonCleanupFailed,
+			Message: err.Error(),
+		}); condErr != nil {
+			log.Error(condErr, "unable to report cleanup failure")
+		}
+		return ctrl.Result{}, err
+	}
+	log.Info("cleaned up policy-controller resources", "removed", removed)
+
+	if _, err := setConditions(ctx, r.Client, pc, metav1.Condition{
+		Type:    v1alpha1.ConditionCleanupComplete,
+		Status:  metav1.ConditionTrue,
+		Reason:  ReasonCleanedUp,
+		Message: fmt.Sprintf("removed %d leftover resources", removed),
+	}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	patch := client.MergeFromWithOptions(pc.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	controllerutil.RemoveFinalizer(pc, CleanupFinalizer)
+	return ctrl.Result{}, client.IgnoreNotFound(r.Patch(ctx, pc, patch))
+}
+
+// cleanup deletes the leftovers of the release and returns how many objects
+// it removed. Objects that are already gone are skipped.
+func (r *CleanupReconciler) cleanup(ctx context.Context, pc *v1alpha1.PolicyController) (int, error) {
+	values := pc.Spec.PolicyController
+	defaulting := values.Webhook.WebhookNames.Defaulting
+	if defaulting == "" {
+		defaulting = constants.DefaultDefaultingName
+	}
+	validating := values.Webhook.WebhookNames.Validating
+	if validating == "" {
+		validating = constants.DefaultValidatingName
+	}
+	webhookName := policyWebhookName(pc)
+
+	objects := []client.Object{
+		&admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: webhookName}},
+		&admissionregistrationv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: webhookName}},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: validating}},
+		&admissionregistrationv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: defaulting}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: pc.Namespace, Name: constants.ImagePoliciesConfigMap}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: pc.Namespace, Name: constants.SigstoreKeysConfigMap}},
+	}
+
+	leases := &coordinationv1.LeaseList{}
+	if err := r.APIReader.List(ctx, leases, client.InNamespace(pc.Namespace)); err != nil {
+		return 0, fmt.Errorf("unable to list leases: %w", err)
+	}
+	for i := range leases.Items {
+		// Other components may share the namespace, so only the leases of
+		// the webhook are deleted.
+		if strings.HasPrefix(leases.Items[i].Name, constants.WebhookLeasePrefix) {
+			objects = append(objects, &leases.Items[i])
+		}
+	}
+
+	removed := 0
+	var errs []error
+	for _, obj := range objects {
+		if err := r.Delete(ctx, obj); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				errs = append(errs, fmt.Errorf("unable to delete %s %q: %w", reflect.TypeOf(obj).Elem().Name(), obj.GetName(), err))
+			}
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// setConditions merges conditions into the PolicyController status. The
+// helm-operator owns the rest of the status, so only the conditions list is
+// patched and the optimistic lock keeps a concurrent status update of the
+// helm-operator from being overwritten. A conflict is retried shortly.
+func setConditions(ctx context.Context, c client.Client, pc *v1alpha1.PolicyController, conditions ...metav1.Condition) (ctrl.Result, error) {
+	patch := client.MergeFromWithOptions(pc.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	changed := false
+	for _, condition := range conditions {
+		if meta.SetStatusCondition(&pc.Status.Conditions, condition) {
+			changed = true
+		}
+	}
+	if !changed {
+		return ctrl.Result{}, nil
+	}
+
+	if err := c.Status().Patch(ctx, pc, patch); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: time.Second}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// enqueueAllPolicyControllers maps any watched object to every
+// PolicyController, as the chart resources carry no owner reference that
+// works across scopes.
+func enqueueAllPolicyControllers(c client.Client) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, _ client.Object) []reconcile.Request {
+		list := &v1alpha1.PolicyControllerList{}
+		if err := c.List(ctx, list); err != nil {
+			logf.FromContext(ctx).Error(err, "unable to list PolicyControllers")
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(list.Items))
+		for _, pc := range list.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&pc)})
+		}
+		return requests
+	})
+}
+
+// policyWebhookName is the name of the validating and mutating webhook
+// configurations that enforce image policies.
+func policyWebhookName(pc *v1alpha1.PolicyController) string {
+	if name := pc.Spec.PolicyController.Cosign.WebhookName; name != "" {
+		return name
+	}
+	return constants.DefaultWebhookName
+}
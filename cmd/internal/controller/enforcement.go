@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// failurePolicyOverride returns the failurePolicy the policy webhooks are to
+// have while a break-glass is active, the circuit breaker is open or upgrade
+// maintenance is in progress, or nil if it is not overridden. Failing open
+// takes precedence over the maintenance profile.
+func failurePolicyOverride(pc *v1alpha1.PolicyController) *admissionregistrationv1.FailurePolicyType {
+	if bg, err := v1alpha1.ParseBreakGlass(pc); err == nil && bg.Active(time.Now()) {
+		return ptr.To(admissionregistrationv1.Ignore)
+	}
+	if meta.IsStatusConditionTrue(pc.Status.Conditions, v1alpha1.ConditionCircuitBreakerOpen) {
+		return ptr.To(admissionregistrationv1.Ignore)
+	}
+	if maintenance := pc.Spec.UpgradeMaintenance; maintenance != nil && maintenance.FailurePolicy != nil &&
+		meta.IsStatusConditionTrue(pc.Status.Conditions, v1alpha1.ConditionUpgradeMaintenance) {
+		return ptr.To(*maintenance.FailurePolicy)
+	}
+	return nil
+}
+
+// applyFailurePolicy sets webhook.failurePolicy to the override, recording
+// the configured value in the declared-failure-policy annotation, or restores
+// the recorded value once nothing overrides it. A value that differs from the
+// one applied was set while the override was in place and becomes the
+// declared value, so that the edit is neither reverted nor lost on restore.
+// The helm operator renders the value into the policy webhooks and would
+// revert them if they were patched directly.
+func applyFailurePolicy(ctx context.Context, c client.Client, pc *v1alpha1.PolicyController) error {
+	override := failurePolicyOverride(pc)
+	values := &pc.Spec.PolicyController.Webhook
+	declared, saved := pc.Annotations[constants.DeclaredFailurePolicyAnnotation]
+	applied := pc.Annotations[constants.AppliedFailurePolicyAnnotation]
+	current := ""
+	if values.FailurePolicy != nil {
+		current = string(*values.FailurePolicy)
+	}
+	if !saved || current != applied {
+		declared = current
+	}
+	patch := client.MergeFromWithOptions(pc.DeepCopy(), client.MergeFromWithOptimisticLock{})
+
+	if override != nil {
+		if saved && current == applied && current == string(*override) {
+			return nil
+		}
+		if pc.Annotations == nil {
+			pc.Annotations = map[string]string{}
+		}
+		pc.Annotations[constants.DeclaredFailurePolicyAnnotation] = declared
+		pc.Annotations[constants.AppliedFailurePolicyAnnotation] = string(*override)
+		values.FailurePolicy = ptr.To(*override)
+	} else {
+		if !saved {
+			return nil
+		}
+		values.FailurePolicy = nil
+		if declared != "" {
+			values.FailurePolicy = ptr.To(admissionregistrationv1.FailurePolicyType(declared))
+		}
+		delete(pc.Annotations, constants.DeclaredFailurePolicyAnnotation)
+		delete(pc.Annotations, constants.AppliedFailurePolicyAnnotation)
+	}
+	return c.Patch(ctx, pc, patch)
+}
+
+// policiesWarn reports whether every ClusterImagePolicy is to be in warn
+// mode, either because of a break-glass in Warn mode or because an upgrade
+// maintenance that asks for it is in progress.
+func policiesWarn(pc *v1alpha1.PolicyController) bool {
+	if bg, err := v1alpha1.ParseBreakGlass(pc); err == nil && bg.Active(time.Now()) && bg.Mode == v1alpha1.BreakGlassWarn {
+		return true
+	}
+	spec := pc.Spec.UpgradeMaintenance
+	return spec != nil && spec.Warn && meta.IsStatusConditionTrue(pc.Status.Conditions, v1alpha1.ConditionUpgradeMaintenance)
+}
+
+// applyPolicyModes switches every ClusterImagePolicy to warn mode, recording
+// its mode in the break-glass-mode annotation, or restores the recorded
+// modes.
+func applyPolicyModes(ctx context.Context, c client.Client, warn bool) error {
+	cips := &unstructured.UnstructuredList{}
+	cips.SetGroupVersionKind(ClusterImagePolicyGVK.GroupVersion().WithKind(ClusterImagePolicyGVK.Kind + "List"))
+	if err := c.List(ctx, cips); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return err
+	}
+	for i := range cips.Items {
+		cip := &cips.Items[i]
+		original, saved := cip.GetAnnotations()[constants.BreakGlassModeAnnotation]
+		mode, _, _ := unstructured.NestedString(cip.Object, "spec", "mode")
+		if warn == saved && (!warn || mode == policy.ModeWarn) {
+			continue
+		}
+
+		patch := client.MergeFromWithOptions(cip.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		annotations := cip.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		if warn {
+			if !saved {
+				annotations[constants.BreakGlassModeAnnotation] = mode
+			}
+			if err := unstructured.SetNestedField(cip.Object, policy.ModeWarn, "spec", "mode"); err != nil {
+				return err
+			}
+		} else {
+			delete(annotations, constants.BreakGlassModeAnnotation)
+			if original == "" {
+				unstructured.RemoveNestedField(cip.Object, "spec", "mode")
+			} else if err := unstructured.SetNestedField(cip.Object, original, "spec", "mode"); err != nil {
+				return err
+			}
+		}
+		cip.SetAnnotations(annotations)
+		if err := c.Patch(ctx, cip, patch); err != nil {
+			return fmt.Errorf("unable to set mode of ClusterImagePolicy %q: %w", cip.GetName(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Reasons of the Exempted condition and exception Events.
+const (
+	ReasonExempted                     = "Exempted"
+	ReasonExpired                      = "Expired"
+	ReasonPendingApproval              = "PendingApproval"
+	ReasonPolicyControllerNotFound     = "PolicyControllerNotFound"
+	ReasonUnsupportedNamespaceSelector = "UnsupportedNamespaceSelector"
+)
+
+// ExceptionReconciler exempts the namespaces of ImagePolicyExceptions from
+// image policy enforcement. While a namespace has an approved exception that
+// has not expired, the labels that make the namespaceSelector of the policy webhooks
+// select it are removed and recorded in the exempted-labels annotation. Once
+// the last exception expires or is deleted they are restored. Requests are
+// keyed by namespace.
+type ExceptionReconciler struct {
+	client.Client
+	Recorder events.EventRecorder
+}
+
+func (r *ExceptionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	byNamespace := func(_ context.Context, obj client.Object) []reconcile.Request {
+		namespace := obj.GetNamespace()
+		if _, ok := obj.(*corev1.Namespace); ok {
+			namespace = obj.GetName()
+		}
+		return []reconcile.Request{{NamespacedName: client.ObjectKey{Namespace: namespace}}}
+	}
+	exempted := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		_, ok := obj.GetAnnotations()[constants.ExemptedLabelsAnnotation]
+		return ok
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("imagepolicyexception").
+		Watches(&v1alpha1.ImagePolicyException{}, handler.EnqueueRequestsFromMapFunc(byNamespace)).
+		// Exempted namespaces are watched so that a label added back by hand
+		// is removed again, and so that labels are restored after the last
+		// exception was deleted while the operator was down.
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(byNamespace), builder.WithPredicates(exempted)).
+		Watches(&v1alpha1.PolicyController{}, r.enqueueExemptedNamespaces()).
+		Complete(r)
+}
+
+// enqueueExemptedNamespaces maps a PolicyController to every namespace with
+// an exception, as its namespaceSelector decides which labels to remove.
+func (r *ExceptionReconciler) enqueueExemptedNamespaces() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, _ client.Object) []reconcile.Request {
+		list := &v1alpha1.ImagePolicyExceptionList{}
+		if err := r.List(ctx, list); err != nil {
+			logf.FromContext(ctx).Error(err, "unable to list ImagePolicyExceptions")
+			return nil
+		}
+		seen := map[string]bool{}
+		var requests []reconcile.Request
+		for _, exception := range list.Items {
+			if !seen[exception.Namespace] {
+				seen[exception.Namespace] = true
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{Namespace: exception.Namespace}})
+			}
+		}
+		return requests
+	})
+}
+
+func (r *ExceptionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx).WithValues("namespace", req.Namespace)
+
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, client.ObjectKey{Name: req.Namespace}, ns); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	list := &v1alpha1.ImagePolicyExceptionList{}
+	if err := r.List(ctx, list, client.InNamespace(req.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	var active, expired, pending []*v1alpha1.ImagePolicyException
+	var nextExpiry time.Time
+	for i := range list.Items {
+		exception := &list.Items[i]
+		if !exception.DeletionTimestamp.IsZero() {
+			continue
+		}
+		expiresAt := exception.Spec.ExpiresAt.Time
+		if !expiresAt.After(now) {
+			expired = append(expired, exception)
+			continue
+		}
+		if nextExpiry.IsZero() || expiresAt.Before(nextExpiry) {
+			nextExpiry = expiresAt
+		}
+		if exception.Spec.Approver == "" {
+			pending = append(pending, exception)
+			continue
+		}
+		active = append(active, exception)
+	}
+
+	for _, exception := range expired {
+		if err := r.setExempted(ctx, exception, metav1.ConditionFalse, ReasonExpired,
+			fmt.Sprintf("expired at %s", exception.Spec.ExpiresAt.UTC().Format(time.RFC3339))); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	for _, exception := range pending {
+		if err := r.setExempted(ctx, exception, metav1.ConditionFalse, ReasonPendingApproval,
+			"waiting for another user to approve the exception"); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if len(active) == 0 {
+		if err := r.restore(ctx, ns, expired); err != nil {
+			return ctrl.Result{}, err
+		}
+		if len(pending) > 0 {
+			return ctrl.Result{RequeueAfter: time.Until(nextExpiry) + time.Second}, nil
+		}
+		return ctrl.Result{}, nil
+	}
+
+	status, reason, message, err := r.exempt(ctx, ns)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for _, exception := range active {
+		condMessage := message
+		if status == metav1.ConditionTrue {
+			condMessage = fmt.Sprintf("%s until %s", message, exception.Spec.ExpiresAt.UTC().Format(time.RFC3339))
+		}
+		if err := r.setExempted(ctx, exception, status, reason, condMessage); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	log.V(1).Info("namespace exempted", "until", nextExpiry)
+	return ctrl.Result{RequeueAfter: time.Until(nextExpiry) + time.Second}, nil
+}
+
+// exempt removes the labels the namespaceSelector selects the namespace by.
+func (r *ExceptionReconciler) exempt(ctx context.Context, ns *corev1.Namespace) (metav1.ConditionStatus, string, string, error) {
+	pcs := &v1alpha1.PolicyControllerList{}
+	if err := r.List(ctx, pcs, client.InNamespace(constants.PolicyControllerInstallNs)); err != nil {
+		return "", "", "", err
+	}
+	if len(pcs.Items) == 0 {
+		return metav1.ConditionFalse, ReasonPolicyControllerNotFound, "no PolicyController is installed", nil
+	}
+	declared := DeclaredNamespaceSelector(&pcs.Items[0])
+	selector, err := metav1.LabelSelectorAsSelector(&declared)
+	if err != nil {
+		return metav1.ConditionFalse, ReasonUnsupportedNamespaceSelector, fmt.Sprintf("invalid namespaceSelector: %v", err), nil
+	}
+	removed := exemptedLabels(ns)
+	if !selector.Matches(labels.Set(ns.Labels)) {
+		if len(removed) > 0 {
+			return metav1.ConditionTrue, ReasonExempted, fmt.Sprintf("removed labels %s from namespace %s", formatLabels(removed), ns.Name), nil
+		}
+		return metav1.ConditionTrue, ReasonExempted, fmt.Sprintf("namespace %s is not selected by namespaceSelector %s", ns.Name, selector), nil
+	}
+
+	remaining := labels.Set{}
+	for key, value := range ns.Labels {
+		remaining[key] = value
+	}
+	for _, key := range selectingKeys(declared) {
+		if value, ok := remaining[key]; ok {
+			removed[key] = value
+			delete(remaining, key)
+		}
+	}
+	if selector.Matches(remaining) {
+		return metav1.ConditionFalse, ReasonUnsupportedNamespaceSelector,
+			fmt.Sprintf("namespaceSelector %s still selects namespace %s without its labels", selector, ns.Name), nil
+	}
+
+	annotation, err := json.Marshal(removed)
+	if err != nil {
+		return "", "", "", err
+	}
+	patch := client.MergeFrom(ns.DeepCopy())
+	ns.Labels = remaining
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[constants.ExemptedLabelsAnnotation] = string(annotation)
+	if err := r.Patch(ctx, ns, patch); err != nil {
+		return "", "", "", err
+	}
+	r.Recorder.Eventf(ns, nil, corev1.EventTypeNormal, ReasonExempted, "Exempt", "removed labels %s", formatLabels(removed))
+	return metav1.ConditionTrue, ReasonExempted, fmt.Sprintf("removed labels %s from namespace %s", formatLabels(removed), ns.Name), nil
+}
+
+// restore puts back the labels removed from a namespace once it has no
+// active exception. Labels set again in the meantime are kept.
+func (r *ExceptionReconciler) restore(ctx context.Context, ns *corev1.Namespace, expired []*v1alpha1.ImagePolicyException) error {
+	if _, ok := ns.Annotations[constants.ExemptedLabelsAnnotation]; !ok {
+		return nil
+	}
+	removed := exemptedLabels(ns)
+	patch := client.MergeFrom(ns.DeepCopy())
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	for key, value := range removed {
+		if _, ok := ns.Labels[key]; !ok {
+			ns.Labels[key] = value
+		}
+	}
+	delete(ns.Annotations, constants.ExemptedLabelsAnnotation)
+	if err := r.Patch(ctx, ns, patch); err != nil {
+		return err
+	}
+
+	// The Event refers to the exception that expired last, if any is left.
+	var related runtime.Object
+	note := "no ImagePolicyException is left"
+	var latest *v1alpha1.ImagePolicyException
+	for _, exception := range expired {
+		if latest == nil || exception.Spec.ExpiresAt.After(latest.Spec.ExpiresAt.Time) {
+			latest = exception
+		}
+	}
+	if latest != nil {
+		related = latest
+		note = fmt.Sprintf("ImagePolicyException %s expired", latest.Name)
+	}
+	r.Recorder.Eventf(ns, related, corev1.EventTypeNormal, ReasonRestored, "Restore", "restored labels %s: %s", formatLabels(removed), note)
+	logf.FromContext(ctx).Info("image policy enforcement restored", "namespace", ns.Name, "labels", removed)
+	return nil
+}
+
+// setExempted updates the Exempted condition and emits an Event when it
+// changes.
+func (r *ExceptionReconciler) setExempted(ctx context.Context, exception *v1alpha1.ImagePolicyException, status metav1.ConditionStatus, reason, message string) error {
+	patch := client.MergeFromWithOptions(exception.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	previous := meta.FindStatusCondition(exception.Status.Conditions, v1alpha1.ConditionExempted)
+	if !meta.SetStatusCondition(&exception.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionExempted,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: exception.Generation,
+	}) {
+		return nil
+	}
+	if err := r.Status().Patch(ctx, exception, patch); err != nil {
+		if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if previous == nil || previous.Reason != reason {
+		eventType := corev1.EventTypeNormal
+		if status != metav1.ConditionTrue && reason != ReasonExpired {
+			eventType = corev1.EventTypeWarning
+		}
+		r.Recorder.Eventf(exception, nil, eventType, reason, "Exempt", "%s (reason: %s, requester: %s, approver: %s)",
+			message, exception.Spec.Reason, exception.Spec.Requester, exception.Spec.Approver)
+	}
+	return nil
+}
+
+// selectingKeys are the label keys whose removal can deselect a namespace.
+func selectingKeys(selector metav1.LabelSelector) []string {
+	var keys []string
+	for key := range selector.MatchLabels {
+		keys = append(keys, key)
+	}
+	for _, expr := range selector.MatchExpressions {
+		switch expr.Operator {
+		case metav1.LabelSelectorOpIn, metav1.LabelSelectorOpExists:
+			keys = append(keys, expr.Key)
+		}
+	}
+	return keys
+}
+
+func exemptedLabels(ns *corev1.Namespace) map[string]string {
+	removed := map[string]string{}
+	if value := ns.Annotations[constants.ExemptedLabelsAnnotation]; value != "" {
+		_ = json.Unmarshal([]byte(value), &removed)
+	}
+	return removed
+}
+
+func formatLabels(set map[string]string) string {
+	pairs := make([]string, 0, len(set))
+	for key, value := range set {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
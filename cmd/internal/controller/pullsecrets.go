@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PullSecrets reads the named image pull secrets and the ones of the
+// ServiceAccount in the namespace. Missing ServiceAccounts and Secrets are
+// skipped, as the policy webhook skips them.
+func PullSecrets(ctx context.Context, reader client.Reader, namespace, serviceAccount string, names []string) ([]corev1.Secret, error) {
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	sa := &corev1.ServiceAccount{}
+	switch err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: serviceAccount}, sa); {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return nil, err
+	default:
+		for _, ref := range sa.ImagePullSecrets {
+			names = append(names, ref.Name)
+		}
+	}
+
+	var secrets []corev1.Secret
+	for _, name := range names {
+		secret := corev1.Secret{}
+		switch err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &secret); {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			return nil, err
+		default:
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets, nil
+}
+
+// PodPullSecrets reads the image pull secrets of a Pod spec and of its
+// ServiceAccount.
+func PodPullSecrets(ctx context.Context, reader client.Reader, namespace string, spec *corev1.PodSpec) ([]corev1.Secret, error) {
+	names := make([]string, 0, len(spec.ImagePullSecrets))
+	for _, ref := range spec.ImagePullSecrets {
+		names = append(names, ref.Name)
+	}
+	return PullSecrets(ctx, reader, namespace, spec.ServiceAccountName, names)
+}
+
+// WebhookPullSecrets reads the image pull secrets of the policy webhook Pods
+// of a PolicyController and of their ServiceAccounts, which the webhook
+// falls back to when the secrets of a Pod do not authenticate it.
+func WebhookPullSecrets(ctx context.Context, reader client.Reader, pc *v1alpha1.PolicyController) ([]corev1.Secret, error) {
+	deployments := &appsv1.DeploymentList{}
+	if err := reader.List(ctx, deployments, client.InNamespace(pc.Namespace), client.MatchingLabels{constants.HelmReleaseLabel: pc.Name}); err != nil {
+		return nil, err
+	}
+	var secrets []corev1.Secret
+	for _, d := range deployments.Items {
+		webhookSecrets, err := PodPullSecrets(ctx, reader, d.Namespace, &d.Spec.Template.Spec)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, webhookSecrets...)
+	}
+	return secrets, nil
+}
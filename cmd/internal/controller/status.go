@@ -0,0 +1,212 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Reasons of the conditions written by the StatusReconciler.
+const (
+	ReasonAvailable                   = "Available"
+	ReasonDeploymentNotFound          = "DeploymentNotFound"
+	ReasonDeploymentUnavailable       = "DeploymentUnavailable"
+	ReasonWebhookConfigurationMissing = "WebhookConfigurationMissing"
+	ReasonLoaded                      = "Loaded"
+	ReasonConfigMapNotFound           = "ConfigMapNotFound"
+	ReasonNotReady                    = "NotReady"
+)
+
+var (
+	ClusterImagePolicyGVK = schema.GroupVersionKind{Group: constants.SigstorePolicyGroup, Version: constants.SigstorePolicyVersion, Kind: constants.ClusterImagePolicyKind}
+	TrustRootGVK          = schema.GroupVersionKind{Group: constants.SigstorePolicyGroup, Version: constants.SigstorePolicyVersion, Kind: constants.TrustRootKind}
+)
+
+// StatusReconciler aggregates the health of the resources rendered by the
+// policy-controller chart into conditions on the PolicyController status.
+// The helm-operator only reports whether the release was installed.
+type StatusReconciler struct {
+	client.Client
+}
+
+func (r *StatusReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	enqueueAll := enqueueAllPolicyControllers(r.Client)
+	chartConfigMaps := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetName() == constants.ImagePoliciesConfigMap || obj.GetName() == constants.SigstoreKeysConfigMap
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("policycontroller-status").
+		For(&v1alpha1.PolicyController{}).
+		Watches(&appsv1.Deployment{}, enqueueAll).
+		Watches(&corev1.ConfigMap{}, enqueueAll, builder.WithPredicates(chartConfigMaps)).
+		Watches(&admissionregistrationv1.ValidatingWebhookConfiguration{}, enqueueAll).
+		Watches(&admissionregistrationv1.MutatingWebhookConfiguration{}, enqueueAll).
+		Watches(newUnstructured(ClusterImagePolicyGVK), enqueueAll).
+		Watches(newUnstructured(TrustRootGVK), enqueueAll).
+		Complete(r)
+}
+
+func (r *StatusReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	pc := &v1alpha1.PolicyController{}
+	if err := r.Get(ctx, req.NamespacedName, pc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !pc.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	webhookAvailable, err := r.webhookAvailable(ctx, pc)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	policiesLoaded, err := r.loaded(ctx, pc.Namespace, v1alpha1.ConditionPoliciesLoaded, ClusterImagePolicyGVK, constants.ImagePoliciesConfigMap)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	trustRootsReady, err := r.loaded(ctx, pc.Namespace, v1alpha1.ConditionTrustRootsReady, TrustRootGVK, constants.SigstoreKeysConfigMap)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return setConditions(ctx, r.Client, pc, webhookAvailable, policiesLoaded, trustRootsReady)
+}
+
+// webhookAvailable checks the webhook Deployment of the helm release and the
+// webhook configurations named by cosign.webhookName.
+func (r *StatusReconciler) webhookAvailable(ctx context.Context, pc *v1alpha1.PolicyController) (metav1.Condition, error) {
+	condition := metav1.Condition{Type: v1alpha1.ConditionWebhookAvailable, Status: metav1.ConditionFalse}
+
+	deployments := &appsv1.DeploymentList{}
+	if err := r.List(ctx, deployments, client.InNamespace(pc.Namespace), client.MatchingLabels{constants.HelmReleaseLabel: pc.Name}); err != nil {
+		return condition, err
+	}
+	if len(deployments.Items) == 0 {
+		condition.Reason = ReasonDeploymentNotFound
+		condition.Message = fmt.Sprintf("no webhook Deployment found for release %q", pc.Name)
+		return condition, nil
+	}
+	for _, d := range deployments.Items {
+		if !deploymentAvailable(&d) {
+			condition.Reason = ReasonDeploymentUnavailable
+			condition.Message = fmt.Sprintf("Deployment %q is not available", d.Name)
+			return condition, nil
+		}
+	}
+
+	webhookName := policyWebhookName(pc)
+	var missing []string
+	for kind, obj := range map[string]client.Object{
+		"ValidatingWebhookConfiguration": &admissionregistrationv1.ValidatingWebhookConfiguration{},
+		"MutatingWebhookConfiguration":   &admissionregistrationv1.MutatingWebhookConfiguration{},
+	} {
+		if err := r.Get(ctx, types.NamespacedName{Name: webhookName}, obj); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return condition, err
+			}
+			missing = append(missing, kind)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		condition.Reason = ReasonWebhookConfigurationMissing
+		condition.Message = fmt.Sprintf("%s %q not found", strings.Join(missing, " and "), webhookName)
+		return condition, nil
+	}
+
+	condition.Status = metav1.ConditionTrue
+	condition.Reason = ReasonAvailable
+	condition.Message = fmt.Sprintf("webhook %q is available", webhookName)
+	return condition, nil
+}
+
+// loaded checks that every object of the given kind is Ready and that the
+// policy-controller compiled it into its ConfigMap, which is keyed by object name.
+func (r *StatusReconciler) loaded(ctx context.Context, namespace, conditionType string, gvk schema.GroupVersionKind, configMap string) (metav1.Condition, error) {
+	condition := metav1.Condition{Type: conditionType, Status: metav1.ConditionFalse}
+
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: configMap}, cm); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return condition, err
+		}
+		condition.Reason = ReasonConfigMapNotFound
+		condition.Message = fmt.Sprintf("ConfigMap %q not found", configMap)
+		return condition, nil
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := r.List(ctx, list); err != nil {
+		return condition, err
+	}
+
+	var notReady []string
+	for _, item := range list.Items {
+		if _, ok := cm.Data[item.GetName()]; !ok || !IsReady(&item) {
+			notReady = append(notReady, item.GetName())
+		}
+	}
+	if len(notReady) > 0 {
+		sort.Strings(notReady)
+		condition.Reason = ReasonNotReady
+		condition.Message = fmt.Sprintf("%d of %d %s objects are not loaded: %s", len(notReady), len(list.Items), gvk.Kind, strings.Join(notReady, ", "))
+		return condition, nil
+	}
+
+	condition.Status = metav1.ConditionTrue
+	condition.Reason = ReasonLoaded
+	condition.Message = fmt.Sprintf("%d %s objects loaded", len(list.Items), gvk.Kind)
+	return condition, nil
+}
+
+func deploymentAvailable(d *appsv1.Deployment) bool {
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentAvailable {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// IsReady reports whether the Ready condition of a policy-controller
+// resource is True.
+func IsReady(obj *unstructured.Unstructured) bool {
+	return hasTrueCondition(obj, "Ready")
+}
+
+// hasTrueCondition reports whether the status condition of the given type of
+// an unstructured object is True.
+func hasTrueCondition(obj *unstructured.Unstructured, conditionType string) bool {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		m, ok := c.(map[string]interface{})
+		if !ok || m["type"] != conditionType {
+			continue
+		}
+		return m["status"] == string(metav1.ConditionTrue)
+	}
+	return false
+}
+
+func newUnstructured(gvk schema.GroupVersionKind) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+	return u
+}
@@ -0,0 +1,242 @@
+package controller_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+func TestAuditReconciler(t *testing.T) {
+	pc := policyController()
+	c := newFakeClient(t,
+		pc,
+		namespace("enforced", map[string]string{"policy.rhtas.com/include": "true"}),
+		namespace("team", nil),
+		namespace("empty", nil),
+		staticPolicy("quay-io", "quay.io/**", policy.StaticPass),
+		pod("team", "standalone", nil, "quay.io/app:v1", "nginx"),
+		pod("team", "replica", &metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-5d8f", Controller: ptr.To(true)}, "nginx"),
+		pod("team", "operand", &metav1.OwnerReference{APIVersion: "example.com/v1", Kind: "Database", Name: "db", Controller: ptr.To(true)}, "quay.io/db:v1"),
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "team",
+				Name:            "web-5d8f",
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Controller: ptr.To(true)}},
+			},
+			Spec: appsv1.ReplicaSetSpec{Template: podTemplate("nginx")},
+		},
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "team",
+				Name:            "canary-6c9f",
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "argoproj.io/v1alpha1", Kind: "Rollout", Name: "canary", Controller: ptr.To(true)}},
+			},
+			Spec: appsv1.ReplicaSetSpec{Template: podTemplate("quay.io/canary:v2")},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "enforced", Name: "web"},
+			Spec:       appsv1.DeploymentSpec{Template: podTemplate("quay.io/web:v1")},
+		},
+		&batchv1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "nightly"},
+			Spec:       batchv1.CronJobSpec{JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: podTemplate("quay.io/batch:v1")}}},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "team",
+				Name:            "nightly-29000000",
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "CronJob", Name: "nightly", Controller: ptr.To(true)}},
+			},
+			Spec: batchv1.JobSpec{Template: podTemplate("quay.io/batch:v1")},
+		},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Namespace: constants.PolicyControllerInstallNs,
+			Name:      controller.AuditReportName("deleted"),
+			Labels:    map[string]string{constants.AuditNamespaceLabel: "deleted"},
+		}},
+	)
+	reconciler := &controller.AuditReconciler{Client: c, APIReader: c}
+
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)})
+	require.NoError(t, err)
+
+	report := func(name string) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{}
+		err := c.Get(context.Background(), client.ObjectKey{Namespace: constants.PolicyControllerInstallNs, Name: name}, cm)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		require.NoError(t, err)
+		return cm
+	}
+	require.Nil(t, report(controller.AuditReportName("empty")))
+	require.Nil(t, report(controller.AuditReportName("deleted")))
+
+	summaryReport := report(constants.AuditReportConfigMap)
+	require.Equal(t, pc.Name, summaryReport.OwnerReferences[0].Name)
+	summary := controller.AuditSummary{}
+	require.NoError(t, yaml.Unmarshal([]byte(summaryReport.Data[controller.AuditSummaryKey]), &summary))
+	require.Equal(t, 1, summary.Policies)
+	require.Equal(t, 2, summary.Namespaces)
+	require.Equal(t, map[policy.Verdict]int{policy.Allowed: 4, policy.Rejected: 1}, summary.Workloads)
+
+	teamReport := report(controller.AuditReportName("team"))
+	require.Equal(t, "team", teamReport.Labels[constants.AuditNamespaceLabel])
+	require.Equal(t, pc.Name, teamReport.OwnerReferences[0].Name)
+	team := controller.NamespaceAudit{}
+	require.NoError(t, yaml.Unmarshal([]byte(teamReport.Data[controller.AuditReportKey]), &team))
+	require.False(t, team.Enforced)
+	require.Len(t, team.Workloads, 4)
+	require.Equal(t, "CronJob", team.Workloads[0].Kind)
+	require.Equal(t, policy.Allowed, team.Workloads[0].Verdict)
+	// Pods and ReplicaSets of controllers the audit does not list are
+	// audited on their own.
+	require.Equal(t, "Pod", team.Workloads[1].Kind)
+	require.Equal(t, "operand", team.Workloads[1].Name)
+	require.Equal(t, "Pod", team.Workloads[2].Kind)
+	require.Equal(t, "standalone", team.Workloads[2].Name)
+	require.Equal(t, policy.Rejected, team.Workloads[2].Verdict)
+	require.Len(t, team.Workloads[2].Images, 2)
+	require.Equal(t, "ReplicaSet", team.Workloads[3].Kind)
+	require.Equal(t, "canary-6c9f", team.Workloads[3].Name)
+
+	enforced := controller.NamespaceAudit{}
+	require.NoError(t, yaml.Unmarshal([]byte(report(controller.AuditReportName("enforced")).Data[controller.AuditReportKey]), &enforced))
+	require.True(t, enforced.Enforced)
+}
+
+func TestAuditReconcilerPullSecrets(t *testing.T) {
+	server := httptest.NewServer(basicAuth("puller", "secret", registry.New()))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(host + "/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img, remote.WithAuth(&authn.Basic{Username: "puller", Password: "secret"})))
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	keyPolicy := policyResource(controller.ClusterImagePolicyGVK, "private", "True")
+	require.NoError(t, unstructured.SetNestedField(keyPolicy.Object, map[string]interface{}{
+		"images": []interface{}{map[string]interface{}{"glob": host + "/**"}},
+		"authorities": []interface{}{map[string]interface{}{"key": map[string]interface{}{
+			"data": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		}}},
+	}, "spec"))
+
+	authenticated := pod("team", "authenticated", nil, host+"/app:v1")
+	authenticated.Spec.ServiceAccountName = "builder"
+	pc := policyController()
+	c := newFakeClient(t,
+		pc,
+		namespace("team", nil),
+		keyPolicy,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "registry"},
+			Type:       corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte(fmt.Sprintf(`{"auths":{%q:{"username":"puller","password":"secret"}}}`, host)),
+			},
+		},
+		&corev1.ServiceAccount{
+			ObjectMeta:       metav1.ObjectMeta{Namespace: "team", Name: "builder"},
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+		},
+		pod("team", "anonymous", nil, host+"/app:v1"),
+		authenticated,
+	)
+	reconciler := &controller.AuditReconciler{Client: c, APIReader: c}
+	_, err = reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)})
+	require.NoError(t, err)
+
+	cm := &corev1.ConfigMap{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: constants.PolicyControllerInstallNs, Name: controller.AuditReportName("team")}, cm))
+	team := controller.NamespaceAudit{}
+	require.NoError(t, yaml.Unmarshal([]byte(cm.Data[controller.AuditReportKey]), &team))
+	require.Len(t, team.Workloads, 2)
+	// Without credentials the registry denies access, which says nothing
+	// about the signatures of the image.
+	require.Equal(t, "anonymous", team.Workloads[0].Name)
+	require.Equal(t, policy.Unknown, team.Workloads[0].Verdict)
+	// The pull secret of the ServiceAccount authenticates the pull, so the
+	// missing signature is found.
+	require.Equal(t, "authenticated", team.Workloads[1].Name)
+	require.Equal(t, policy.Rejected, team.Workloads[1].Verdict, team.Workloads[1].Images)
+	require.Contains(t, team.Workloads[1].Images[0].Messages[0], "no signatures found")
+}
+
+// basicAuth serves handler to clients that authenticate as user.
+func basicAuth(user, password string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != user || p != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func namespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func podTemplate(images ...string) corev1.PodTemplateSpec {
+	template := corev1.PodTemplateSpec{}
+	for i, image := range images {
+		template.Spec.Containers = append(template.Spec.Containers, corev1.Container{Name: fmt.Sprintf("container-%d", i), Image: image})
+	}
+	return template
+}
+
+func pod(ns, name string, owner *metav1.OwnerReference, images ...string) *corev1.Pod {
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Spec:       podTemplate(images...).Spec,
+	}
+	if owner != nil {
+		p.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	return p
+}
+
+func staticPolicy(name, glob, action string) *unstructured.Unstructured {
+	u := policyResource(controller.ClusterImagePolicyGVK, name, "True")
+	_ = unstructured.SetNestedField(u.Object, map[string]interface{}{
+		"images":      []interface{}{map[string]interface{}{"glob": glob}},
+		"authorities": []interface{}{map[string]interface{}{"static": map[string]interface{}{"action": action}}},
+	}, "spec")
+	return u
+}
@@ -0,0 +1,156 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestBreakGlassReconciler(t *testing.T) {
+	ctx := context.Background()
+	pc := policyController()
+	pc.Annotations = map[string]string{v1alpha1.BreakGlassAnnotation: breakGlass(t, v1alpha1.BreakGlassWarn, time.Hour)}
+	pc.Spec.PolicyController.Webhook.FailurePolicy = ptr.To(admissionregistrationv1.Fail)
+	enforced := policyResource(controller.ClusterImagePolicyGVK, "enforced", "True")
+	require.NoError(t, unstructured.SetNestedField(enforced.Object, "enforce", "spec", "mode"))
+	defaulted := policyResource(controller.ClusterImagePolicyGVK, "defaulted", "True")
+	c := newFakeClient(t,
+		pc,
+		enforced,
+		defaulted,
+		validatingWebhookConfiguration(admissionregistrationv1.Fail, declaredSelector()),
+		mutatingWebhookConfiguration(admissionregistrationv1.Fail, declaredSelector()),
+	)
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &controller.BreakGlassReconciler{Client: c, Recorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)}
+
+	result, err := reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Greater(t, result.RequeueAfter, 59*time.Minute)
+	requireFailurePolicyValue(t, c, pc, admissionregistrationv1.Ignore, ptr.To("Fail"))
+	// The helm operator renders the value into the webhook configurations,
+	// which are not patched directly.
+	requireFailurePolicy(t, c, admissionregistrationv1.Fail)
+	requirePolicyMode(t, c, "enforced", "warn", "enforce")
+	requirePolicyMode(t, c, "defaulted", "warn", "")
+	condition := breakGlassCondition(t, c, pc)
+	require.Equal(t, metav1.ConditionTrue, condition.Status)
+	require.Equal(t, controller.ReasonBreakGlassActivated, condition.Reason)
+	require.Contains(t, condition.Message, "Warn mode requested by oncall@example.com")
+	require.Contains(t, condition.Message, "INC-42")
+	require.Contains(t, condition.Message, "revert them to enforce unless syncing is paused")
+	require.Len(t, recorder.Events, 1)
+	require.Contains(t, <-recorder.Events, "Warning Activated image policy enforcement suspended")
+
+	// The drift reconciler declares the overridden failurePolicy in the
+	// meantime.
+	drift := &controller.WebhookDriftReconciler{Client: c, Recorder: events.NewFakeRecorder(10)}
+	_, err = drift.Reconcile(ctx, req)
+	require.NoError(t, err)
+	requireFailurePolicy(t, c, admissionregistrationv1.Ignore)
+
+	updated := &v1alpha1.PolicyController{}
+	require.NoError(t, c.Get(ctx, req.NamespacedName, updated))
+	updated.Annotations[v1alpha1.BreakGlassAnnotation] = breakGlass(t, v1alpha1.BreakGlassWarn, -time.Minute)
+	require.NoError(t, c.Update(ctx, updated))
+
+	result, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Zero(t, result.RequeueAfter)
+	requireFailurePolicyValue(t, c, pc, admissionregistrationv1.Fail, nil)
+	requirePolicyMode(t, c, "enforced", "enforce", "")
+	requirePolicyMode(t, c, "defaulted", "", "")
+	condition = breakGlassCondition(t, c, pc)
+	require.Equal(t, metav1.ConditionFalse, condition.Status)
+	require.Equal(t, controller.ReasonExpired, condition.Reason)
+	require.Contains(t, condition.Message, "INC-42")
+	require.Contains(t, <-recorder.Events, "Normal Restored image policy enforcement restored")
+
+	require.NoError(t, c.Get(ctx, req.NamespacedName, updated))
+	require.NotContains(t, updated.Annotations, v1alpha1.BreakGlassAnnotation)
+
+	// Without a break-glass the declared failurePolicy is left to the drift
+	// reconciler.
+	_, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Empty(t, recorder.Events)
+}
+
+func breakGlass(t *testing.T, mode v1alpha1.BreakGlassMode, expiresIn time.Duration) string {
+	value, err := json.Marshal(v1alpha1.BreakGlass{
+		Mode:        mode,
+		Reason:      "INC-42: Fulcio root rotation broke verification",
+		RequestedBy: "oncall@example.com",
+		ExpiresAt:   metav1.NewTime(time.Now().Add(expiresIn)),
+	})
+	require.NoError(t, err)
+	return string(value)
+}
+
+func requireFailurePolicy(t *testing.T, c client.Client, expected admissionregistrationv1.FailurePolicyType) {
+	t.Helper()
+	vwc := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: constants.DefaultWebhookName}, vwc))
+	require.Equal(t, expected, *vwc.Webhooks[0].FailurePolicy)
+	mwc := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: constants.DefaultWebhookName}, mwc))
+	require.Equal(t, expected, *mwc.Webhooks[0].FailurePolicy)
+}
+
+// requireFailurePolicyValue checks webhook.failurePolicy of the
+// PolicyController and the configured value recorded while it is overridden,
+// if any.
+func requireFailurePolicyValue(t *testing.T, c client.Client, pc *v1alpha1.PolicyController, expected admissionregistrationv1.FailurePolicyType, declared *string) {
+	t.Helper()
+	updated := &v1alpha1.PolicyController{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(pc), updated))
+	if expected == "" {
+		require.Nil(t, updated.Spec.PolicyController.Webhook.FailurePolicy)
+	} else {
+		require.Equal(t, expected, *updated.Spec.PolicyController.Webhook.FailurePolicy)
+	}
+	original, ok := updated.Annotations[constants.DeclaredFailurePolicyAnnotation]
+	require.Equal(t, declared != nil, ok)
+	applied, ok := updated.Annotations[constants.AppliedFailurePolicyAnnotation]
+	require.Equal(t, declared != nil, ok)
+	if declared != nil {
+		require.Equal(t, *declared, original)
+		require.Equal(t, string(expected), applied)
+	}
+}
+
+func requirePolicyMode(t *testing.T, c client.Client, name, mode, saved string) {
+	t.Helper()
+	cip := &unstructured.Unstructured{}
+	cip.SetGroupVersionKind(controller.ClusterImagePolicyGVK)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: name}, cip))
+	actual, _, _ := unstructured.NestedString(cip.Object, "spec", "mode")
+	require.Equal(t, mode, actual)
+	original, ok := cip.GetAnnotations()[constants.BreakGlassModeAnnotation]
+	require.Equal(t, mode == "warn", ok)
+	require.Equal(t, saved, original)
+}
+
+func breakGlassCondition(t *testing.T, c client.Client, pc *v1alpha1.PolicyController) *metav1.Condition {
+	t.Helper()
+	updated := &v1alpha1.PolicyController{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(pc), updated))
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionBreakGlass)
+	require.NotNil(t, condition)
+	return condition
+}
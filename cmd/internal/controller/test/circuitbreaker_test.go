@@ -0,0 +1,154 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestCircuitBreakerReconciler(t *testing.T) {
+	ctx := context.Background()
+	pc := policyController()
+	pc.Spec.CircuitBreaker = &v1alpha1.CircuitBreakerSpec{GracePeriod: &metav1.Duration{Duration: 5 * time.Minute}}
+	c := newFakeClient(t,
+		pc,
+		unavailableDeployment(10*time.Minute),
+		validatingWebhookConfiguration(admissionregistrationv1.Fail, declaredSelector()),
+		mutatingWebhookConfiguration(admissionregistrationv1.Fail, declaredSelector()),
+	)
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &controller.CircuitBreakerReconciler{Client: c, Recorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)}
+
+	_, err := reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	requireFailurePolicyValue(t, c, pc, admissionregistrationv1.Ignore, ptr.To(""))
+	requireFailurePolicy(t, c, admissionregistrationv1.Fail)
+	condition := circuitBreakerCondition(t, c, pc)
+	require.Equal(t, metav1.ConditionTrue, condition.Status)
+	require.Equal(t, controller.ReasonWebhookUnavailable, condition.Reason)
+	require.Contains(t, <-recorder.Events, "Warning WebhookUnavailable")
+
+	// The drift reconciler declares the overridden failurePolicy while the
+	// circuit breaker is open.
+	drift := &controller.WebhookDriftReconciler{Client: c, Recorder: events.NewFakeRecorder(10)}
+	_, err = drift.Reconcile(ctx, req)
+	require.NoError(t, err)
+	requireFailurePolicy(t, c, admissionregistrationv1.Ignore)
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(webhookDeployment(corev1.ConditionTrue)), deployment))
+	deployment.Status.Conditions[0].Status = corev1.ConditionTrue
+	require.NoError(t, c.Status().Update(ctx, deployment))
+
+	_, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	requireFailurePolicyValue(t, c, pc, "", nil)
+	condition = circuitBreakerCondition(t, c, pc)
+	require.Equal(t, metav1.ConditionFalse, condition.Status)
+	require.Equal(t, controller.ReasonWebhookHealthy, condition.Reason)
+	require.Contains(t, <-recorder.Events, "Normal Restored")
+}
+
+// TestCircuitBreakerReconcilerDeclaredEdit checks that a failurePolicy set
+// while the circuit breaker is open is restored once it closes.
+func TestCircuitBreakerReconcilerDeclaredEdit(t *testing.T) {
+	ctx := context.Background()
+	pc := policyController()
+	pc.Spec.CircuitBreaker = &v1alpha1.CircuitBreakerSpec{}
+	c := newFakeClient(t, pc, unavailableDeployment(10*time.Minute))
+	reconciler := &controller.CircuitBreakerReconciler{Client: c, Recorder: events.NewFakeRecorder(10)}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)}
+
+	_, err := reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	requireFailurePolicyValue(t, c, pc, admissionregistrationv1.Ignore, ptr.To(""))
+
+	updated := &v1alpha1.PolicyController{}
+	require.NoError(t, c.Get(ctx, req.NamespacedName, updated))
+	updated.Spec.PolicyController.Webhook.FailurePolicy = ptr.To(admissionregistrationv1.Fail)
+	require.NoError(t, c.Update(ctx, updated))
+
+	_, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	requireFailurePolicyValue(t, c, pc, admissionregistrationv1.Ignore, ptr.To("Fail"))
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(webhookDeployment(corev1.ConditionTrue)), deployment))
+	deployment.Status.Conditions[0].Status = corev1.ConditionTrue
+	require.NoError(t, c.Status().Update(ctx, deployment))
+
+	_, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	requireFailurePolicyValue(t, c, pc, admissionregistrationv1.Fail, nil)
+}
+
+func TestCircuitBreakerReconcilerGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	pc := policyController()
+	pc.Spec.CircuitBreaker = &v1alpha1.CircuitBreakerSpec{}
+	c := newFakeClient(t,
+		pc,
+		unavailableDeployment(time.Minute),
+		validatingWebhookConfiguration(admissionregistrationv1.Fail, declaredSelector()),
+		mutatingWebhookConfiguration(admissionregistrationv1.Fail, declaredSelector()),
+	)
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &controller.CircuitBreakerReconciler{Client: c, Recorder: recorder}
+
+	result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)})
+	require.NoError(t, err)
+	require.InDelta(t, 4*time.Minute, result.RequeueAfter, float64(5*time.Second))
+	requireFailurePolicyValue(t, c, pc, "", nil)
+	condition := circuitBreakerCondition(t, c, pc)
+	require.Equal(t, metav1.ConditionFalse, condition.Status)
+	require.Equal(t, controller.ReasonWithinGracePeriod, condition.Reason)
+	require.Empty(t, recorder.Events)
+}
+
+func TestCircuitBreakerReconcilerDisabled(t *testing.T) {
+	ctx := context.Background()
+	pc := policyController()
+	c := newFakeClient(t,
+		pc,
+		unavailableDeployment(time.Hour),
+		validatingWebhookConfiguration(admissionregistrationv1.Fail, declaredSelector()),
+		mutatingWebhookConfiguration(admissionregistrationv1.Fail, declaredSelector()),
+	)
+	reconciler := &controller.CircuitBreakerReconciler{Client: c, Recorder: events.NewFakeRecorder(10)}
+
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)})
+	require.NoError(t, err)
+	requireFailurePolicyValue(t, c, pc, "", nil)
+	updated := &v1alpha1.PolicyController{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(pc), updated))
+	require.Nil(t, meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionCircuitBreakerOpen))
+}
+
+func unavailableDeployment(since time.Duration) *appsv1.Deployment {
+	d := webhookDeployment(corev1.ConditionFalse)
+	d.Status.Conditions[0].LastTransitionTime = metav1.NewTime(time.Now().Add(-since))
+	return d
+}
+
+func circuitBreakerCondition(t *testing.T, c client.Client, pc *v1alpha1.PolicyController) *metav1.Condition {
+	t.Helper()
+	updated := &v1alpha1.PolicyController{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(pc), updated))
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionCircuitBreakerOpen)
+	require.NotNil(t, condition)
+	return condition
+}
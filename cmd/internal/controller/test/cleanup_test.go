@@ -0,0 +1,99 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestCleanupReconcilerAddsFinalizer(t *testing.T) {
+	pc := policyController()
+	c := newFakeClient(t, pc)
+	reconciler := &controller.CleanupReconciler{Client: c, APIReader: c}
+
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)})
+	require.NoError(t, err)
+
+	updated := &v1alpha1.PolicyController{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(pc), updated))
+	require.Contains(t, updated.Finalizers, controller.CleanupFinalizer)
+}
+
+func TestCleanupReconcilerWaitsForUninstall(t *testing.T) {
+	pc := deletedPolicyController(controller.HelmUninstallFinalizer, controller.CleanupFinalizer)
+	c := newFakeClient(t, pc, lease(webhookLease))
+	reconciler := &controller.CleanupReconciler{Client: c, APIReader: c}
+
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)})
+	require.NoError(t, err)
+
+	updated := &v1alpha1.PolicyController{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(pc), updated))
+	require.Contains(t, updated.Finalizers, controller.CleanupFinalizer)
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionCleanupComplete)
+	require.NotNil(t, condition)
+	require.Equal(t, controller.ReasonWaitingForUninstall, condition.Reason)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: constants.PolicyControllerInstallNs, Name: webhookLease}, &coordinationv1.Lease{}))
+}
+
+func TestCleanupReconcilerRemovesLeftovers(t *testing.T) {
+	pc := deletedPolicyController(controller.CleanupFinalizer)
+	c := newFakeClient(t,
+		pc,
+		lease(webhookLease),
+		lease("policy-controller.github.com-sigstore-policy-controller-pkg-reconciler-trustroot.reconciler.00-of-01"),
+		lease(constants.OperatorLeaderElectionID),
+		lease("cert-manager-controller"),
+		lease(constants.WebhookControllerLeaderElectionID),
+		validatingWebhookConfiguration(admissionregistrationv1.Fail, declaredSelector()),
+		mutatingWebhookConfiguration(admissionregistrationv1.Fail, declaredSelector()),
+		configMap(constants.ImagePoliciesConfigMap),
+	)
+	reconciler := &controller.CleanupReconciler{Client: c, APIReader: c}
+
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)})
+	require.NoError(t, err)
+
+	err = c.Get(context.Background(), client.ObjectKeyFromObject(pc), &v1alpha1.PolicyController{})
+	require.True(t, apierrors.IsNotFound(err), "PolicyController should be released once cleaned up")
+
+	leases := &coordinationv1.LeaseList{}
+	require.NoError(t, c.List(context.Background(), leases, client.InNamespace(constants.PolicyControllerInstallNs)))
+	var names []string
+	for _, l := range leases.Items {
+		names = append(names, l.Name)
+	}
+	// Leases of other components in the namespace are kept.
+	require.ElementsMatch(t, []string{constants.OperatorLeaderElectionID, constants.WebhookControllerLeaderElectionID, "cert-manager-controller"}, names)
+
+	err = c.Get(context.Background(), client.ObjectKey{Name: constants.DefaultWebhookName}, &admissionregistrationv1.ValidatingWebhookConfiguration{})
+	require.True(t, apierrors.IsNotFound(err))
+	err = c.Get(context.Background(), client.ObjectKey{Namespace: constants.PolicyControllerInstallNs, Name: constants.ImagePoliciesConfigMap}, configMap(""))
+	require.True(t, apierrors.IsNotFound(err))
+}
+
+// webhookLease is a leader election lease of the policy-controller webhook.
+const webhookLease = "policy-controller.webhook.webhookcertificates.00-of-01"
+
+func deletedPolicyController(finalizers ...string) *v1alpha1.PolicyController {
+	pc := policyController()
+	now := metav1.Now()
+	pc.DeletionTimestamp = &now
+	pc.Finalizers = finalizers
+	return pc
+}
+
+func lease(name string) *coordinationv1.Lease {
+	return &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: constants.PolicyControllerInstallNs, Name: name}}
+}
@@ -0,0 +1,152 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestExceptionReconciler(t *testing.T) {
+	ctx := context.Background()
+	include := map[string]string{"policy.rhtas.com/include": "true", "team": "a"}
+	c := newFakeClient(t,
+		policyController(),
+		namespace("team", include),
+		imagePolicyException("team", "incident", time.Hour),
+		imagePolicyException("team", "previous", -time.Hour),
+	)
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &controller.ExceptionReconciler{Client: c, Recorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "team"}}
+
+	result, err := reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.InDelta(t, time.Hour, result.RequeueAfter, float64(time.Minute))
+
+	ns := &corev1.Namespace{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "team"}, ns))
+	require.Equal(t, map[string]string{"team": "a"}, ns.Labels)
+	require.JSONEq(t, `{"policy.rhtas.com/include":"true"}`, ns.Annotations[constants.ExemptedLabelsAnnotation])
+	require.Equal(t, metav1.ConditionTrue, exempted(t, c, "team", "incident").Status)
+	require.Contains(t, exempted(t, c, "team", "incident").Message, "removed labels policy.rhtas.com/include=true from namespace team until")
+	require.Equal(t, controller.ReasonExpired, exempted(t, c, "team", "previous").Reason)
+	require.Len(t, recorder.Events, 3)
+
+	// A label added back by hand is removed again.
+	ns.Labels["policy.rhtas.com/include"] = "true"
+	require.NoError(t, c.Update(ctx, ns))
+	_, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "team"}, ns))
+	require.NotContains(t, ns.Labels, "policy.rhtas.com/include")
+
+	require.NoError(t, c.Delete(ctx, &v1alpha1.ImagePolicyException{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "incident"}}))
+	result, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Zero(t, result.RequeueAfter)
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "team"}, ns))
+	require.Equal(t, include, ns.Labels)
+	require.NotContains(t, ns.Annotations, constants.ExemptedLabelsAnnotation)
+
+	var last string
+	for len(recorder.Events) > 0 {
+		last = <-recorder.Events
+	}
+	require.Contains(t, last, "Restored restored labels policy.rhtas.com/include=true: ImagePolicyException previous expired")
+}
+
+func TestExceptionReconcilerPendingApproval(t *testing.T) {
+	ctx := context.Background()
+	pending := imagePolicyException("team", "incident", time.Hour)
+	pending.Spec.Approver = ""
+	c := newFakeClient(t, policyController(), namespace("team", map[string]string{"policy.rhtas.com/include": "true"}), pending)
+	reconciler := &controller.ExceptionReconciler{Client: c, Recorder: events.NewFakeRecorder(10)}
+	req := ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "team"}}
+
+	result, err := reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.InDelta(t, time.Hour, result.RequeueAfter, float64(time.Minute))
+	require.Equal(t, controller.ReasonPendingApproval, exempted(t, c, "team", "incident").Reason)
+	ns := &corev1.Namespace{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "team"}, ns))
+	require.Contains(t, ns.Labels, "policy.rhtas.com/include")
+
+	// Once approved, the namespace is exempted.
+	exception := &v1alpha1.ImagePolicyException{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "team", Name: "incident"}, exception))
+	exception.Spec.Approver = "asmith@example.com"
+	require.NoError(t, c.Update(ctx, exception))
+	_, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, metav1.ConditionTrue, exempted(t, c, "team", "incident").Status)
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "team"}, ns))
+	require.NotContains(t, ns.Labels, "policy.rhtas.com/include")
+}
+
+func TestExceptionReconcilerCannotExempt(t *testing.T) {
+	ctx := context.Background()
+	notIn := policyController()
+	notIn.Spec.PolicyController.Webhook.NamespaceSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "policy.rhtas.com/exclude", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"true"}}},
+	}
+
+	tests := []struct {
+		name    string
+		objects []client.Object
+		reason  string
+	}{
+		{name: "no PolicyController", reason: controller.ReasonPolicyControllerNotFound},
+		{name: "NotIn selector", objects: []client.Object{notIn}, reason: controller.ReasonUnsupportedNamespaceSelector},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeClient(t, append(tt.objects,
+				namespace("team", map[string]string{"policy.rhtas.com/include": "true"}),
+				imagePolicyException("team", "incident", time.Hour),
+			)...)
+			reconciler := &controller.ExceptionReconciler{Client: c, Recorder: events.NewFakeRecorder(10)}
+
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "team"}})
+			require.NoError(t, err)
+			condition := exempted(t, c, "team", "incident")
+			require.Equal(t, metav1.ConditionFalse, condition.Status)
+			require.Equal(t, tt.reason, condition.Reason)
+
+			ns := &corev1.Namespace{}
+			require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "team"}, ns))
+			require.Contains(t, ns.Labels, "policy.rhtas.com/include")
+		})
+	}
+}
+
+func imagePolicyException(namespace, name string, expiresIn time.Duration) *v1alpha1.ImagePolicyException {
+	return &v1alpha1.ImagePolicyException{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: v1alpha1.ImagePolicyExceptionSpec{
+			Reason:    "INC-1234",
+			Requester: "jdoe@example.com",
+			Approver:  "asmith@example.com",
+			ExpiresAt: metav1.NewTime(time.Now().Add(expiresIn)),
+		},
+	}
+}
+
+func exempted(t *testing.T, c client.Client, namespace, name string) *metav1.Condition {
+	t.Helper()
+	exception := &v1alpha1.ImagePolicyException{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: name}, exception))
+	condition := meta.FindStatusCondition(exception.Status.Conditions, v1alpha1.ConditionExempted)
+	require.NotNil(t, condition)
+	return condition
+}
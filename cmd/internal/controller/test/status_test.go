@@ -0,0 +1,186 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestStatusReconciler(t *testing.T) {
+	tests := []struct {
+		name     string
+		objects  []client.Object
+		expected map[string]metav1.ConditionStatus
+		reasons  map[string]string
+	}{
+		{
+			name:    "nothing deployed yet",
+			objects: nil,
+			expected: map[string]metav1.ConditionStatus{
+				v1alpha1.ConditionWebhookAvailable: metav1.ConditionFalse,
+				v1alpha1.ConditionPoliciesLoaded:   metav1.ConditionFalse,
+				v1alpha1.ConditionTrustRootsReady:  metav1.ConditionFalse,
+			},
+			reasons: map[string]string{
+				v1alpha1.ConditionWebhookAvailable: controller.ReasonDeploymentNotFound,
+				v1alpha1.ConditionPoliciesLoaded:   controller.ReasonConfigMapNotFound,
+				v1alpha1.ConditionTrustRootsReady:  controller.ReasonConfigMapNotFound,
+			},
+		},
+		{
+			name: "healthy release",
+			objects: []client.Object{
+				webhookDeployment(corev1.ConditionTrue),
+				&admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: constants.DefaultWebhookName}},
+				&admissionregistrationv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: constants.DefaultWebhookName}},
+				configMap(constants.ImagePoliciesConfigMap, "image-policy"),
+				configMap(constants.SigstoreKeysConfigMap, "trust-root"),
+				policyResource(controller.ClusterImagePolicyGVK, "image-policy", "True"),
+				policyResource(controller.TrustRootGVK, "trust-root", "True"),
+			},
+			expected: map[string]metav1.ConditionStatus{
+				v1alpha1.ConditionWebhookAvailable: metav1.ConditionTrue,
+				v1alpha1.ConditionPoliciesLoaded:   metav1.ConditionTrue,
+				v1alpha1.ConditionTrustRootsReady:  metav1.ConditionTrue,
+			},
+		},
+		{
+			name: "degraded release",
+			objects: []client.Object{
+				webhookDeployment(corev1.ConditionTrue),
+				&admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: constants.DefaultWebhookName}},
+				configMap(constants.ImagePoliciesConfigMap),
+				configMap(constants.SigstoreKeysConfigMap, "trust-root"),
+				policyResource(controller.ClusterImagePolicyGVK, "image-policy", "True"),
+				policyResource(controller.TrustRootGVK, "trust-root", "False"),
+			},
+			expected: map[string]metav1.ConditionStatus{
+				v1alpha1.ConditionWebhookAvailable: metav1.ConditionFalse,
+				v1alpha1.ConditionPoliciesLoaded:   metav1.ConditionFalse,
+				v1alpha1.ConditionTrustRootsReady:  metav1.ConditionFalse,
+			},
+			reasons: map[string]string{
+				v1alpha1.ConditionWebhookAvailable: controller.ReasonWebhookConfigurationMissing,
+				v1alpha1.ConditionPoliciesLoaded:   controller.ReasonNotReady,
+				v1alpha1.ConditionTrustRootsReady:  controller.ReasonNotReady,
+			},
+		},
+		{
+			name: "unavailable deployment",
+			objects: []client.Object{
+				webhookDeployment(corev1.ConditionFalse),
+			},
+			expected: map[string]metav1.ConditionStatus{
+				v1alpha1.ConditionWebhookAvailable: metav1.ConditionFalse,
+			},
+			reasons: map[string]string{
+				v1alpha1.ConditionWebhookAvailable: controller.ReasonDeploymentUnavailable,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pc := policyController()
+			c := newFakeClient(t, append(tc.objects, pc)...)
+			reconciler := &controller.StatusReconciler{Client: c}
+
+			_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)})
+			require.NoError(t, err)
+
+			updated := &v1alpha1.PolicyController{}
+			require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(pc), updated))
+
+			deployed := meta.FindStatusCondition(updated.Status.Conditions, "Deployed")
+			require.NotNil(t, deployed, "helm-operator conditions must be preserved")
+
+			for conditionType, status := range tc.expected {
+				condition := meta.FindStatusCondition(updated.Status.Conditions, conditionType)
+				require.NotNil(t, condition, conditionType)
+				require.Equal(t, status, condition.Status, condition.Message)
+				if reason, ok := tc.reasons[conditionType]; ok {
+					require.Equal(t, reason, condition.Reason, condition.Message)
+				}
+			}
+		})
+	}
+}
+
+func newFakeClient(t *testing.T, objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	for _, gvk := range []schema.GroupVersionKind{controller.ClusterImagePolicyGVK, controller.TrustRootGVK, controller.ClusterVersionGVK, controller.MachineConfigPoolGVK} {
+		scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+	}
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(&v1alpha1.PolicyController{}, &v1alpha1.ImagePolicyException{}).
+		Build()
+}
+
+func policyController() *v1alpha1.PolicyController {
+	return &v1alpha1.PolicyController{
+		ObjectMeta: metav1.ObjectMeta{Name: "policycontroller-sample", Namespace: constants.PolicyControllerInstallNs},
+		Status: v1alpha1.PolicyControllerStatus{
+			Conditions: []metav1.Condition{{
+				Type:               "Deployed",
+				Status:             metav1.ConditionTrue,
+				Reason:             "InstallSuccessful",
+				LastTransitionTime: metav1.Now(),
+			}},
+		},
+	}
+}
+
+func webhookDeployment(available corev1.ConditionStatus) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "policycontroller-sample-policy-controller-webhook",
+			Namespace: constants.PolicyControllerInstallNs,
+			Labels:    map[string]string{constants.HelmReleaseLabel: "policycontroller-sample"},
+		},
+		Status: appsv1.DeploymentStatus{
+			Conditions: []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable, Status: available}},
+		},
+	}
+}
+
+func configMap(name string, keys ...string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: constants.PolicyControllerInstallNs},
+		Data:       map[string]string{},
+	}
+	for _, key := range keys {
+		cm.Data[key] = "{}"
+	}
+	return cm
+}
+
+func policyResource(gvk schema.GroupVersionKind, name, ready string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+	u.SetName(name)
+	_ = unstructured.SetNestedSlice(u.Object, []interface{}{
+		map[string]interface{}{"type": "Ready", "status": ready},
+	}, "status", "conditions")
+	return u
+}
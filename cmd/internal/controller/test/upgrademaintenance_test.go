@@ -0,0 +1,200 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestUpgradeMaintenanceReconciler(t *testing.T) {
+	ctx := context.Background()
+	pc := policyController()
+	pc.Spec.UpgradeMaintenance = &v1alpha1.UpgradeMaintenanceSpec{
+		Warn:           true,
+		FailurePolicy:  ptr.To(admissionregistrationv1.Ignore),
+		TimeoutSeconds: ptr.To(int32(30)),
+		ReplicaCount:   ptr.To(int32(3)),
+	}
+	enforced := policyResource(controller.ClusterImagePolicyGVK, "enforced", "True")
+	require.NoError(t, unstructured.SetNestedField(enforced.Object, "enforce", "spec", "mode"))
+	clusterVersion := openShiftResource(controller.ClusterVersionGVK, "version", "Progressing", "True")
+	require.NoError(t, unstructured.SetNestedField(clusterVersion.Object, "4.18.1", "status", "desired", "version"))
+	pool := openShiftResource(controller.MachineConfigPoolGVK, "worker", "Updating", "False")
+	c := newFakeClient(t,
+		pc,
+		enforced,
+		clusterVersion,
+		pool,
+		validatingWebhookConfiguration(admissionregistrationv1.Fail, declaredSelector()),
+		mutatingWebhookConfiguration(admissionregistrationv1.Fail, declaredSelector()),
+	)
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &controller.UpgradeMaintenanceReconciler{Client: c, Recorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)}
+
+	_, err := reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	condition := maintenanceCondition(t, c, pc)
+	require.Equal(t, metav1.ConditionTrue, condition.Status)
+	require.Equal(t, "cluster upgrade to 4.18.1 is in progress", condition.Message)
+	requireFailurePolicyValue(t, c, pc, admissionregistrationv1.Ignore, ptr.To(""))
+	requirePolicyMode(t, c, "enforced", "warn", "enforce")
+	updated := &v1alpha1.PolicyController{}
+	require.NoError(t, c.Get(ctx, req.NamespacedName, updated))
+	require.Equal(t, int32(3), *updated.Spec.PolicyController.Webhook.ReplicaCount)
+	require.Equal(t, "", updated.Annotations[constants.MaintenanceReplicaCountAnnotation])
+	require.Equal(t, v1alpha1.CosignWebhookTimeoutSeconds{Mutating: ptr.To(int32(30)), Validating: ptr.To(int32(30))}, updated.Spec.PolicyController.Cosign.WebhookTimeoutSeconds)
+	require.Equal(t, "{}", updated.Annotations[constants.MaintenanceTimeoutSecondsAnnotation])
+	require.Contains(t, <-recorder.Events, "Warning UpgradeInProgress")
+
+	// The helm operator renders the values into the webhook configurations,
+	// which are not patched directly.
+	requireFailurePolicy(t, c, admissionregistrationv1.Fail)
+	drift := &controller.WebhookDriftReconciler{Client: c, Recorder: events.NewFakeRecorder(10)}
+	_, err = drift.Reconcile(ctx, req)
+	require.NoError(t, err)
+	requireFailurePolicy(t, c, admissionregistrationv1.Ignore)
+	requireTimeoutSeconds(t, c, 30)
+
+	// Without a break-glass the break-glass reconciler keeps the policies in
+	// warn mode during the upgrade.
+	breakGlass := &controller.BreakGlassReconciler{Client: c, Recorder: events.NewFakeRecorder(10)}
+	_, err = breakGlass.Reconcile(ctx, req)
+	require.NoError(t, err)
+	requirePolicyMode(t, c, "enforced", "warn", "enforce")
+
+	require.NoError(t, unstructured.SetNestedSlice(clusterVersion.Object, conditions("Progressing", "False"), "status", "conditions"))
+	require.NoError(t, c.Update(ctx, clusterVersion))
+	require.NoError(t, unstructured.SetNestedSlice(pool.Object, conditions("Updating", "True"), "status", "conditions"))
+	require.NoError(t, c.Update(ctx, pool))
+	_, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	condition = maintenanceCondition(t, c, pc)
+	require.Equal(t, metav1.ConditionTrue, condition.Status)
+	require.Equal(t, "MachineConfigPool worker is updating", condition.Message)
+	require.Empty(t, recorder.Events)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(pool), pool))
+	require.NoError(t, unstructured.SetNestedSlice(pool.Object, conditions("Updating", "False"), "status", "conditions"))
+	require.NoError(t, c.Update(ctx, pool))
+	_, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	condition = maintenanceCondition(t, c, pc)
+	require.Equal(t, metav1.ConditionFalse, condition.Status)
+	require.Equal(t, controller.ReasonNoUpgrade, condition.Reason)
+	requireFailurePolicyValue(t, c, pc, "", nil)
+	requirePolicyMode(t, c, "enforced", "enforce", "")
+	require.NoError(t, c.Get(ctx, req.NamespacedName, updated))
+	require.Nil(t, updated.Spec.PolicyController.Webhook.ReplicaCount)
+	require.NotContains(t, updated.Annotations, constants.MaintenanceReplicaCountAnnotation)
+	require.Zero(t, updated.Spec.PolicyController.Cosign.WebhookTimeoutSeconds)
+	require.NotContains(t, updated.Annotations, constants.MaintenanceTimeoutSecondsAnnotation)
+	require.Contains(t, <-recorder.Events, "Normal Restored")
+}
+
+// TestUpgradeMaintenanceReconcilerDeclaredFailurePolicy checks that a
+// failurePolicy set during an upgrade is restored once it has finished, and
+// that the maintenance failurePolicy is not taken for one when the circuit
+// breaker overrides it in turn.
+func TestUpgradeMaintenanceReconcilerDeclaredFailurePolicy(t *testing.T) {
+	ctx := context.Background()
+	pc := policyController()
+	pc.Spec.UpgradeMaintenance = &v1alpha1.UpgradeMaintenanceSpec{FailurePolicy: ptr.To(admissionregistrationv1.Fail)}
+	clusterVersion := openShiftResource(controller.ClusterVersionGVK, "version", "Progressing", "True")
+	c := newFakeClient(t, pc, clusterVersion)
+	reconciler := &controller.UpgradeMaintenanceReconciler{Client: c, Recorder: events.NewFakeRecorder(10)}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)}
+
+	_, err := reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	requireFailurePolicyValue(t, c, pc, admissionregistrationv1.Fail, ptr.To(""))
+
+	updated := &v1alpha1.PolicyController{}
+	require.NoError(t, c.Get(ctx, req.NamespacedName, updated))
+	meta.SetStatusCondition(&updated.Status.Conditions, metav1.Condition{Type: v1alpha1.ConditionCircuitBreakerOpen, Status: metav1.ConditionTrue, Reason: controller.ReasonWebhookUnavailable})
+	require.NoError(t, c.Status().Update(ctx, updated))
+	_, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	requireFailurePolicyValue(t, c, pc, admissionregistrationv1.Ignore, ptr.To(""))
+
+	require.NoError(t, c.Get(ctx, req.NamespacedName, updated))
+	meta.RemoveStatusCondition(&updated.Status.Conditions, v1alpha1.ConditionCircuitBreakerOpen)
+	require.NoError(t, c.Status().Update(ctx, updated))
+	_, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	requireFailurePolicyValue(t, c, pc, admissionregistrationv1.Fail, ptr.To(""))
+
+	require.NoError(t, c.Get(ctx, req.NamespacedName, updated))
+	updated.Spec.PolicyController.Webhook.FailurePolicy = ptr.To(admissionregistrationv1.Ignore)
+	require.NoError(t, c.Update(ctx, updated))
+	_, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	requireFailurePolicyValue(t, c, pc, admissionregistrationv1.Fail, ptr.To("Ignore"))
+
+	require.NoError(t, unstructured.SetNestedSlice(clusterVersion.Object, conditions("Progressing", "False"), "status", "conditions"))
+	require.NoError(t, c.Update(ctx, clusterVersion))
+	_, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, metav1.ConditionFalse, maintenanceCondition(t, c, pc).Status)
+	requireFailurePolicyValue(t, c, pc, admissionregistrationv1.Ignore, nil)
+}
+
+func TestUpgradeMaintenanceReconcilerKeepsHigherReplicaCount(t *testing.T) {
+	ctx := context.Background()
+	pc := policyController()
+	pc.Spec.PolicyController.Webhook.ReplicaCount = ptr.To(int32(5))
+	pc.Spec.UpgradeMaintenance = &v1alpha1.UpgradeMaintenanceSpec{ReplicaCount: ptr.To(int32(3))}
+	c := newFakeClient(t, pc, openShiftResource(controller.ClusterVersionGVK, "version", "Progressing", "True"))
+	reconciler := &controller.UpgradeMaintenanceReconciler{Client: c, Recorder: events.NewFakeRecorder(10)}
+
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)})
+	require.NoError(t, err)
+	updated := &v1alpha1.PolicyController{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(pc), updated))
+	require.Equal(t, int32(5), *updated.Spec.PolicyController.Webhook.ReplicaCount)
+	require.NotContains(t, updated.Annotations, constants.MaintenanceReplicaCountAnnotation)
+}
+
+func openShiftResource(gvk schema.GroupVersionKind, name, conditionType, status string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+	u.SetName(name)
+	_ = unstructured.SetNestedSlice(u.Object, conditions(conditionType, status), "status", "conditions")
+	return u
+}
+
+func conditions(conditionType, status string) []interface{} {
+	return []interface{}{map[string]interface{}{"type": conditionType, "status": status}}
+}
+
+func requireTimeoutSeconds(t *testing.T, c client.Client, expected int32) {
+	t.Helper()
+	vwc := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: constants.DefaultWebhookName}, vwc))
+	require.Equal(t, expected, *vwc.Webhooks[0].TimeoutSeconds)
+	mwc := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: constants.DefaultWebhookName}, mwc))
+	require.Equal(t, expected, *mwc.Webhooks[0].TimeoutSeconds)
+}
+
+func maintenanceCondition(t *testing.T, c client.Client, pc *v1alpha1.PolicyController) *metav1.Condition {
+	t.Helper()
+	updated := &v1alpha1.PolicyController{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(pc), updated))
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionUpgradeMaintenance)
+	require.NotNil(t, condition)
+	return condition
+}
@@ -0,0 +1,137 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var knativeExclude = metav1.LabelSelectorRequirement{Key: "webhooks.knative.dev/exclude", Operator: metav1.LabelSelectorOpDoesNotExist}
+
+func TestWebhookDriftReconciler(t *testing.T) {
+	tests := []struct {
+		name           string
+		mode           v1alpha1.WebhookDriftMode
+		failurePolicy  admissionregistrationv1.FailurePolicyType
+		selector       metav1.LabelSelector
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+		expectedPolicy admissionregistrationv1.FailurePolicyType
+		expectEvents   bool
+	}{
+		{
+			name:           "in sync",
+			failurePolicy:  admissionregistrationv1.Fail,
+			selector:       declaredSelector(knativeExclude),
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: controller.ReasonInSync,
+			expectedPolicy: admissionregistrationv1.Fail,
+		},
+		{
+			name:           "drift is restored by default",
+			failurePolicy:  admissionregistrationv1.Ignore,
+			selector:       metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{knativeExclude}},
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: controller.ReasonRestored,
+			expectedPolicy: admissionregistrationv1.Fail,
+			expectEvents:   true,
+		},
+		{
+			name:           "drift is only reported",
+			mode:           v1alpha1.WebhookDriftReport,
+			failurePolicy:  admissionregistrationv1.Ignore,
+			selector:       declaredSelector(),
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: controller.ReasonDrifted,
+			expectedPolicy: admissionregistrationv1.Ignore,
+			expectEvents:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pc := policyController()
+			pc.Spec.WebhookDrift.Mode = tc.mode
+			c := newFakeClient(t,
+				pc,
+				validatingWebhookConfiguration(tc.failurePolicy, tc.selector),
+				mutatingWebhookConfiguration(admissionregistrationv1.Fail, declaredSelector(knativeExclude)),
+			)
+			recorder := events.NewFakeRecorder(10)
+			reconciler := &controller.WebhookDriftReconciler{Client: c, Recorder: recorder}
+
+			_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pc)})
+			require.NoError(t, err)
+
+			updated := &v1alpha1.PolicyController{}
+			require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(pc), updated))
+			condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionWebhookConfigurationSynced)
+			require.NotNil(t, condition)
+			require.Equal(t, tc.expectedStatus, condition.Status, condition.Message)
+			require.Equal(t, tc.expectedReason, condition.Reason, condition.Message)
+
+			vwc := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+			require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: constants.DefaultWebhookName}, vwc))
+			require.Equal(t, tc.expectedPolicy, *vwc.Webhooks[0].FailurePolicy)
+			if tc.mode != v1alpha1.WebhookDriftReport {
+				require.Equal(t, declaredSelector(knativeExclude), *vwc.Webhooks[0].NamespaceSelector)
+			}
+
+			if tc.expectEvents {
+				require.NotEmpty(t, recorder.Events)
+			} else {
+				require.Empty(t, recorder.Events)
+			}
+		})
+	}
+}
+
+func declaredSelector(extra ...metav1.LabelSelectorRequirement) metav1.LabelSelector {
+	return metav1.LabelSelector{
+		MatchExpressions: append([]metav1.LabelSelectorRequirement{{
+			Key:      "policy.rhtas.com/include",
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   []string{"true"},
+		}}, extra...),
+	}
+}
+
+func webhookClientConfig() admissionregistrationv1.WebhookClientConfig {
+	return admissionregistrationv1.WebhookClientConfig{
+		Service: &admissionregistrationv1.ServiceReference{Namespace: constants.PolicyControllerInstallNs, Name: "webhook"},
+	}
+}
+
+func validatingWebhookConfiguration(fp admissionregistrationv1.FailurePolicyType, selector metav1.LabelSelector) *admissionregistrationv1.ValidatingWebhookConfiguration {
+	return &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: constants.DefaultWebhookName},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name:              constants.DefaultWebhookName,
+			FailurePolicy:     &fp,
+			NamespaceSelector: &selector,
+			ClientConfig:      webhookClientConfig(),
+		}},
+	}
+}
+
+func mutatingWebhookConfiguration(fp admissionregistrationv1.FailurePolicyType, selector metav1.LabelSelector) *admissionregistrationv1.MutatingWebhookConfiguration {
+	return &admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: constants.DefaultWebhookName},
+		Webhooks: []admissionregistrationv1.MutatingWebhook{{
+			Name:              constants.DefaultWebhookName,
+			FailurePolicy:     &fp,
+			NamespaceSelector: &selector,
+			ClientConfig:      webhookClientConfig(),
+		}},
+	}
+}
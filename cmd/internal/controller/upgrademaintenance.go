@@ -0,0 +1,258 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// OpenShift resources that report the progress of a cluster upgrade.
+var (
+	ClusterVersionGVK    = schema.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: "ClusterVersion"}
+	MachineConfigPoolGVK = schema.GroupVersionKind{Group: "machineconfiguration.openshift.io", Version: "v1", Kind: "MachineConfigPool"}
+)
+
+// clusterVersionName is the name of the singleton ClusterVersion.
+const clusterVersionName = "version"
+
+// defaultReplicaCount mirrors webhook.replicaCount in the chart values.
+const defaultReplicaCount int32 = 1
+
+// Reasons of the UpgradeMaintenance condition and maintenance Events.
+const (
+	ReasonUpgradeInProgress = "UpgradeInProgress"
+	ReasonNoUpgrade         = "NoUpgrade"
+)
+
+// UpgradeMaintenanceReconciler applies the upgrade maintenance profile of a
+// PolicyController while the OpenShift ClusterVersion is Progressing or a
+// MachineConfigPool is Updating, and reverts it afterwards. The policy modes
+// follow the UpgradeMaintenance condition; the webhook failurePolicy and
+// timeouts and the replica count are overridden through the helm values, as
+// the helm-operator reverts changes to the objects it renders.
+type UpgradeMaintenanceReconciler struct {
+	client.Client
+	Recorder events.EventRecorder
+}
+
+// SetupWithManager skips the controller on clusters without the OpenShift
+// upgrade APIs.
+func (r *UpgradeMaintenanceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if _, err := mgr.GetRESTMapper().RESTMapping(ClusterVersionGVK.GroupKind(), ClusterVersionGVK.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			mgr.GetLogger().Info("ClusterVersion API not found, upgrade maintenance is disabled")
+			return nil
+		}
+		return err
+	}
+	enqueueAll := enqueueAllPolicyControllers(r.Client)
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("policycontroller-upgrademaintenance").
+		For(&v1alpha1.PolicyController{}).
+		Watches(newUnstructured(ClusterVersionGVK), enqueueAll).
+		Watches(newUnstructured(MachineConfigPoolGVK), enqueueAll).
+		Complete(r)
+}
+
+func (r *UpgradeMaintenanceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	pc := &v1alpha1.PolicyController{}
+	if err := r.Get(ctx, req.NamespacedName, pc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !pc.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	previous := meta.FindStatusCondition(pc.Status.Conditions, v1alpha1.ConditionUpgradeMaintenance)
+	wasActive := previous != nil && previous.Status == metav1.ConditionTrue
+	condition := metav1.Condition{Type: v1alpha1.ConditionUpgradeMaintenance, Status: metav1.ConditionFalse}
+	if pc.Spec.UpgradeMaintenance == nil {
+		if previous == nil {
+			return ctrl.Result{}, nil
+		}
+		condition.Reason = ReasonDisabled
+		condition.Message = "upgrade maintenance is disabled"
+	} else {
+		upgrade, err := r.upgradeInProgress(ctx)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if upgrade != "" {
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = ReasonUpgradeInProgress
+			condition.Message = upgrade
+		} else {
+			condition.Reason = ReasonNoUpgrade
+			condition.Message = "no cluster upgrade is in progress"
+		}
+	}
+
+	active := condition.Status == metav1.ConditionTrue
+	result, err := setConditions(ctx, r.Client, pc, condition)
+	if err != nil || !result.IsZero() {
+		return result, err
+	}
+	if !active && !wasActive {
+		return ctrl.Result{}, nil
+	}
+
+	if err := applyPolicyModes(ctx, r.Client, policiesWarn(pc)); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := applyFailurePolicy(ctx, r.Client, pc); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: time.Second}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if err := r.applyTimeoutSeconds(ctx, pc, active); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.applyReplicaCount(ctx, pc, active); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch {
+	case active && !wasActive:
+		log.Info("applied upgrade maintenance profile", "reason", condition.Message)
+		r.Recorder.Eventf(pc, nil, corev1.EventTypeWarning, ReasonUpgradeInProgress, "UpgradeMaintenance", "applied the upgrade maintenance profile: %s", condition.Message)
+	case !active && wasActive:
+		log.Info("reverted upgrade maintenance profile")
+		r.Recorder.Eventf(pc, nil, corev1.EventTypeNormal, ReasonRestored, "UpgradeMaintenance", "reverted the upgrade maintenance profile: %s", condition.Message)
+	}
+	return ctrl.Result{}, nil
+}
+
+// upgradeInProgress describes the cluster upgrade or MachineConfigPool
+// update in progress, or returns an empty string if there is none.
+func (r *UpgradeMaintenanceReconciler) upgradeInProgress(ctx context.Context) (string, error) {
+	var upgrades []string
+
+	cv := newUnstructured(ClusterVersionGVK)
+	if err := r.Get(ctx, client.ObjectKey{Name: clusterVersionName}, cv); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return "", err
+		}
+	} else if hasTrueCondition(cv, "Progressing") {
+		version, _, _ := unstructured.NestedString(cv.Object, "status", "desired", "version")
+		upgrades = append(upgrades, fmt.Sprintf("cluster upgrade to %s is in progress", version))
+	}
+
+	pools := &unstructured.UnstructuredList{}
+	pools.SetGroupVersionKind(MachineConfigPoolGVK.GroupVersion().WithKind(MachineConfigPoolGVK.Kind + "List"))
+	if err := r.List(ctx, pools); err != nil {
+		return "", err
+	}
+	var updating []string
+	for i := range pools.Items {
+		if hasTrueCondition(&pools.Items[i], "Updating") {
+			updating = append(updating, pools.Items[i].GetName())
+		}
+	}
+	if len(updating) > 0 {
+		sort.Strings(updating)
+		upgrades = append(upgrades, fmt.Sprintf("MachineConfigPool %s is updating", strings.Join(updating, ", ")))
+	}
+	return strings.Join(upgrades, "; "), nil
+}
+
+// applyTimeoutSeconds sets cosign.webhookTimeoutSeconds to the maintenance
+// timeout, recording the configured values in an annotation, or restores the
+// recorded values once maintenance has ended.
+func (r *UpgradeMaintenanceReconciler) applyTimeoutSeconds(ctx context.Context, pc *v1alpha1.PolicyController, active bool) error {
+	values := &pc.Spec.PolicyController.Cosign.WebhookTimeoutSeconds
+	original, saved := pc.Annotations[constants.MaintenanceTimeoutSecondsAnnotation]
+	patch := client.MergeFromWithOptions(pc.DeepCopy(), client.MergeFromWithOptimisticLock{})
+
+	if active {
+		spec := pc.Spec.UpgradeMaintenance
+		if saved || spec == nil || spec.TimeoutSeconds == nil {
+			return nil
+		}
+		recorded, err := json.Marshal(values)
+		if err != nil {
+			return err
+		}
+		if pc.Annotations == nil {
+			pc.Annotations = map[string]string{}
+		}
+		pc.Annotations[constants.MaintenanceTimeoutSecondsAnnotation] = string(recorded)
+		values.Mutating = ptr.To(*spec.TimeoutSeconds)
+		values.Validating = ptr.To(*spec.TimeoutSeconds)
+	} else {
+		if !saved {
+			return nil
+		}
+		restored := v1alpha1.CosignWebhookTimeoutSeconds{}
+		if err := json.Unmarshal([]byte(original), &restored); err != nil {
+			return fmt.Errorf("invalid %s annotation: %w", constants.MaintenanceTimeoutSecondsAnnotation, err)
+		}
+		*values = restored
+		delete(pc.Annotations, constants.MaintenanceTimeoutSecondsAnnotation)
+	}
+	return r.Patch(ctx, pc, patch)
+}
+
+// applyReplicaCount raises webhook.replicaCount to the maintenance replica
+// count, recording the configured value in an annotation, or restores the
+// recorded value once maintenance has ended.
+func (r *UpgradeMaintenanceReconciler) applyReplicaCount(ctx context.Context, pc *v1alpha1.PolicyController, active bool) error {
+	values := &pc.Spec.PolicyController.Webhook
+	original, saved := pc.Annotations[constants.MaintenanceReplicaCountAnnotation]
+	patch := client.MergeFromWithOptions(pc.DeepCopy(), client.MergeFromWithOptimisticLock{})
+
+	if active {
+		spec := pc.Spec.UpgradeMaintenance
+		if saved || spec == nil || spec.ReplicaCount == nil {
+			return nil
+		}
+		current := defaultReplicaCount
+		if values.ReplicaCount != nil {
+			current = *values.ReplicaCount
+			original = strconv.Itoa(int(current))
+		}
+		if current >= *spec.ReplicaCount {
+			return nil
+		}
+		if pc.Annotations == nil {
+			pc.Annotations = map[string]string{}
+		}
+		pc.Annotations[constants.MaintenanceReplicaCountAnnotation] = original
+		replicas := *spec.ReplicaCount
+		values.ReplicaCount = &replicas
+	} else {
+		if !saved {
+			return nil
+		}
+		values.ReplicaCount = nil
+		if original != "" {
+			replicas, err := strconv.ParseInt(original, 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid %s annotation: %w", constants.MaintenanceReplicaCountAnnotation, err)
+			}
+			values.ReplicaCount = ptr.To(int32(replicas))
+		}
+		delete(pc.Annotations, constants.MaintenanceReplicaCountAnnotation)
+	}
+	return r.Patch(ctx, pc, patch)
+}
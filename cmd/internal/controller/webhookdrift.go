@@ -0,0 +1,302 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Reasons of the WebhookConfigurationSynced condition and drift Events.
+const (
+	ReasonInSync                     = "InSync"
+	ReasonDrifted                    = "Drifted"
+	ReasonRestored                   = "Restored"
+	ReasonWebhookConfigurationAbsent = "WebhookConfigurationAbsent"
+)
+
+// webhookServiceName is the Service the chart points the policy webhooks at.
+const webhookServiceName = "webhook"
+
+// knativeExcludeKey is added to the namespaceSelector of the policy webhooks
+// by the policy-controller itself, so it is not drift.
+const knativeExcludeKey = "webhooks.knative.dev/exclude"
+
+// defaultNamespaceSelector mirrors webhook.namespaceSelector in the operator
+// chart values.
+var defaultNamespaceSelector = metav1.LabelSelector{
+	MatchExpressions: []metav1.LabelSelectorRequirement{{
+		Key:      "policy.rhtas.com/include",
+		Operator: metav1.LabelSelectorOpIn,
+		Values:   []string{"true"},
+	}},
+}
+
+// WebhookDriftReconciler compares the policy webhook configurations named by
+// cosign.webhookName with the PolicyController spec. Depending on
+// spec.webhookDrift.mode it restores the declared state or only reports the
+// drift through the WebhookConfigurationSynced condition and Events.
+type WebhookDriftReconciler struct {
+	client.Client
+	Recorder events.EventRecorder
+}
+
+func (r *WebhookDriftReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	enqueueAll := enqueueAllPolicyControllers(r.Client)
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("policycontroller-webhookdrift").
+		For(&v1alpha1.PolicyController{}).
+		Watches(&admissionregistrationv1.ValidatingWebhookConfiguration{}, enqueueAll).
+		Watches(&admissionregistrationv1.MutatingWebhookConfiguration{}, enqueueAll).
+		Complete(r)
+}
+
+// webhookFields points at the fields of a validating or mutating webhook that
+// the chart renders from the PolicyController spec.
+type webhookFields struct {
+	FailurePolicy     **admissionregistrationv1.FailurePolicyType
+	NamespaceSelector **metav1.LabelSelector
+	TimeoutSeconds    **int32
+	Service           **admissionregistrationv1.ServiceReference
+}
+
+// desiredWebhook is the declared state of a policy webhook. A nil
+// TimeoutSeconds leaves the API server default in place.
+type desiredWebhook struct {
+	FailurePolicy     admissionregistrationv1.FailurePolicyType
+	NamespaceSelector metav1.LabelSelector
+	TimeoutSeconds    *int32
+	Service           types.NamespacedName
+}
+
+func (r *WebhookDriftReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	pc := &v1alpha1.PolicyController{}
+	if err := r.Get(ctx, req.NamespacedName, pc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !pc.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	mode := pc.Spec.WebhookDrift.Mode
+	if mode == "" {
+		mode = v1alpha1.WebhookDriftRestore
+	}
+	name := policyWebhookName(pc)
+	validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	configs := []struct {
+		kind    string
+		obj     client.Object
+		desired desiredWebhook
+		fields  func() *webhookFields
+	}{
+		{
+			kind:    "ValidatingWebhookConfiguration",
+			obj:     validating,
+			desired: declaredWebhook(pc, pc.Spec.PolicyController.Cosign.WebhookTimeoutSeconds.Validating),
+			fields: func() *webhookFields {
+				for i := range validating.Webhooks {
+					if wh := &validating.Webhooks[i]; wh.Name == name {
+						return &webhookFields{&wh.FailurePolicy, &wh.NamespaceSelector, &wh.TimeoutSeconds, &wh.ClientConfig.Service}
+					}
+				}
+				return nil
+			},
+		},
+		{
+			kind:    "MutatingWebhookConfiguration",
+			obj:     mutating,
+			desired: declaredWebhook(pc, pc.Spec.PolicyController.Cosign.WebhookTimeoutSeconds.Mutating),
+			fields: func() *webhookFields {
+				for i := range mutating.Webhooks {
+					if wh := &mutating.Webhooks[i]; wh.Name == name {
+						return &webhookFields{&wh.FailurePolicy, &wh.NamespaceSelector, &wh.TimeoutSeconds, &wh.ClientConfig.Service}
+					}
+				}
+				return nil
+			},
+		},
+	}
+
+	var drifted, missing []string
+	found := 0
+	for _, config := range configs {
+		if err := r.Get(ctx, types.NamespacedName{Name: name}, config.obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return ctrl.Result{}, err
+		}
+		found++
+
+		fields := config.fields()
+		if fields == nil {
+			missing = append(missing, fmt.Sprintf("%s %q has no webhook named %q", config.kind, name, name))
+			continue
+		}
+		diffs := compareWebhook(config.desired, fields)
+		if len(diffs) == 0 {
+			continue
+		}
+		summary := fmt.Sprintf("%s %q: %s", config.kind, name, strings.Join(diffs, ", "))
+		drifted = append(drifted, summary)
+		r.Recorder.Eventf(pc, config.obj, corev1.EventTypeWarning, ReasonDrifted, "Compare", "%s", summary)
+
+		if mode != v1alpha1.WebhookDriftRestore {
+			continue
+		}
+		patch := client.MergeFromWithOptions(config.obj.DeepCopyObject().(client.Object), client.MergeFromWithOptimisticLock{})
+		restoreWebhook(config.desired, fields)
+		if err := r.Patch(ctx, config.obj, patch); err != nil {
+			return ctrl.Result{}, fmt.Errorf("unable to restore %s %q: %w", config.kind, name, err)
+		}
+		log.Info("restored webhook configuration", "kind", config.kind, "name", name, "drift", diffs)
+		r.Recorder.Eventf(pc, config.obj, corev1.EventTypeNormal, ReasonRestored, "Restore", "restored %s %q", config.kind, name)
+	}
+
+	condition := metav1.Condition{Type: v1alpha1.ConditionWebhookConfigurationSynced}
+	switch {
+	case found == 0:
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = ReasonWebhookConfigurationAbsent
+		condition.Message = fmt.Sprintf("no webhook configuration named %q", name)
+	case len(missing) > 0:
+		// A webhook entry cannot be restored without the CA bundle and rules
+		// that the policy-controller maintains, so it is only reported.
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = ReasonDrifted
+		condition.Message = strings.Join(append(missing, drifted...), "; ")
+	case len(drifted) == 0:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = ReasonInSync
+		condition.Message = "webhook configurations match the PolicyController spec"
+	case mode == v1alpha1.WebhookDriftRestore:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = ReasonRestored
+		condition.Message = "restored " + strings.Join(drifted, "; ")
+	default:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = ReasonDrifted
+		condition.Message = strings.Join(drifted, "; ")
+	}
+	return setConditions(ctx, r.Client, pc, condition)
+}
+
+// declaredWebhook renders the policy webhook the way the chart does.
+func declaredWebhook(pc *v1alpha1.PolicyController, timeoutSeconds *int32) desiredWebhook {
+	values := pc.Spec.PolicyController.Webhook
+	desired := desiredWebhook{
+		FailurePolicy:     admissionregistrationv1.Fail,
+		NamespaceSelector: DeclaredNamespaceSelector(pc),
+		TimeoutSeconds:    timeoutSeconds,
+		Service:           types.NamespacedName{Namespace: pc.Namespace, Name: webhookServiceName},
+	}
+	if values.FailurePolicy != nil {
+		desired.FailurePolicy = *values.FailurePolicy
+	}
+	return desired
+}
+
+// DeclaredNamespaceSelector is the namespaceSelector the chart renders into
+// the policy webhooks. Helm merges the namespaceSelector map of the CR over
+// the chart default, so matchLabels and matchExpressions are defaulted
+// independently.
+func DeclaredNamespaceSelector(pc *v1alpha1.PolicyController) metav1.LabelSelector {
+	declared := *defaultNamespaceSelector.DeepCopy()
+	if selector := pc.Spec.PolicyController.Webhook.NamespaceSelector; selector != nil {
+		if selector.MatchLabels != nil {
+			declared.MatchLabels = selector.MatchLabels
+		}
+		if selector.MatchExpressions != nil {
+			declared.MatchExpressions = selector.MatchExpressions
+		}
+	}
+	return declared
+}
+
+func compareWebhook(desired desiredWebhook, live *webhookFields) []string {
+	var diffs []string
+	if fp := *live.FailurePolicy; fp == nil || *fp != desired.FailurePolicy {
+		diffs = append(diffs, fmt.Sprintf("failurePolicy is %s, expected %s", stringOrUnset(fp), desired.FailurePolicy))
+	}
+	if !equality.Semantic.DeepEqual(withoutKnativeExclude(*live.NamespaceSelector), &desired.NamespaceSelector) {
+		diffs = append(diffs, fmt.Sprintf("namespaceSelector is %s, expected %s", metav1.FormatLabelSelector(*live.NamespaceSelector), metav1.FormatLabelSelector(&desired.NamespaceSelector)))
+	}
+	if desired.TimeoutSeconds != nil {
+		if ts := *live.TimeoutSeconds; ts == nil || *ts != *desired.TimeoutSeconds {
+			diffs = append(diffs, fmt.Sprintf("timeoutSeconds is %s, expected %d", stringOrUnset(ts), *desired.TimeoutSeconds))
+		}
+	}
+	if svc := *live.Service; svc == nil || svc.Namespace != desired.Service.Namespace || svc.Name != desired.Service.Name {
+		live := "unset"
+		if svc != nil {
+			live = svc.Namespace + "/" + svc.Name
+		}
+		diffs = append(diffs, fmt.Sprintf("clientConfig.service is %s, expected %s", live, desired.Service))
+	}
+	return diffs
+}
+
+// restoreWebhook writes the declared state into the live webhook. The
+// namespaceSelector keeps the exclusion maintained by the policy-controller
+// and the service keeps the path and port it set.
+func restoreWebhook(desired desiredWebhook, live *webhookFields) {
+	fp := desired.FailurePolicy
+	*live.FailurePolicy = &fp
+
+	selector := desired.NamespaceSelector.DeepCopy()
+	if current := *live.NamespaceSelector; current != nil {
+		for _, req := range current.MatchExpressions {
+			if req.Key == knativeExcludeKey {
+				selector.MatchExpressions = append(selector.MatchExpressions, req)
+			}
+		}
+	}
+	*live.NamespaceSelector = selector
+
+	if desired.TimeoutSeconds != nil {
+		ts := *desired.TimeoutSeconds
+		*live.TimeoutSeconds = &ts
+	}
+
+	if *live.Service == nil {
+		*live.Service = &admissionregistrationv1.ServiceReference{}
+	}
+	(*live.Service).Namespace = desired.Service.Namespace
+	(*live.Service).Name = desired.Service.Name
+}
+
+func withoutKnativeExclude(selector *metav1.LabelSelector) *metav1.LabelSelector {
+	if selector == nil {
+		return &metav1.LabelSelector{}
+	}
+	out := selector.DeepCopy()
+	out.MatchExpressions = nil
+	for _, req := range selector.MatchExpressions {
+		if req.Key != knativeExcludeKey {
+			out.MatchExpressions = append(out.MatchExpressions, req)
+		}
+	}
+	return out
+}
+
+func stringOrUnset[T any](v *T) string {
+	if v == nil {
+		return "unset"
+	}
+	return fmt.Sprint(*v)
+}
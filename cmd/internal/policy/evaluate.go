@@ -0,0 +1,426 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Verdict is the outcome of evaluating an image.
+type Verdict string
+
+const (
+	// Allowed images are admitted without a warning.
+	Allowed Verdict = "Allowed"
+	// Warned images are admitted with a warning.
+	Warned Verdict = "Warned"
+	// Unknown images could not be fully evaluated, see the messages.
+	Unknown Verdict = "Unknown"
+	// Rejected images are denied admission.
+	Rejected Verdict = "Rejected"
+)
+
+// severity orders verdicts from the best to the worst outcome.
+var severity = map[Verdict]int{Allowed: 0, Warned: 1, Unknown: 2, Rejected: 3}
+
+// Worst returns the more severe of two verdicts.
+func Worst(a, b Verdict) Verdict {
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
+}
+
+// NoMatchPolicy decides about images that no ClusterImagePolicy matches. It
+// is read from the no-match-policy key of the config-policy-controller
+// ConfigMap.
+type NoMatchPolicy string
+
+const (
+	NoMatchAllow NoMatchPolicy = "allow"
+	NoMatchWarn  NoMatchPolicy = "warn"
+	NoMatchDeny  NoMatchPolicy = "deny"
+)
+
+// ErrNotEvaluated is wrapped by verification errors that say nothing about
+// the signatures of an image, such as an unreachable registry.
+var ErrNotEvaluated = errors.New("not evaluated")
+
+// Verifier checks the signatures and attestations of an image. Keyless
+// authorities are verified against the trusted material, which is nil for
+// key authorities.
+type Verifier interface {
+	// VerifySignature returns nil if the image carries a signature the
+	// authority accepts.
+	VerifySignature(ctx context.Context, image string, authority Authority, trust *TrustedMaterial) error
+	// VerifyAttestation returns nil if the image carries an attestation of
+	// the predicate type that the authority accepts.
+	VerifyAttestation(ctx context.Context, image string, authority Authority, trust *TrustedMaterial, predicateType string) error
+}
+
+// Resource is the object an image is admitted with. It decides which
+// ClusterImagePolicies with a match list apply.
+type Resource struct {
+	schema.GroupVersionResource
+	Labels map[string]string
+}
+
+// Pods is the resource the images of workloads are admitted with.
+var Pods = Resource{GroupVersionResource: schema.GroupVersionResource{Version: "v1", Resource: "pods"}}
+
+// ImageResult is the evaluation of one image.
+type ImageResult struct {
+	Image    string   `json:"image"`
+	Verdict  Verdict  `json:"verdict"`
+	Policies []string `json:"policies,omitempty"`
+	Messages []string `json:"messages,omitempty"`
+}
+
+// Explanation details how every policy evaluated an image.
+type Explanation struct {
+	Image    string              `json:"image"`
+	Verdict  Verdict             `json:"verdict"`
+	Policies []PolicyExplanation `json:"policies"`
+	// Message explains the verdict when no policy matches.
+	Message string `json:"message,omitempty"`
+}
+
+// Result summarizes the explanation with a message for every matching policy
+// that is not satisfied.
+func (x Explanation) Result() ImageResult {
+	result := ImageResult{Image: x.Image, Verdict: x.Verdict}
+	for _, p := range x.Policies {
+		if !p.Matched {
+			continue
+		}
+		result.Policies = append(result.Policies, p.Name)
+		if p.Verdict != Allowed {
+			result.Messages = append(result.Messages, fmt.Sprintf("%s: %s", p.Name, p.Message))
+		}
+	}
+	if x.Message != "" {
+		result.Messages = append(result.Messages, x.Message)
+	}
+	return result
+}
+
+type PolicyExplanation struct {
+	Name    string `json:"name"`
+	Mode    string `json:"mode"`
+	Matched bool   `json:"matched"`
+	// Glob is the first glob that matches the image.
+	Glob        string                 `json:"glob,omitempty"`
+	Verdict     Verdict                `json:"verdict,omitempty"`
+	Message     string                 `json:"message,omitempty"`
+	Authorities []AuthorityExplanation `json:"authorities,omitempty"`
+}
+
+type AuthorityExplanation struct {
+	Name         string                   `json:"name"`
+	Verdict      Verdict                  `json:"verdict"`
+	Message      string                   `json:"message"`
+	Attestations []AttestationExplanation `json:"attestations,omitempty"`
+}
+
+type AttestationExplanation struct {
+	Name          string  `json:"name"`
+	PredicateType string  `json:"predicateType"`
+	Verdict       Verdict `json:"verdict"`
+	Message       string  `json:"message"`
+}
+
+// Evaluator evaluates images against a set of ClusterImagePolicies. It
+// remembers signature verifications, so it should not outlive the policies
+// it was built from. It is not safe for concurrent use.
+type Evaluator struct {
+	Policies []ClusterImagePolicy
+	// TrustRoots are the installed TrustRoots by name.
+	TrustRoots    map[string]TrustRoot
+	NoMatchPolicy NoMatchPolicy
+	Verifier      Verifier
+
+	verified map[string]error
+}
+
+// Evaluate evaluates an image the way the policy-controller admits it.
+func (e *Evaluator) Evaluate(ctx context.Context, image string, resource Resource) ImageResult {
+	return e.Explain(ctx, image, resource).Result()
+}
+
+// Explain evaluates an image against every policy. A matching policy has to
+// be satisfied by at least one of its authorities. An authority with
+// attestations is satisfied by them rather than by a signature. Key
+// signatures are verified without looking up their transparency log entry.
+// Keyless signatures need a TrustRoot that lists its keys. CUE and Rego
+// policies are reported as Unknown.
+func (e *Evaluator) Explain(ctx context.Context, image string, resource Resource) Explanation {
+	explanation := Explanation{Image: image, Verdict: Allowed}
+	matched := false
+
+	for _, cip := range e.Policies {
+		p := PolicyExplanation{Name: cip.Name, Mode: cip.Spec.Mode}
+		if p.Mode == "" {
+			p.Mode = ModeEnforce
+		}
+		glob, err := e.match(cip, image, resource)
+		switch {
+		case err != nil:
+			p.Matched = true
+			p.Verdict = Rejected
+			p.Message = err.Error()
+		case glob == "":
+			explanation.Policies = append(explanation.Policies, p)
+			continue
+		default:
+			p.Matched = true
+			p.Glob = glob
+			e.evaluatePolicy(ctx, cip, image, &p)
+		}
+		matched = true
+
+		verdict := p.Verdict
+		if verdict != Allowed && p.Mode == ModeWarn {
+			verdict = Warned
+		}
+		explanation.Verdict = Worst(explanation.Verdict, verdict)
+		explanation.Policies = append(explanation.Policies, p)
+	}
+
+	if !matched {
+		switch e.NoMatchPolicy {
+		case NoMatchAllow:
+		case NoMatchWarn:
+			explanation.Verdict = Warned
+			explanation.Message = "no matching policies"
+		default:
+			explanation.Verdict = Rejected
+			explanation.Message = "no matching policies"
+		}
+	}
+	return explanation
+}
+
+// match returns the first glob of a policy that matches the image admitted
+// with the resource, or "" if the policy does not apply.
+func (e *Evaluator) match(cip ClusterImagePolicy, image string, resource Resource) (string, error) {
+	if len(cip.Spec.Match) > 0 && !matchesResource(cip.Spec.Match, resource) {
+		return "", nil
+	}
+	for _, pattern := range cip.Spec.Images {
+		matched, err := MatchGlob(pattern.Glob, image)
+		if err != nil {
+			return "", err
+		}
+		if matched {
+			return pattern.Glob, nil
+		}
+	}
+	return "", nil
+}
+
+func matchesResource(match []MatchResource, resource Resource) bool {
+	for _, m := range match {
+		if m.Group != resource.Group || m.Version != resource.Version || m.Resource != resource.Resource {
+			continue
+		}
+		if m.ResourceSelector == nil {
+			return true
+		}
+		selector, err := metav1.LabelSelectorAsSelector(m.ResourceSelector)
+		if err == nil && selector.Matches(labels.Set(resource.Labels)) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluatePolicy tries every authority. The policy is Allowed if any
+// authority is, Unknown if an authority could not be evaluated and Rejected
+// otherwise.
+func (e *Evaluator) evaluatePolicy(ctx context.Context, cip ClusterImagePolicy, image string, p *PolicyExplanation) {
+	if len(cip.Spec.Authorities) == 0 {
+		p.Verdict = Rejected
+		p.Message = "no authorities"
+		return
+	}
+
+	p.Verdict = Rejected
+	var messages []string
+	for i, authority := range cip.Spec.Authorities {
+		a := e.evaluateAuthority(ctx, authority, image)
+		a.Name = authority.Name
+		if a.Name == "" {
+			a.Name = fmt.Sprintf("authority-%d", i)
+		}
+		p.Authorities = append(p.Authorities, a)
+
+		switch a.Verdict {
+		case Allowed:
+			p.Verdict = Allowed
+		case Unknown:
+			if p.Verdict == Rejected {
+				p.Verdict = Unknown
+			}
+		}
+		messages = append(messages, fmt.Sprintf("%s: %s", a.Name, a.Message))
+	}
+
+	if p.Verdict == Allowed && cip.Spec.Policy != nil {
+		p.Verdict = Unknown
+		p.Message = fmt.Sprintf("%s policies are not evaluated", cip.Spec.Policy.Type)
+		return
+	}
+	if p.Verdict != Allowed {
+		p.Message = strings.Join(messages, "; ")
+	}
+}
+
+func (e *Evaluator) evaluateAuthority(ctx context.Context, authority Authority, image string) AuthorityExplanation {
+	for _, ref := range trustRootRefs(authority) {
+		tr, found := e.TrustRoots[ref]
+		switch {
+		case !found:
+			return AuthorityExplanation{Verdict: Rejected, Message: fmt.Sprintf("TrustRoot %q not found", ref)}
+		case !tr.Ready:
+			return AuthorityExplanation{Verdict: Rejected, Message: fmt.Sprintf("TrustRoot %q is not ready", ref)}
+		}
+	}
+
+	switch {
+	case authority.Static != nil:
+		if authority.Static.Action == StaticPass {
+			return AuthorityExplanation{Verdict: Allowed, Message: "static authority passes"}
+		}
+		message := "static authority fails"
+		if authority.Static.Message != "" {
+			message = authority.Static.Message
+		}
+		return AuthorityExplanation{Verdict: Rejected, Message: message}
+	case authority.Key != nil && authority.Key.Data == "":
+		return AuthorityExplanation{Verdict: Unknown, Message: "only keys given as data are evaluated"}
+	case authority.Key == nil && authority.Keyless == nil:
+		return AuthorityExplanation{Verdict: Rejected, Message: "authority has no key, keyless or static verifier"}
+	}
+
+	var trust *TrustedMaterial
+	if authority.Keyless != nil {
+		var err error
+		if trust, err = e.trustedMaterial(authority); err != nil {
+			return AuthorityExplanation{Verdict: Unknown, Message: err.Error()}
+		}
+	}
+
+	if len(authority.Attestations) == 0 {
+		if err := e.verify(ctx, image, authority, trust, ""); err != nil {
+			return AuthorityExplanation{Verdict: verdictOf(err), Message: err.Error()}
+		}
+		return AuthorityExplanation{Verdict: Allowed, Message: "signature verified"}
+	}
+
+	a := AuthorityExplanation{Verdict: Allowed, Message: "attestations verified"}
+	for _, attestation := range authority.Attestations {
+		result := AttestationExplanation{Name: attestation.Name, PredicateType: attestation.PredicateType, Verdict: Allowed, Message: "attestation verified"}
+		if err := e.verify(ctx, image, authority, trust, attestation.PredicateType); err != nil {
+			result.Verdict = verdictOf(err)
+			result.Message = err.Error()
+		} else if attestation.Policy != nil {
+			result.Verdict = Unknown
+			result.Message = fmt.Sprintf("attestation verified, %s policy not evaluated", attestation.Policy.Type)
+		}
+		a.Attestations = append(a.Attestations, result)
+		if result.Verdict != Allowed {
+			a.Verdict = Worst(a.Verdict, result.Verdict)
+			a.Message = fmt.Sprintf("attestation %s: %s", attestation.Name, result.Message)
+		}
+	}
+	return a
+}
+
+func verdictOf(err error) Verdict {
+	if errors.Is(err, ErrNotEvaluated) {
+		return Unknown
+	}
+	return Rejected
+}
+
+// trustedMaterial collects the keys a keyless authority is verified with
+// from its TrustRoots or its ca-cert.
+func (e *Evaluator) trustedMaterial(authority Authority) (*TrustedMaterial, error) {
+	keyless := authority.Keyless
+	var certificates []string
+	var tlogs, ctlogs []TransparencyLog
+
+	switch {
+	case keyless.CACert != nil && keyless.CACert.Data != "":
+		certificates = append(certificates, keyless.CACert.Data)
+	case keyless.TrustRootRef != "":
+		keys := e.TrustRoots[keyless.TrustRootRef].SigstoreKeys
+		if keys == nil {
+			return nil, fmt.Errorf("TrustRoot %q is backed by a TUF repository, which is not evaluated", keyless.TrustRootRef)
+		}
+		for _, ca := range keys.CertificateAuthorities {
+			certificates = append(certificates, ca.CertChain)
+		}
+		tlogs, ctlogs = keys.TLogs, keys.CTLogs
+	default:
+		return nil, errors.New("keyless authorities without a trustRootRef or ca-cert use the public Sigstore instance, which is not evaluated")
+	}
+
+	if authority.CTLog != nil && authority.CTLog.TrustRootRef != "" && authority.CTLog.TrustRootRef != keyless.TrustRootRef {
+		if keys := e.TrustRoots[authority.CTLog.TrustRootRef].SigstoreKeys; keys != nil {
+			tlogs = keys.TLogs
+		}
+	}
+	trust, err := trustedMaterial(certificates, tlogs, ctlogs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted material: %v", err)
+	}
+	return trust, nil
+}
+
+// verify verifies a signature, or an attestation if a predicate type is
+// given, and remembers the result.
+func (e *Evaluator) verify(ctx context.Context, image string, authority Authority, trust *TrustedMaterial, predicateType string) error {
+	if e.Verifier == nil {
+		return fmt.Errorf("%w: no signature verifier", ErrNotEvaluated)
+	}
+	key, err := json.Marshal(authority)
+	if err != nil {
+		return err
+	}
+	id := image + "\x00" + string(key) + "\x00" + predicateType
+	if err, ok := e.verified[id]; ok {
+		return err
+	}
+	if predicateType == "" {
+		err = e.Verifier.VerifySignature(ctx, image, authority, trust)
+	} else {
+		err = e.Verifier.VerifyAttestation(ctx, image, authority, trust, predicateType)
+	}
+	if e.verified == nil {
+		e.verified = map[string]error{}
+	}
+	e.verified[id] = err
+	return err
+}
+
+// trustRootRefs lists the TrustRoots an authority depends on.
+func trustRootRefs(authority Authority) []string {
+	var refs []string
+	if authority.Keyless != nil && authority.Keyless.TrustRootRef != "" {
+		refs = append(refs, authority.Keyless.TrustRootRef)
+	}
+	if authority.CTLog != nil && authority.CTLog.TrustRootRef != "" {
+		refs = append(refs, authority.CTLog.TrustRootRef)
+	}
+	if authority.RFC3161Timestamp != nil && authority.RFC3161Timestamp.TrustRootRef != "" {
+		refs = append(refs, authority.RFC3161Timestamp.TrustRootRef)
+	}
+	return refs
+}
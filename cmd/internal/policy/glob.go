@@ -0,0 +1,131 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+const (
+	dockerHubRegistry   = "index.docker.io"
+	dockerHubLibraryOrg = "library"
+)
+
+// validGlob lists the characters a ClusterImagePolicy glob may contain.
+var validGlob = regexp.MustCompile(`^[a-zA-Z0-9-_:/*.@]+$`)
+
+// CompileGlob turns a ClusterImagePolicy glob into a regular expression the
+// way the policy-controller does. `*` matches within a path segment and `**`
+// across segments. The globs `*` and `*/*` are taken to mean Docker Hub
+// images; other globs are not qualified, so they have to name the registry,
+// and a tag or digest, the way image references are normalized.
+func CompileGlob(glob string) (*regexp.Regexp, error) {
+	glob = expandGlob(glob)
+	if !validGlob.MatchString(glob) {
+		return nil, fmt.Errorf("invalid glob %q: only alphanumerics and -_:/*.@ are allowed", glob)
+	}
+
+	glob = strings.ReplaceAll(glob, ".", `\.`)
+	// ** is stashed as # so that the * of its replacement is not replaced.
+	glob = strings.ReplaceAll(glob, "**", "#")
+	glob = strings.ReplaceAll(glob, "*", "[^/]*")
+	glob = strings.ReplaceAll(glob, "#", ".*")
+	return regexp.Compile("^" + glob + "$")
+}
+
+// expandGlob rewrites the globs the policy-controller takes to mean Docker
+// Hub images.
+func expandGlob(glob string) string {
+	switch glob {
+	case "*/*":
+		return dockerHubRegistry + "/*/*"
+	case "*":
+		return dockerHubRegistry + "/" + dockerHubLibraryOrg + "/*"
+	}
+	return glob
+}
+
+// ValidateGlob checks that a glob only contains the characters the
+// policy-controller allows.
+func ValidateGlob(glob string) error {
+	_, err := CompileGlob(glob)
+	return err
+}
+
+// UnmatchableGlob explains why a well formed glob matches no image, or only
+// images written exactly the way the glob is, or returns an empty string.
+// Image references are normalized before they are matched, so a glob such as
+// docker.io/nginx or one without a tag only matches through the
+// policy-controller's fallback to the image as it is written. Globs with a
+// digest are not checked.
+func UnmatchableGlob(glob string) string {
+	re, err := CompileGlob(glob)
+	if err != nil || strings.Contains(glob, "@") {
+		return ""
+	}
+	// Wildcards are replaced with a name that also passes for a registry.
+	expanded := expandGlob(glob)
+	example := strings.NewReplacer("**", "a.b", "*", "a.b").Replace(expanded)
+	ref, err := name.ParseReference(example, name.WeakValidation)
+	if err != nil {
+		return fmt.Sprintf("matches no valid image reference: %v", err)
+	}
+	if re.MatchString(ref.Name()) {
+		return ""
+	}
+	if example == expanded {
+		return fmt.Sprintf("only matches the image written exactly as %q, as it is normalized to %q before it is matched", example, ref.Name())
+	}
+	return fmt.Sprintf("only matches images written exactly the way the glob is, as images such as %q are normalized to %q before they are matched", example, ref.Name())
+}
+
+// MatchGlob reports whether image matches the glob. Like the
+// policy-controller, it matches the normalized reference, such as
+// index.docker.io/library/nginx:latest for nginx, and falls back to the image
+// as it is written.
+func MatchGlob(glob, image string) (bool, error) {
+	re, err := CompileGlob(glob)
+	if err != nil {
+		return false, err
+	}
+	ref, err := name.ParseReference(image, name.WeakValidation)
+	if err != nil {
+		return false, fmt.Errorf("invalid image reference %q: %w", image, err)
+	}
+	if re.MatchString(ref.Name()) {
+		return true, nil
+	}
+	return ref.Name() != image && re.MatchString(image), nil
+}
+
+// GlobRegistry returns the registry a glob names, or "" if the glob matches
+// images of more than one registry.
+func GlobRegistry(glob string) string {
+	registry, _, _ := strings.Cut(qualifyGlob(expandGlob(glob)), "/")
+	if strings.Contains(registry, "*") {
+		return ""
+	}
+	return registry
+}
+
+// qualifyGlob prefixes globs without a registry the way image references
+// without one are resolved, to tell the registry the images they match are
+// pulled from.
+func qualifyGlob(glob string) string {
+	if strings.HasPrefix(glob, "*") {
+		return glob
+	}
+	first, rest, found := strings.Cut(glob, "/")
+	if found && first == "docker.io" {
+		return dockerHubRegistry + "/" + rest
+	}
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return glob
+	}
+	if !found || rest == "" {
+		return dockerHubRegistry + "/" + dockerHubLibraryOrg + "/" + glob
+	}
+	return dockerHubRegistry + "/" + glob
+}
@@ -0,0 +1,174 @@
+package policy
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+)
+
+// Fulcio certificate extensions holding the OIDC issuer of the signer.
+var (
+	oidIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// rekorBundle is the transparency log entry cosign attaches to keyless
+// signatures.
+type rekorBundle struct {
+	SignedEntryTimestamp []byte `json:"SignedEntryTimestamp"`
+	Payload              struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogIndex       int64  `json:"logIndex"`
+		LogID          string `json:"logID"`
+	} `json:"Payload"`
+}
+
+// bundleMediaType is the media type of the Sigstore bundle the cosign
+// annotations are converted into. Version 0.1 bundles prove the inclusion of
+// the transparency log entry with its signed entry timestamp.
+const bundleMediaType = "application/vnd.dev.sigstore.bundle+json;version=0.1"
+
+// verifyKeyless checks a signature made with a short-lived Fulcio
+// certificate. The certificate and the transparency log bundle of the
+// signature annotations are added to the bundle holding the signature, which
+// sigstore-go verifies: the log entry has to be signed by a trusted
+// transparency log and record the same signature, certificate and digest,
+// the certificate has to chain up to the trusted certificate authorities at
+// the time the entry was logged, carry a signed certificate timestamp of a
+// trusted certificate transparency log unless insecureIgnoreSCT is set, and
+// its identity has to match one of the authority.
+func verifyKeyless(keyless *KeylessRef, trust *TrustedMaterial, annotations map[string]string, b *protobundle.Bundle, artifact verify.ArtifactPolicyOption) error {
+	if annotations[CertificateAnnotation] == "" {
+		return errors.New("signature has no certificate")
+	}
+	certs, err := ParseCertificates(annotations[CertificateAnnotation])
+	if err != nil {
+		return fmt.Errorf("signature certificate: %w", err)
+	}
+	leaf := certs[0]
+	entry, err := tlogEntry(annotations[BundleAnnotation])
+	if err != nil {
+		return err
+	}
+
+	b.MediaType = bundleMediaType
+	b.VerificationMaterial = &protobundle.VerificationMaterial{
+		Content: &protobundle.VerificationMaterial_X509CertificateChain{X509CertificateChain: &protocommon.X509CertificateChain{
+			Certificates: []*protocommon.X509Certificate{{RawBytes: leaf.Raw}},
+		}},
+		TlogEntries: []*protorekor.TransparencyLogEntry{entry},
+	}
+	entity, err := bundle.NewBundle(b)
+	if err != nil {
+		return fmt.Errorf("transparency log entry cannot be verified: %v", err)
+	}
+
+	options := []verify.VerifierOption{verify.WithTransparencyLog(1), verify.WithIntegratedTimestamps(1)}
+	if !keyless.InsecureIgnoreSCT {
+		options = append(options, verify.WithSignedCertificateTimestamps(1))
+	}
+	verifier, err := verify.NewVerifier(trust.Root, options...)
+	if err != nil {
+		return err
+	}
+	policy, err := identityPolicy(keyless.Identities)
+	if err != nil {
+		return err
+	}
+	if _, err := verifier.Verify(entity, verify.NewPolicy(artifact, policy...)); err != nil {
+		var noMatch *verify.ErrNoMatchingCertificateIdentity
+		if errors.As(err, &noMatch) {
+			subject, issuer := CertificateIdentity(leaf)
+			return fmt.Errorf("certificate identity %q issued by %q matches none of the %d identities", subject, issuer, len(keyless.Identities))
+		}
+		return err
+	}
+	return nil
+}
+
+// tlogEntry converts the transparency log bundle of a signature. The kind of
+// the entry is read from its body; entries of kinds sigstore-go cannot bind
+// to the signature fail the verification.
+func tlogEntry(annotation string) (*protorekor.TransparencyLogEntry, error) {
+	if annotation == "" {
+		return nil, errors.New("signature has no transparency log bundle")
+	}
+	var rb rekorBundle
+	if err := json.Unmarshal([]byte(annotation), &rb); err != nil {
+		return nil, fmt.Errorf("invalid transparency log bundle: %v", err)
+	}
+	body, err := base64.StdEncoding.DecodeString(rb.Payload.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transparency log entry: %v", err)
+	}
+	var kind struct {
+		Kind       string `json:"kind"`
+		APIVersion string `json:"apiVersion"`
+	}
+	if err := json.Unmarshal(body, &kind); err != nil {
+		return nil, fmt.Errorf("invalid transparency log entry: %v", err)
+	}
+	logID, err := hex.DecodeString(rb.Payload.LogID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transparency log ID: %v", err)
+	}
+	return &protorekor.TransparencyLogEntry{
+		LogIndex:          rb.Payload.LogIndex,
+		LogId:             &protocommon.LogId{KeyId: logID},
+		KindVersion:       &protorekor.KindVersion{Kind: kind.Kind, Version: kind.APIVersion},
+		IntegratedTime:    rb.Payload.IntegratedTime,
+		InclusionPromise:  &protorekor.InclusionPromise{SignedEntryTimestamp: rb.SignedEntryTimestamp},
+		CanonicalizedBody: body,
+	}, nil
+}
+
+// identityPolicy requires the certificate to match one of the identities.
+// Authorities without identities accept any identity.
+func identityPolicy(identities []Identity) ([]verify.PolicyOption, error) {
+	if len(identities) == 0 {
+		return []verify.PolicyOption{verify.WithoutIdentitiesUnsafe()}, nil
+	}
+	var options []verify.PolicyOption
+	for _, identity := range identities {
+		id, err := verify.NewShortCertificateIdentity(identity.Issuer, identity.IssuerRegExp, identity.Subject, identity.SubjectRegExp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity: %w", err)
+		}
+		options = append(options, verify.WithCertificateIdentity(id))
+	}
+	return options, nil
+}
+
+// CertificateIdentity returns the subject alternative name and OIDC issuer
+// of a Fulcio certificate.
+func CertificateIdentity(cert *x509.Certificate) (subject, issuer string) {
+	switch {
+	case len(cert.EmailAddresses) > 0:
+		subject = cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		subject = cert.URIs[0].String()
+	}
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerV2):
+			var value string
+			if _, err := asn1.Unmarshal(ext.Value, &value); err == nil {
+				issuer = value
+			}
+		case ext.Id.Equal(oidIssuer) && issuer == "":
+			issuer = string(ext.Value)
+		}
+	}
+	return subject, issuer
+}
@@ -0,0 +1,159 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// ErrNotFound is wrapped by Registry errors for images it does not have.
+var ErrNotFound = errors.New("not found")
+
+// RefNameAnnotation names the images of an OCI layout.
+const RefNameAnnotation = "org.opencontainers.image.ref.name"
+
+// Registry serves images and their signatures.
+type Registry interface {
+	// Digest resolves a reference to the digest of its manifest.
+	Digest(ctx context.Context, ref name.Reference) (v1.Hash, error)
+	// Image fetches an image.
+	Image(ctx context.Context, ref name.Reference) (v1.Image, error)
+	// Referrers lists the artifacts that refer to a manifest.
+	Referrers(ctx context.Context, digest name.Digest) ([]v1.Descriptor, error)
+}
+
+// Remote is the registry an image reference points at.
+type Remote struct {
+	// Keychain authenticates against the registries. Defaults to the
+	// docker config of the process.
+	Keychain authn.Keychain
+	// Options are passed to every registry request.
+	Options []remote.Option
+}
+
+func (r *Remote) options(ctx context.Context) []remote.Option {
+	keychain := r.Keychain
+	if keychain == nil {
+		keychain = authn.DefaultKeychain
+	}
+	return append([]remote.Option{remote.WithContext(ctx), remote.WithAuthFromKeychain(keychain)}, r.Options...)
+}
+
+func (r *Remote) Digest(ctx context.Context, ref name.Reference) (v1.Hash, error) {
+	desc, err := remote.Head(ref, r.options(ctx)...)
+	if err != nil {
+		return v1.Hash{}, remoteError(err)
+	}
+	return desc.Digest, nil
+}
+
+func (r *Remote) Image(ctx context.Context, ref name.Reference) (v1.Image, error) {
+	img, err := remote.Image(ref, r.options(ctx)...)
+	if err != nil {
+		return nil, remoteError(err)
+	}
+	return img, nil
+}
+
+func (r *Remote) Referrers(ctx context.Context, digest name.Digest) ([]v1.Descriptor, error) {
+	index, err := remote.Referrers(digest, r.options(ctx)...)
+	if err != nil {
+		return nil, remoteError(err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Manifests, nil
+}
+
+func remoteError(err error) error {
+	var terr *transport.Error
+	if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
+
+// Layout serves images from an OCI image layout, such as the ones written by
+// `crane pull --format=oci` or `skopeo copy`. Images are looked up by digest
+// or by their org.opencontainers.image.ref.name annotation, which may hold
+// the full reference or only its tag.
+type Layout struct {
+	Path layout.Path
+}
+
+func (l *Layout) find(ref name.Reference) (v1.ImageIndex, v1.Descriptor, error) {
+	index, err := l.Path.ImageIndex()
+	if err != nil {
+		return nil, v1.Descriptor{}, err
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, v1.Descriptor{}, err
+	}
+	for _, desc := range manifest.Manifests {
+		if digest, ok := ref.(name.Digest); ok {
+			if desc.Digest.String() == digest.DigestStr() {
+				return index, desc, nil
+			}
+			continue
+		}
+		switch desc.Annotations[RefNameAnnotation] {
+		case ref.String(), ref.Name(), ref.Identifier():
+			return index, desc, nil
+		}
+	}
+	return nil, v1.Descriptor{}, fmt.Errorf("%w: %s is not in the OCI layout %s", ErrNotFound, ref, l.Path)
+}
+
+func (l *Layout) Digest(_ context.Context, ref name.Reference) (v1.Hash, error) {
+	_, desc, err := l.find(ref)
+	return desc.Digest, err
+}
+
+func (l *Layout) Image(_ context.Context, ref name.Reference) (v1.Image, error) {
+	index, desc, err := l.find(ref)
+	if err != nil {
+		return nil, err
+	}
+	return index.Image(desc.Digest)
+}
+
+// Referrers lists the manifests of the layout whose subject is the digest.
+func (l *Layout) Referrers(_ context.Context, digest name.Digest) ([]v1.Descriptor, error) {
+	index, err := l.Path.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	var referrers []v1.Descriptor
+	for _, desc := range manifest.Manifests {
+		img, err := index.Image(desc.Digest)
+		if err != nil {
+			continue
+		}
+		m, err := img.Manifest()
+		if err != nil || m.Subject == nil || m.Subject.Digest.String() != digest.DigestStr() {
+			continue
+		}
+		artifact := desc
+		artifact.ArtifactType = m.ArtifactType
+		if artifact.ArtifactType == "" {
+			artifact.ArtifactType = string(m.Config.MediaType)
+		}
+		referrers = append(referrers, artifact)
+	}
+	return referrers, nil
+}
@@ -0,0 +1,286 @@
+package policy_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	"github.com/sigstore/policy-controller/pkg/apis/glob"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeVerifier accepts the images it lists and fails the others with err.
+type fakeVerifier struct {
+	signed map[string]bool
+	err    error
+	calls  int
+}
+
+func (v *fakeVerifier) VerifySignature(_ context.Context, image string, _ policy.Authority, _ *policy.TrustedMaterial) error {
+	return v.verify(image)
+}
+
+func (v *fakeVerifier) VerifyAttestation(_ context.Context, image string, _ policy.Authority, _ *policy.TrustedMaterial, predicateType string) error {
+	return v.verify(image + "@" + predicateType)
+}
+
+func (v *fakeVerifier) verify(image string) error {
+	v.calls++
+	if v.signed[image] {
+		return nil
+	}
+	if v.err != nil {
+		return v.err
+	}
+	return errors.New("no signatures found")
+}
+
+var pods = policy.Resource{GroupVersionResource: schema.GroupVersionResource{Version: "v1", Resource: "pods"}}
+
+func TestMatchGlob(t *testing.T) {
+	const digest = "sha256:5504f2a95018e3d8a52d80d9e1a128c6ea337581808ff9fe96f5628ce2336350"
+	tests := []struct {
+		glob, image string
+		expected    bool
+	}{
+		{"**", "nginx", true},
+		{"*", "nginx", true},
+		{"*", "nginx:1.25", true},
+		{"*/*", "myuser/myapp", true},
+		{"*/**", "ghcr.io/foo", true},
+		{"nginx", "nginx", true},
+		{"nginx", "nginx:1.25", false},
+		{"nginx*", "nginx:1.25", true},
+		{"docker.io/library/nginx*", "nginx:1.25", false},
+		{"index.docker.io/library/nginx", "nginx", false},
+		{"index.docker.io/library/nginx:*", "nginx", true},
+		{"index.docker.io/library/*", "docker.io/library/nginx:1.25", true},
+		{"index.docker.io/myuser/*", "myuser/myapp", true},
+		{"quay.io/securesign/*", "quay.io/securesign/cli:latest", true},
+		{"quay.io/securesign/*", "quay.io/securesign/team/cli", false},
+		{"quay.io/securesign/**", "quay.io/securesign/team/cli", true},
+		{"registry.redhat.io/rhtas/app", "registry.redhat.io/rhtas/app", true},
+		{"registry.redhat.io/rhtas/app", "registry.redhat.io/rhtas/app:1.0", false},
+		{"registry.redhat.io/rhtas/app", "registry.redhat.io/rhtas/app@" + digest, false},
+		{"registry.redhat.io/rhtas/app:**", "registry.redhat.io/rhtas/app:1.0", true},
+		{"registry.redhat.io/rhtas/app@" + digest, "registry.redhat.io/rhtas/app@" + digest, true},
+		{"registry.redhat.io/rhtas/app@sha256:*", "registry.redhat.io/rhtas/app@" + digest, true},
+		{"registry.local:5000/app*", "registry.local:5000/application:v2", true},
+		{"registry.local:5000/app*", "quay.io/app", false},
+		{"ghcr.io/foo", "prefix-ghcr.io/foo", false},
+		{"ghcr.io/**", "ghcrxio/foo", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.glob+" "+tc.image, func(t *testing.T) {
+			matched, err := policy.MatchGlob(tc.glob, tc.image)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, matched)
+
+			upstream, err := glob.Match(tc.glob, tc.image)
+			require.NoError(t, err)
+			require.Equal(t, upstream, matched, "the policy-controller matches differently")
+		})
+	}
+
+	_, err := policy.CompileGlob("quay.io/[a-z]+")
+	require.Error(t, err)
+	_, err = policy.MatchGlob("**", "invalid&name")
+	require.Error(t, err)
+}
+
+func TestGlobRegistry(t *testing.T) {
+	require.Equal(t, "quay.io", policy.GlobRegistry("quay.io/securesign/**"))
+	require.Equal(t, "localhost:5000", policy.GlobRegistry("localhost:5000/app"))
+	require.Equal(t, "index.docker.io", policy.GlobRegistry("nginx"))
+	require.Equal(t, "index.docker.io", policy.GlobRegistry("docker.io/library/*"))
+	require.Equal(t, "index.docker.io", policy.GlobRegistry("*"))
+	require.Empty(t, policy.GlobRegistry("**"))
+	require.Empty(t, policy.GlobRegistry("*.example.com/app"))
+}
+
+func TestValidateGlob(t *testing.T) {
+	tests := []struct {
+		glob        string
+		err         string
+		unmatchable string
+	}{
+		{glob: "**"},
+		{glob: "***"},
+		{glob: "*"},
+		{glob: "*/*"},
+		{glob: "*/nginx:*"},
+		{glob: "index.docker.io/library/nginx:**"},
+		{glob: "quay.io/securesign/cli@sha256:*"},
+		{glob: "quay.io/securesign/cli@latest"},
+		{glob: "quay.io/[a-z]+", err: "only alphanumerics and -_:/*.@ are allowed"},
+		{glob: "nginx", unmatchable: `only matches the image written exactly as "nginx", as it is normalized to "index.docker.io/library/nginx:latest"`},
+		{glob: "docker.io/nginx", unmatchable: `only matches the image written exactly as "docker.io/nginx", as it is normalized to "index.docker.io/library/nginx:latest"`},
+		{glob: "docker.io/library/nginx*", unmatchable: `as images such as "docker.io/library/nginxa.b" are normalized to "index.docker.io/library/nginxa.b:latest"`},
+		{glob: "quay.io/SecureSign/*", unmatchable: "matches no valid image reference"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.glob, func(t *testing.T) {
+			err := policy.ValidateGlob(tc.glob)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			_, upstream := glob.Compile(tc.glob)
+			require.NoError(t, upstream, "the policy-controller rejects the glob")
+			if tc.unmatchable == "" {
+				require.Empty(t, policy.UnmatchableGlob(tc.glob))
+			} else {
+				require.Contains(t, policy.UnmatchableGlob(tc.glob), tc.unmatchable)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	keyAuthority := policy.Authority{Name: "key", Key: &policy.KeyRef{Data: "pem"}}
+	keyless := policy.Authority{Name: "keyless", Keyless: &policy.KeylessRef{URL: "https://fulcio.example.com", TrustRootRef: "trust-root"}}
+	cip := func(name, glob, mode string, authorities ...policy.Authority) policy.ClusterImagePolicy {
+		return policy.ClusterImagePolicy{Name: name, Spec: policy.ClusterImagePolicySpec{
+			Images:      []policy.ImagePattern{{Glob: glob}},
+			Authorities: authorities,
+			Mode:        mode,
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		policies   []policy.ClusterImagePolicy
+		trustRoots map[string]policy.TrustRoot
+		noMatch    policy.NoMatchPolicy
+		verifier   *fakeVerifier
+		image      string
+		expected   policy.Verdict
+	}{
+		{
+			name:     "signed image",
+			policies: []policy.ClusterImagePolicy{cip("signed", "quay.io/**", "", keyAuthority)},
+			verifier: &fakeVerifier{signed: map[string]bool{"quay.io/app:v1": true}},
+			image:    "quay.io/app:v1",
+			expected: policy.Allowed,
+		},
+		{
+			name:     "unsigned image",
+			policies: []policy.ClusterImagePolicy{cip("signed", "quay.io/**", "", keyAuthority)},
+			verifier: &fakeVerifier{},
+			image:    "quay.io/app:v1",
+			expected: policy.Rejected,
+		},
+		{
+			name:     "unsigned image in warn mode",
+			policies: []policy.ClusterImagePolicy{cip("signed", "quay.io/**", policy.ModeWarn, keyAuthority)},
+			verifier: &fakeVerifier{},
+			image:    "quay.io/app:v1",
+			expected: policy.Warned,
+		},
+		{
+			name:     "unreachable registry",
+			policies: []policy.ClusterImagePolicy{cip("signed", "quay.io/**", "", keyAuthority)},
+			verifier: &fakeVerifier{err: policy.ErrNotEvaluated},
+			image:    "quay.io/app:v1",
+			expected: policy.Unknown,
+		},
+		{
+			name:     "no matching policy is denied by default",
+			policies: []policy.ClusterImagePolicy{cip("signed", "quay.io/**", "", keyAuthority)},
+			image:    "nginx",
+			expected: policy.Rejected,
+		},
+		{
+			name:     "no matching policy is allowed",
+			policies: []policy.ClusterImagePolicy{cip("signed", "quay.io/**", "", keyAuthority)},
+			noMatch:  policy.NoMatchAllow,
+			image:    "nginx",
+			expected: policy.Allowed,
+		},
+		{
+			name:       "keyless authority",
+			policies:   []policy.ClusterImagePolicy{cip("keyless", "**", "", keyless)},
+			trustRoots: map[string]policy.TrustRoot{"trust-root": {Name: "trust-root", Ready: true}},
+			image:      "nginx",
+			expected:   policy.Unknown,
+		},
+		{
+			name:       "keyless authority with a missing TrustRoot",
+			policies:   []policy.ClusterImagePolicy{cip("keyless", "**", "", keyless)},
+			trustRoots: map[string]policy.TrustRoot{},
+			image:      "nginx",
+			expected:   policy.Rejected,
+		},
+		{
+			name: "static pass satisfies the policy",
+			policies: []policy.ClusterImagePolicy{
+				cip("mixed", "**", "", keyless, policy.Authority{Static: &policy.StaticRef{Action: policy.StaticPass}}),
+			},
+			image:    "nginx",
+			expected: policy.Allowed,
+		},
+		{
+			name: "every matching policy has to pass",
+			policies: []policy.ClusterImagePolicy{
+				cip("allow-all", "**", "", policy.Authority{Static: &policy.StaticRef{Action: policy.StaticPass}}),
+				cip("deny-quay", "quay.io/**", "", policy.Authority{Static: &policy.StaticRef{Action: policy.StaticFail}}),
+			},
+			image:    "quay.io/app:v1",
+			expected: policy.Rejected,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			evaluator := &policy.Evaluator{Policies: tc.policies, TrustRoots: tc.trustRoots, NoMatchPolicy: tc.noMatch}
+			if tc.verifier != nil {
+				evaluator.Verifier = tc.verifier
+			}
+			result := evaluator.Evaluate(context.Background(), tc.image, pods)
+			require.Equal(t, tc.expected, result.Verdict, result.Messages)
+		})
+	}
+}
+
+func TestEvaluateMatchesResources(t *testing.T) {
+	cip := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "deployments-only"},
+		"spec": map[string]interface{}{
+			"images":      []interface{}{map[string]interface{}{"glob": "**"}},
+			"authorities": []interface{}{map[string]interface{}{"static": map[string]interface{}{"action": "fail"}}},
+			"match": []interface{}{map[string]interface{}{
+				"group": "apps", "version": "v1", "resource": "deployments",
+				"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"signed": "true"}},
+			}},
+		},
+	}}
+	parsed, err := policy.FromUnstructured(cip)
+	require.NoError(t, err)
+	evaluator := &policy.Evaluator{Policies: []policy.ClusterImagePolicy{parsed}, NoMatchPolicy: policy.NoMatchAllow}
+
+	deployment := policy.Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		Labels:               map[string]string{"signed": "true"},
+	}
+	require.Equal(t, policy.Rejected, evaluator.Evaluate(context.Background(), "nginx", deployment).Verdict)
+	require.Equal(t, policy.Allowed, evaluator.Evaluate(context.Background(), "nginx", pods).Verdict)
+}
+
+func TestEvaluateVerifiesOnce(t *testing.T) {
+	verifier := &fakeVerifier{signed: map[string]bool{"quay.io/app:v1": true}}
+	evaluator := &policy.Evaluator{
+		Policies: []policy.ClusterImagePolicy{{Name: "signed", Spec: policy.ClusterImagePolicySpec{
+			Images:      []policy.ImagePattern{{Glob: "quay.io/**"}},
+			Authorities: []policy.Authority{{Key: &policy.KeyRef{Data: "pem"}}},
+		}}},
+		Verifier: verifier,
+	}
+	for range 3 {
+		require.Equal(t, policy.Allowed, evaluator.Evaluate(context.Background(), "quay.io/app:v1", pods).Verdict)
+	}
+	require.Equal(t, 1, verifier.calls)
+}
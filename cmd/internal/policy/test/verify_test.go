@@ -0,0 +1,499 @@
+package policy_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	cttls "github.com/google/certificate-transparency-go/tls"
+	ctx509util "github.com/google/certificate-transparency-go/x509util"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	"github.com/securesign/policy-controller-operator/cmd/internal/testutil"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIssuer  = "https://keycloak.example.com/realms/trusted-artifact-signer"
+	testSubject = "jdoe@redhat.com"
+	sbomType    = "https://cyclonedx.org/bom"
+)
+
+func TestRegistryVerifier(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	signer := newKey(t)
+	other := newKey(t)
+
+	signed := pushImage(t, host+"/signed:v1")
+	pushSignatures(t, signed, "sig", signLayer(t, signed, signer, nil))
+	pushSignatures(t, signed, "att", attestLayer(t, signed, sbomType, signer, nil))
+	unsigned := pushImage(t, host+"/unsigned:v1")
+
+	verifier := &policy.RegistryVerifier{}
+	ctx := context.Background()
+	withKey := func(key *ecdsa.PrivateKey) policy.Authority {
+		return policy.Authority{Key: publicKey(t, key)}
+	}
+
+	require.NoError(t, verifier.VerifySignature(ctx, signed.String(), withKey(signer), nil))
+	require.NoError(t, verifier.VerifySignature(ctx, host+"/signed:v1", withKey(signer), nil))
+	require.NoError(t, verifier.VerifyAttestation(ctx, signed.String(), withKey(signer), nil, "cyclonedx"))
+
+	err := verifier.VerifySignature(ctx, signed.String(), withKey(other), nil)
+	require.ErrorContains(t, err, "signature does not match the key")
+	require.False(t, errors.Is(err, policy.ErrNotEvaluated))
+
+	err = verifier.VerifyAttestation(ctx, signed.String(), withKey(signer), nil, "slsaprovenance")
+	require.ErrorContains(t, err, "no https://slsa.dev/provenance/v0.2 attestation found")
+
+	err = verifier.VerifySignature(ctx, unsigned.String(), withKey(signer), nil)
+	require.ErrorContains(t, err, "no signatures found")
+
+	err = verifier.VerifySignature(ctx, host+"/missing:v1", withKey(signer), nil)
+	require.ErrorIs(t, err, policy.ErrNotEvaluated)
+}
+
+func TestRegistryVerifierKeylessFromLayout(t *testing.T) {
+	sigstore := newSigstore(t)
+	path, err := layout.Write(t.TempDir(), empty.Index)
+	require.NoError(t, err)
+
+	signed := appendSigned(t, path, "registry.example.com/app:v1", func(image name.Digest) mutate.Addendum {
+		return sigstore.sign(t, image, testSubject)
+	})
+	withoutSCT := appendSigned(t, path, "registry.example.com/app:v2", func(image name.Digest) mutate.Addendum {
+		return sigstore.signWithoutSCT(t, image, testSubject)
+	})
+	// The transparency log entry records another signature of the image.
+	unbound := appendSigned(t, path, "registry.example.com/app:v3", func(image name.Digest) mutate.Addendum {
+		layer := sigstore.sign(t, image, testSubject)
+		layer.Annotations[policy.BundleAnnotation] = sigstore.sign(t, image, testSubject).Annotations[policy.BundleAnnotation]
+		return layer
+	})
+
+	verifier := &policy.RegistryVerifier{Registry: &policy.Layout{Path: path}}
+	authority := func(subject string) policy.Authority {
+		return policy.Authority{Keyless: &policy.KeylessRef{Identities: []policy.Identity{{Issuer: testIssuer, Subject: subject}}}}
+	}
+	ctx := context.Background()
+
+	require.NoError(t, verifier.VerifySignature(ctx, "registry.example.com/app:v1", authority(testSubject), sigstore.trust(t)))
+	err = verifier.VerifySignature(ctx, signed.String(), authority("someone@example.com"), sigstore.trust(t))
+	require.ErrorContains(t, err, `certificate identity "jdoe@redhat.com"`)
+
+	untrusted := newSigstore(t)
+	err = verifier.VerifySignature(ctx, signed.String(), authority(testSubject), untrusted.trust(t))
+	require.ErrorContains(t, err, "none of 1 signature layers")
+
+	err = verifier.VerifySignature(ctx, withoutSCT.String(), authority(testSubject), sigstore.trust(t))
+	require.ErrorContains(t, err, "SCT")
+	ignoreSCT := authority(testSubject)
+	ignoreSCT.Keyless.InsecureIgnoreSCT = true
+	require.NoError(t, verifier.VerifySignature(ctx, withoutSCT.String(), ignoreSCT, sigstore.trust(t)))
+
+	err = verifier.VerifySignature(ctx, unbound.String(), authority(testSubject), sigstore.trust(t))
+	require.ErrorContains(t, err, "transparency log signature does not match")
+}
+
+// appendSigned writes a random image and the signature image made by sign to
+// the layout, and returns the digest of the image.
+func appendSigned(t *testing.T, path layout.Path, ref string, sign func(name.Digest) mutate.Addendum) name.Digest {
+	t.Helper()
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	hash, err := img.Digest()
+	require.NoError(t, err)
+	image, err := name.NewDigest(strings.Split(ref, ":")[0] + "@" + hash.String())
+	require.NoError(t, err)
+
+	require.NoError(t, path.AppendImage(img, layout.WithAnnotations(map[string]string{policy.RefNameAnnotation: ref})))
+	sig, err := mutate.Append(empty.Image, sign(image))
+	require.NoError(t, err)
+	sigTag := strings.Replace(hash.String(), ":", "-", 1) + ".sig"
+	require.NoError(t, path.AppendImage(sig, layout.WithAnnotations(map[string]string{policy.RefNameAnnotation: sigTag})))
+	return image
+}
+
+func TestExplainKeyless(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	sigstore := newSigstore(t)
+	image := pushImage(t, host+"/app:v1")
+	pushSignatures(t, image, "sig", sigstore.sign(t, image, testSubject))
+	pushSignatures(t, image, "att", sigstore.attest(t, image, sbomType, testSubject), sigstore.attestLegacy(t, image, "https://spdx.dev/Document", testSubject))
+
+	authority := policy.Authority{
+		Name: "rhtas",
+		Keyless: &policy.KeylessRef{
+			TrustRootRef: "trust-root",
+			Identities:   []policy.Identity{{IssuerRegExp: ".*keycloak.*", SubjectRegExp: ".*@redhat.com"}},
+		},
+		CTLog: &policy.TLog{TrustRootRef: "trust-root"},
+	}
+	withAttestations := authority
+	withAttestations.Attestations = []policy.Attestation{
+		{Name: "sbom", PredicateType: "cyclonedx"},
+		{Name: "provenance", PredicateType: "slsaprovenance", Policy: &policy.AttestationPolicy{Type: "cue"}},
+		{Name: "spdx", PredicateType: "spdx"},
+	}
+	evaluator := &policy.Evaluator{
+		Policies: []policy.ClusterImagePolicy{
+			{Name: "signed", Spec: policy.ClusterImagePolicySpec{Images: []policy.ImagePattern{{Glob: host + "/**"}}, Authorities: []policy.Authority{authority}}},
+			{Name: "attested", Spec: policy.ClusterImagePolicySpec{Images: []policy.ImagePattern{{Glob: host + "/**"}}, Authorities: []policy.Authority{withAttestations}}},
+			{Name: "other", Spec: policy.ClusterImagePolicySpec{Images: []policy.ImagePattern{{Glob: "quay.io/**"}}}},
+		},
+		TrustRoots: map[string]policy.TrustRoot{"trust-root": sigstore.trustRoot},
+		Verifier:   &policy.RegistryVerifier{},
+	}
+
+	explanation := evaluator.Explain(context.Background(), image.String(), pods)
+	require.Equal(t, policy.Rejected, explanation.Verdict)
+	require.Len(t, explanation.Policies, 3)
+
+	signedPolicy := explanation.Policies[0]
+	require.True(t, signedPolicy.Matched)
+	require.Equal(t, host+"/**", signedPolicy.Glob)
+	require.Equal(t, policy.Allowed, signedPolicy.Verdict)
+	require.Equal(t, "signature verified", signedPolicy.Authorities[0].Message)
+
+	attested := explanation.Policies[1].Authorities[0]
+	require.Equal(t, policy.Rejected, attested.Verdict)
+	require.Equal(t, policy.Allowed, attested.Attestations[0].Verdict)
+	require.Equal(t, policy.Rejected, attested.Attestations[1].Verdict)
+	require.Contains(t, attested.Attestations[1].Message, "no https://slsa.dev/provenance/v0.2 attestation found")
+	// Entries of kinds that cannot be bound to the attestation fail.
+	require.Equal(t, policy.Rejected, attested.Attestations[2].Verdict)
+	require.Contains(t, attested.Attestations[2].Message, "transparency log entry cannot be verified")
+
+	require.False(t, explanation.Policies[2].Matched)
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func pushImage(t *testing.T, ref string) name.Digest {
+	t.Helper()
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	tag, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(tag, img))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	return tag.Context().Digest(digest.String())
+}
+
+// pushSignatures pushes the signature or attestation image of an image the
+// way cosign stores it.
+func pushSignatures(t *testing.T, image name.Digest, suffix string, layers ...mutate.Addendum) {
+	t.Helper()
+	img, err := mutate.Append(empty.Image, layers...)
+	require.NoError(t, err)
+	tag := image.Context().Tag(strings.Replace(image.DigestStr(), ":", "-", 1) + "." + suffix)
+	require.NoError(t, remote.Write(tag, img))
+}
+
+func simpleSigningPayload(image name.Digest) []byte {
+	return fmt.Appendf(nil, `{"critical":{"identity":{"docker-reference":%q},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`,
+		image.Context().Name(), image.DigestStr())
+}
+
+// signLayer signs the image like `cosign sign --key`, adding the given
+// annotations to the signature layer.
+func signLayer(t *testing.T, image name.Digest, key *ecdsa.PrivateKey, annotations map[string]string) mutate.Addendum {
+	t.Helper()
+	payload := simpleSigningPayload(image)
+	signature := sign(t, key, payload)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[policy.SignatureAnnotation] = base64.StdEncoding.EncodeToString(signature)
+	return mutate.Addendum{
+		Layer:       static.NewLayer(payload, types.MediaType("application/vnd.dev.cosign.simplesigning.v1+json")),
+		Annotations: annotations,
+	}
+}
+
+// attestLayer attests the image like `cosign attest --key`.
+func attestLayer(t *testing.T, image name.Digest, predicateType string, key *ecdsa.PrivateKey, annotations map[string]string) mutate.Addendum {
+	t.Helper()
+	_, hex, _ := strings.Cut(image.DigestStr(), ":")
+	statement := fmt.Appendf(nil, `{"_type":"https://in-toto.io/Statement/v0.1","predicateType":%q,"subject":[{"name":%q,"digest":{"sha256":%q}}],"predicate":{}}`,
+		predicateType, image.Context().Name(), hex)
+	pae := fmt.Appendf(nil, "DSSEv1 %d %s %d %s", len("application/vnd.in-toto+json"), "application/vnd.in-toto+json", len(statement), statement)
+	envelope, err := json.Marshal(map[string]interface{}{
+		"payloadType": "application/vnd.in-toto+json",
+		"payload":     base64.StdEncoding.EncodeToString(statement),
+		"signatures":  []map[string]string{{"sig": base64.StdEncoding.EncodeToString(sign(t, key, pae))}},
+	})
+	require.NoError(t, err)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[policy.SignatureAnnotation] = ""
+	return mutate.Addendum{
+		Layer:       static.NewLayer(envelope, types.MediaType("application/vnd.dsse.envelope.v1+json")),
+		Annotations: annotations,
+	}
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, payload []byte) []byte {
+	t.Helper()
+	digest := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	return signature
+}
+
+func publicKey(t *testing.T, key crypto.Signer) *policy.KeyRef {
+	t.Helper()
+	return &policy.KeyRef{Data: testutil.EncodePublicKey(t, key.Public())}
+}
+
+// sigstore is a certificate authority, transparency log and certificate
+// transparency log that issue keyless signatures the way Fulcio and Rekor do.
+type sigstore struct {
+	ca        *x509.Certificate
+	caKey     *ecdsa.PrivateKey
+	rekorKey  *ecdsa.PrivateKey
+	ctKey     *ecdsa.PrivateKey
+	trustRoot policy.TrustRoot
+}
+
+func newSigstore(t *testing.T) *sigstore {
+	t.Helper()
+	s := &sigstore{caKey: newKey(t), rekorKey: newKey(t), ctKey: newKey(t)}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"Red Hat"}, CommonName: "fulcio"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, s.caKey.Public(), s.caKey)
+	require.NoError(t, err)
+	s.ca, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	s.trustRoot = policy.TrustRoot{Name: "trust-root", Ready: true, SigstoreKeys: &policy.SigstoreKeys{
+		CertificateAuthorities: []policy.CertificateAuthority{{CertChain: base64.StdEncoding.EncodeToString(chain)}},
+		TLogs:                  []policy.TransparencyLog{{PublicKey: base64.StdEncoding.EncodeToString([]byte(testutil.EncodePublicKey(t, s.rekorKey.Public())))}},
+		CTLogs:                 []policy.TransparencyLog{{PublicKey: base64.StdEncoding.EncodeToString([]byte(testutil.EncodePublicKey(t, s.ctKey.Public())))}},
+	}}
+	return s
+}
+
+// trust is the trusted material of the TrustRoot of the sigstore.
+func (s *sigstore) trust(t *testing.T) *policy.TrustedMaterial {
+	t.Helper()
+	logs := func(key *ecdsa.PrivateKey) map[string]*root.TransparencyLog {
+		return map[string]*root.TransparencyLog{hex.EncodeToString(logID(t, key)): {
+			ID:                  logID(t, key),
+			ValidityPeriodStart: time.Now().Add(-time.Hour),
+			HashFunc:            crypto.SHA256,
+			PublicKey:           key.Public(),
+			SignatureHashFunc:   crypto.SHA256,
+		}}
+	}
+	trustedRoot, err := root.NewTrustedRoot(root.TrustedRootMediaType01,
+		[]root.CertificateAuthority{&root.FulcioCertificateAuthority{Root: s.ca}}, logs(s.ctKey), nil, logs(s.rekorKey))
+	require.NoError(t, err)
+	return &policy.TrustedMaterial{Root: trustedRoot}
+}
+
+func logID(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	id := sha256.Sum256(der)
+	return id[:]
+}
+
+// certificate issues a short-lived signing certificate for the subject. The
+// certificate embeds a signed certificate timestamp unless withSCT is false.
+func (s *sigstore) certificate(t *testing.T, key *ecdsa.PrivateKey, subject string, withSCT bool) string {
+	t.Helper()
+	issuer, err := asn1.Marshal(testIssuer)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       time.Now().Add(-time.Minute),
+		NotAfter:        time.Now().Add(10 * time.Minute),
+		EmailAddresses:  []string{subject},
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}, Value: issuer}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, s.ca, key.Public(), s.caKey)
+	require.NoError(t, err)
+	if withSCT {
+		// The timestamp is signed over the certificate without the
+		// extension that embeds it.
+		precert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		template.ExtraExtensions = append(template.ExtraExtensions, s.sctExtension(t, precert))
+		der, err = x509.CreateCertificate(rand.Reader, template, s.ca, key.Public(), s.caKey)
+		require.NoError(t, err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// sctExtension returns the extension embedding a signed certificate timestamp
+// of the certificate transparency log for the certificate.
+func (s *sigstore) sctExtension(t *testing.T, precert *x509.Certificate) pkix.Extension {
+	t.Helper()
+	sct := ct.SignedCertificateTimestamp{
+		SCTVersion: ct.V1,
+		LogID:      ct.LogID{KeyID: [sha256.Size]byte(logID(t, s.ctKey))},
+		Timestamp:  uint64(time.Now().UnixMilli()),
+	}
+	leaf := ct.MerkleTreeLeaf{
+		Version:  ct.V1,
+		LeafType: ct.TimestampedEntryLeafType,
+		TimestampedEntry: &ct.TimestampedEntry{
+			EntryType: ct.PrecertLogEntryType,
+			Timestamp: sct.Timestamp,
+			PrecertEntry: &ct.PreCert{
+				IssuerKeyHash:  sha256.Sum256(s.ca.RawSubjectPublicKeyInfo),
+				TBSCertificate: precert.RawTBSCertificate,
+			},
+		},
+	}
+	input, err := ct.SerializeSCTSignatureInput(sct, ct.LogEntry{Leaf: leaf})
+	require.NoError(t, err)
+	sct.Signature = ct.DigitallySigned{
+		Algorithm: cttls.SignatureAndHashAlgorithm{Hash: cttls.SHA256, Signature: cttls.ECDSA},
+		Signature: sign(t, s.ctKey, input),
+	}
+	list, err := ctx509util.MarshalSCTsIntoSCTList([]*ct.SignedCertificateTimestamp{&sct})
+	require.NoError(t, err)
+	serialized, err := cttls.Marshal(*list)
+	require.NoError(t, err)
+	value, err := asn1.Marshal(serialized)
+	require.NoError(t, err)
+	return pkix.Extension{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}, Value: value}
+}
+
+// bundle logs an entry and returns the bundle cosign attaches to the
+// signature.
+func (s *sigstore) bundle(t *testing.T, entry []byte) string {
+	t.Helper()
+	payload := map[string]interface{}{
+		"body":           base64.StdEncoding.EncodeToString(entry),
+		"integratedTime": time.Now().Unix(),
+		"logIndex":       int64(42),
+		"logID":          hex.EncodeToString(logID(t, s.rekorKey)),
+	}
+	canonical, err := json.Marshal(payload)
+	require.NoError(t, err)
+	bundle, err := json.Marshal(map[string]interface{}{
+		"SignedEntryTimestamp": sign(t, s.rekorKey, canonical),
+		"Payload":              payload,
+	})
+	require.NoError(t, err)
+	return string(bundle)
+}
+
+func (s *sigstore) sign(t *testing.T, image name.Digest, subject string) mutate.Addendum {
+	return s.signImage(t, image, subject, true)
+}
+
+func (s *sigstore) signWithoutSCT(t *testing.T, image name.Digest, subject string) mutate.Addendum {
+	return s.signImage(t, image, subject, false)
+}
+
+// signImage signs the image like `cosign sign`, logging the signature as a
+// hashedrekord entry.
+func (s *sigstore) signImage(t *testing.T, image name.Digest, subject string, withSCT bool) mutate.Addendum {
+	t.Helper()
+	key := newKey(t)
+	certificate := s.certificate(t, key, subject, withSCT)
+	layer := signLayer(t, image, key, map[string]string{policy.CertificateAnnotation: certificate})
+	digest := sha256.Sum256(simpleSigningPayload(image))
+	entry := fmt.Appendf(nil, `{"apiVersion":"0.0.1","kind":"hashedrekord","spec":{"data":{"hash":{"algorithm":"sha256","value":%q}},"signature":{"content":%q,"publicKey":{"content":%q}}}}`,
+		hex.EncodeToString(digest[:]), layer.Annotations[policy.SignatureAnnotation], base64.StdEncoding.EncodeToString([]byte(certificate)))
+	layer.Annotations[policy.BundleAnnotation] = s.bundle(t, entry)
+	return layer
+}
+
+// attest attests the image like `cosign attest`, logging the attestation as
+// a dsse entry.
+func (s *sigstore) attest(t *testing.T, image name.Digest, predicateType, subject string) mutate.Addendum {
+	t.Helper()
+	key := newKey(t)
+	certificate := s.certificate(t, key, subject, true)
+	layer := attestLayer(t, image, predicateType, key, map[string]string{policy.CertificateAnnotation: certificate})
+	envelope := layerPayload(t, layer)
+	var env struct {
+		Payload    string              `json:"payload"`
+		Signatures []map[string]string `json:"signatures"`
+	}
+	require.NoError(t, json.Unmarshal(envelope, &env))
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	require.NoError(t, err)
+	envelopeHash, payloadHash := sha256.Sum256(envelope), sha256.Sum256(payload)
+	entry := fmt.Appendf(nil, `{"apiVersion":"0.0.1","kind":"dsse","spec":{"envelopeHash":{"algorithm":"sha256","value":%q},"payloadHash":{"algorithm":"sha256","value":%q},"signatures":[{"signature":%q,"verifier":%q}]}}`,
+		hex.EncodeToString(envelopeHash[:]), hex.EncodeToString(payloadHash[:]), env.Signatures[0]["sig"], base64.StdEncoding.EncodeToString([]byte(certificate)))
+	layer.Annotations[policy.BundleAnnotation] = s.bundle(t, entry)
+	return layer
+}
+
+// attestLegacy attests the image with a transparency log entry of a kind that
+// does not record the attestation.
+func (s *sigstore) attestLegacy(t *testing.T, image name.Digest, predicateType, subject string) mutate.Addendum {
+	t.Helper()
+	key := newKey(t)
+	return attestLayer(t, image, predicateType, key, map[string]string{
+		policy.CertificateAnnotation: s.certificate(t, key, subject, true),
+		policy.BundleAnnotation:      s.bundle(t, []byte(`{"apiVersion":"0.0.1","kind":"intoto","spec":{}}`)),
+	})
+}
+
+func layerPayload(t *testing.T, layer mutate.Addendum) []byte {
+	t.Helper()
+	rc, err := layer.Layer.Compressed()
+	require.NoError(t, err)
+	defer rc.Close()
+	payload, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return payload
+}
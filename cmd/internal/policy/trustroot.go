@@ -0,0 +1,193 @@
+package policy
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// TrustRoot is a policy.sigstore.dev TrustRoot. Only TrustRoots that list
+// their keys in sigstoreKeys can be used to verify signatures; TrustRoots
+// backed by a TUF repository need to be fetched by the policy-controller.
+type TrustRoot struct {
+	Name         string
+	Ready        bool
+	SigstoreKeys *SigstoreKeys
+}
+
+type SigstoreKeys struct {
+	CertificateAuthorities []CertificateAuthority `json:"certificateAuthorities,omitempty"`
+	TLogs                  []TransparencyLog      `json:"tLogs,omitempty"`
+	CTLogs                 []TransparencyLog      `json:"ctLogs,omitempty"`
+	TimestampAuthorities   []CertificateAuthority `json:"timestampAuthorities,omitempty"`
+}
+
+type CertificateAuthority struct {
+	URI string `json:"uri,omitempty"`
+	// CertChain is the PEM encoded chain, optionally base64 encoded again.
+	CertChain string `json:"certChain"`
+}
+
+type TransparencyLog struct {
+	BaseURL       string `json:"baseURL,omitempty"`
+	HashAlgorithm string `json:"hashAlgorithm,omitempty"`
+	// PublicKey is the PEM encoded key, optionally base64 encoded again.
+	PublicKey string `json:"publicKey"`
+}
+
+// TrustRootFromUnstructured converts a TrustRoot read as unstructured.
+// Objects without a status, such as manifests, are taken as Ready.
+func TrustRootFromUnstructured(obj *unstructured.Unstructured, ready bool) (TrustRoot, error) {
+	tr := TrustRoot{Name: obj.GetName(), Ready: ready}
+	keys, found, err := unstructured.NestedMap(obj.Object, "spec", "sigstoreKeys")
+	if err != nil {
+		return tr, fmt.Errorf("TrustRoot %q: %w", tr.Name, err)
+	}
+	if !found {
+		return tr, nil
+	}
+	tr.SigstoreKeys = &SigstoreKeys{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(keys, tr.SigstoreKeys); err != nil {
+		return tr, fmt.Errorf("TrustRoot %q: %w", tr.Name, err)
+	}
+	return tr, nil
+}
+
+// TrustedMaterial is what keyless signatures are verified against.
+type TrustedMaterial struct {
+	// Root holds the certificate authorities that issue the signing
+	// certificates, the transparency logs that record the signatures and the
+	// certificate transparency logs that issue the signed certificate
+	// timestamps.
+	Root root.TrustedMaterial
+}
+
+// trustedMaterial collects the certificate authorities, transparency logs and
+// certificate transparency logs of a keyless authority. Every certificate
+// chain has to end in a root certificate authority.
+func trustedMaterial(certificates []string, tlogs, ctlogs []TransparencyLog) (*TrustedMaterial, error) {
+	var authorities []root.CertificateAuthority
+	for _, chain := range certificates {
+		certs, err := ParseCertificates(chain)
+		if err != nil {
+			return nil, err
+		}
+		ca := &root.FulcioCertificateAuthority{}
+		for _, cert := range certs {
+			if isSelfSigned(cert) {
+				ca.Root = cert
+			} else {
+				ca.Intermediates = append(ca.Intermediates, cert)
+			}
+		}
+		if ca.Root == nil {
+			return nil, errors.New("certificate chain has no root certificate authority")
+		}
+		authorities = append(authorities, ca)
+	}
+	if len(authorities) == 0 {
+		return nil, errors.New("no root certificate authority")
+	}
+	rekorLogs, err := transparencyLogs(tlogs)
+	if err != nil {
+		return nil, err
+	}
+	ctLogs, err := transparencyLogs(ctlogs)
+	if err != nil {
+		return nil, err
+	}
+	trustedRoot, err := root.NewTrustedRoot(root.TrustedRootMediaType01, authorities, ctLogs, nil, rekorLogs)
+	if err != nil {
+		return nil, err
+	}
+	return &TrustedMaterial{Root: trustedRoot}, nil
+}
+
+// transparencyLogs keys the logs by their log ID, the hex encoded SHA-256
+// digest of their public key. TrustRoots carry no validity periods, so the
+// keys are taken as valid since the epoch.
+func transparencyLogs(logs []TransparencyLog) (map[string]*root.TransparencyLog, error) {
+	keyed := map[string]*root.TransparencyLog{}
+	for _, log := range logs {
+		der, err := decodePEM(log.PublicKey, "PUBLIC KEY")
+		if err != nil {
+			return nil, fmt.Errorf("transparency log %s: %w", log.BaseURL, err)
+		}
+		pub, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("transparency log %s: %w", log.BaseURL, err)
+		}
+		id := sha256.Sum256(der)
+		keyed[hex.EncodeToString(id[:])] = &root.TransparencyLog{
+			BaseURL:             log.BaseURL,
+			ID:                  id[:],
+			ValidityPeriodStart: time.Unix(0, 0),
+			HashFunc:            crypto.SHA256,
+			PublicKey:           pub,
+			SignatureHashFunc:   crypto.SHA256,
+		}
+	}
+	return keyed, nil
+}
+
+// ParseCertificates parses a PEM encoded certificate chain, which may be
+// base64 encoded again as in TrustRoots.
+func ParseCertificates(data string) ([]*x509.Certificate, error) {
+	raw := []byte(data)
+	if decoded, err := base64.StdEncoding.DecodeString(data); err == nil {
+		raw = decoded
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, raw = pem.Decode(raw)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM encoded certificates")
+	}
+	return certs, nil
+}
+
+// decodePEM returns the DER bytes of the first PEM block of the given type in
+// data, which may be base64 encoded again.
+func decodePEM(data, blockType string) ([]byte, error) {
+	raw := []byte(data)
+	if decoded, err := base64.StdEncoding.DecodeString(data); err == nil {
+		raw = decoded
+	}
+	for {
+		var block *pem.Block
+		block, raw = pem.Decode(raw)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM encoded %s", blockType)
+		}
+		if block.Type == blockType {
+			return block.Bytes, nil
+		}
+	}
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return cert.CheckSignatureFrom(cert) == nil
+}
@@ -0,0 +1,129 @@
+// Package policy evaluates container images against the ClusterImagePolicies
+// of the policy-controller, as far as that is possible outside of its
+// admission webhook.
+package policy
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Modes of a ClusterImagePolicy.
+const (
+	ModeEnforce = "enforce"
+	ModeWarn    = "warn"
+)
+
+// Actions of a static authority.
+const (
+	StaticPass = "pass"
+	StaticFail = "fail"
+)
+
+// ClusterImagePolicy is the part of a policy.sigstore.dev ClusterImagePolicy
+// that takes part in the evaluation of an image.
+type ClusterImagePolicy struct {
+	Name string
+	Spec ClusterImagePolicySpec
+}
+
+type ClusterImagePolicySpec struct {
+	Images      []ImagePattern     `json:"images"`
+	Authorities []Authority        `json:"authorities,omitempty"`
+	Mode        string             `json:"mode,omitempty"`
+	Match       []MatchResource    `json:"match,omitempty"`
+	Policy      *AttestationPolicy `json:"policy,omitempty"`
+}
+
+type ImagePattern struct {
+	Glob string `json:"glob"`
+}
+
+type Authority struct {
+	Name             string            `json:"name,omitempty"`
+	Key              *KeyRef           `json:"key,omitempty"`
+	Keyless          *KeylessRef       `json:"keyless,omitempty"`
+	Static           *StaticRef        `json:"static,omitempty"`
+	Sources          []Source          `json:"source,omitempty"`
+	CTLog            *TLog             `json:"ctlog,omitempty"`
+	Attestations     []Attestation     `json:"attestations,omitempty"`
+	RFC3161Timestamp *RFC3161Timestamp `json:"rfc3161timestamp,omitempty"`
+	SignatureFormat  string            `json:"signatureFormat,omitempty"`
+}
+
+type KeyRef struct {
+	Data          string                  `json:"data,omitempty"`
+	SecretRef     *corev1.SecretReference `json:"secretRef,omitempty"`
+	KMS           string                  `json:"kms,omitempty"`
+	HashAlgorithm string                  `json:"hashAlgorithm,omitempty"`
+}
+
+type KeylessRef struct {
+	URL               string     `json:"url,omitempty"`
+	Identities        []Identity `json:"identities,omitempty"`
+	CACert            *KeyRef    `json:"ca-cert,omitempty"`
+	TrustRootRef      string     `json:"trustRootRef,omitempty"`
+	InsecureIgnoreSCT bool       `json:"insecureIgnoreSCT,omitempty"`
+}
+
+type Identity struct {
+	Issuer        string `json:"issuer,omitempty"`
+	Subject       string `json:"subject,omitempty"`
+	IssuerRegExp  string `json:"issuerRegExp,omitempty"`
+	SubjectRegExp string `json:"subjectRegExp,omitempty"`
+}
+
+type StaticRef struct {
+	Action  string `json:"action"`
+	Message string `json:"message,omitempty"`
+}
+
+type Source struct {
+	OCI                  string                        `json:"oci,omitempty"`
+	SignaturePullSecrets []corev1.LocalObjectReference `json:"signaturePullSecrets,omitempty"`
+	TagPrefix            string                        `json:"tagPrefix,omitempty"`
+}
+
+type TLog struct {
+	URL          string `json:"url,omitempty"`
+	TrustRootRef string `json:"trustRootRef,omitempty"`
+}
+
+type RFC3161Timestamp struct {
+	TrustRootRef string `json:"trustRootRef,omitempty"`
+}
+
+type Attestation struct {
+	Name          string             `json:"name"`
+	PredicateType string             `json:"predicateType"`
+	Policy        *AttestationPolicy `json:"policy,omitempty"`
+}
+
+// AttestationPolicy is a CUE or Rego policy. Its other sources are not
+// evaluated, so they are left out.
+type AttestationPolicy struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"`
+}
+
+type MatchResource struct {
+	metav1.GroupVersionResource `json:",inline"`
+	ResourceSelector            *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// FromUnstructured converts a ClusterImagePolicy read as unstructured.
+func FromUnstructured(obj *unstructured.Unstructured) (ClusterImagePolicy, error) {
+	cip := ClusterImagePolicy{Name: obj.GetName()}
+	spec, _, err := unstructured.NestedMap(obj.Object, "spec")
+	if err != nil {
+		return cip, fmt.Errorf("ClusterImagePolicy %q: %w", cip.Name, err)
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &cip.Spec); err != nil {
+		return cip, fmt.Errorf("ClusterImagePolicy %q: %w", cip.Name, err)
+	}
+	return cip, nil
+}
@@ -0,0 +1,430 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protodsse "github.com/sigstore/protobuf-specs/gen/pb-go/dsse"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+)
+
+// Annotations of the layers of cosign signature and attestation images.
+const (
+	SignatureAnnotation   = "dev.cosignproject.cosign/signature"
+	CertificateAnnotation = "dev.sigstore.cosign/certificate"
+	BundleAnnotation      = "dev.sigstore.cosign/bundle"
+)
+
+// bundleArtifactType is the artifact type of signatures in the Sigstore
+// bundle format, which are attached to the image as OCI referrers.
+const bundleArtifactType = "application/vnd.dev.sigstore.bundle"
+
+// inTotoPayloadType is the DSSE payload type of attestations.
+const inTotoPayloadType = "application/vnd.in-toto+json"
+
+// maxPayloadSize bounds the payloads read from a registry.
+const maxPayloadSize = 1 << 20
+
+// RegistryVerifier verifies cosign signatures and attestations stored next
+// to the image under the sha256-<digest>.sig and .att tags. Signatures in
+// the Sigstore bundle format are detected but not verified.
+type RegistryVerifier struct {
+	// Registry serves the images and their signatures. Defaults to the
+	// remote registries of the images.
+	Registry Registry
+}
+
+// simpleSigning is the part of a cosign simple signing payload that binds the
+// signature to an image.
+type simpleSigning struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// envelope is a DSSE envelope holding an in-toto statement.
+type envelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+	Signatures  []struct {
+		Sig string `json:"sig"`
+	} `json:"signatures"`
+}
+
+type statement struct {
+	PredicateType string `json:"predicateType"`
+	Subject       []struct {
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+}
+
+func (v *RegistryVerifier) registry() Registry {
+	if v.Registry == nil {
+		return &Remote{}
+	}
+	return v.Registry
+}
+
+func (v *RegistryVerifier) VerifySignature(ctx context.Context, image string, authority Authority, trust *TrustedMaterial) error {
+	signer, err := newSigner(authority, trust)
+	if err != nil {
+		return err
+	}
+	digest, layers, err := v.fetch(ctx, image, authority.Sources, "sig")
+	if err != nil {
+		return err
+	}
+
+	var reasons []string
+	for _, layer := range layers {
+		signature, err := base64.StdEncoding.DecodeString(layer.annotations[SignatureAnnotation])
+		if err != nil || len(signature) == 0 {
+			continue
+		}
+		if err := signer.verifyMessage(layer.annotations, layer.payload, signature); err != nil {
+			reasons = append(reasons, err.Error())
+			continue
+		}
+		var signed simpleSigning
+		if err := json.Unmarshal(layer.payload, &signed); err != nil || signed.Critical.Image.DockerManifestDigest != digest.DigestStr() {
+			reasons = append(reasons, "signature is for a different image")
+			continue
+		}
+		return nil
+	}
+	return noneValid("signature", image, len(layers), reasons)
+}
+
+func (v *RegistryVerifier) VerifyAttestation(ctx context.Context, image string, authority Authority, trust *TrustedMaterial, predicateType string) error {
+	signer, err := newSigner(authority, trust)
+	if err != nil {
+		return err
+	}
+	digest, layers, err := v.fetch(ctx, image, authority.Sources, "att")
+	if err != nil {
+		return err
+	}
+	wanted := PredicateTypeURL(predicateType)
+	_, digestHex, _ := strings.Cut(digest.DigestStr(), ":")
+
+	var reasons []string
+	for _, layer := range layers {
+		var env envelope
+		if err := json.Unmarshal(layer.payload, &env); err != nil || env.PayloadType != inTotoPayloadType {
+			continue
+		}
+		payload, err := base64.StdEncoding.DecodeString(env.Payload)
+		if err != nil {
+			continue
+		}
+		var st statement
+		if err := json.Unmarshal(payload, &st); err != nil || st.PredicateType != wanted {
+			continue
+		}
+		bound := false
+		for _, subject := range st.Subject {
+			bound = bound || subject.Digest["sha256"] == digestHex
+		}
+		if !bound {
+			reasons = append(reasons, "attestation is for a different image")
+			continue
+		}
+		for _, s := range env.Signatures {
+			signature, err := base64.StdEncoding.DecodeString(s.Sig)
+			if err != nil {
+				continue
+			}
+			if err := signer.verifyEnvelope(layer.annotations, env.PayloadType, payload, signature, digestHex); err != nil {
+				reasons = append(reasons, err.Error())
+				continue
+			}
+			return nil
+		}
+	}
+	if len(reasons) == 0 {
+		return fmt.Errorf("no %s attestation found for %s", wanted, image)
+	}
+	return noneValid(wanted+" attestation", image, len(layers), reasons)
+}
+
+// signatureLayer is a layer of a signature or attestation image.
+type signatureLayer struct {
+	annotations map[string]string
+	payload     []byte
+}
+
+// fetch resolves the digest of an image and reads the layers of its
+// signature or attestation image, named by the suffix.
+func (v *RegistryVerifier) fetch(ctx context.Context, image string, sources []Source, suffix string) (name.Digest, []signatureLayer, error) {
+	registry := v.registry()
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return name.Digest{}, nil, fmt.Errorf("invalid image reference %q: %w", image, err)
+	}
+	digest, ok := ref.(name.Digest)
+	if !ok {
+		hash, err := registry.Digest(ctx, ref)
+		if err != nil {
+			return name.Digest{}, nil, fmt.Errorf("%w: unable to resolve %s: %v", ErrNotEvaluated, image, err)
+		}
+		digest = ref.Context().Digest(hash.String())
+	}
+
+	repo := ref.Context()
+	tagPrefix := ""
+	if len(sources) > 0 {
+		if sources[0].OCI != "" {
+			if repo, err = name.NewRepository(sources[0].OCI); err != nil {
+				return digest, nil, fmt.Errorf("invalid signature repository %q: %w", sources[0].OCI, err)
+			}
+		}
+		tagPrefix = sources[0].TagPrefix
+	}
+	tag := repo.Tag(tagPrefix + strings.Replace(digest.DigestStr(), ":", "-", 1) + "." + suffix)
+
+	img, err := registry.Image(ctx, tag)
+	if errors.Is(err, ErrNotFound) {
+		kind := map[string]string{"sig": "signatures", "att": "attestations"}[suffix]
+		if referrers, _ := registry.Referrers(ctx, digest); hasBundle(referrers) {
+			return digest, nil, fmt.Errorf("%w: %s of %s are in the Sigstore bundle format, which is not verified", ErrNotEvaluated, kind, image)
+		}
+		return digest, nil, fmt.Errorf("no %s found for %s", kind, image)
+	}
+	if err != nil {
+		return digest, nil, fmt.Errorf("%w: unable to fetch %s: %v", ErrNotEvaluated, tag, err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return digest, nil, fmt.Errorf("%w: unable to read %s: %v", ErrNotEvaluated, tag, err)
+	}
+
+	layers := make([]signatureLayer, 0, len(manifest.Layers))
+	for _, desc := range manifest.Layers {
+		payload, err := readLayer(img, desc.Digest)
+		if err != nil {
+			return digest, nil, fmt.Errorf("%w: unable to read layer %s of %s: %v", ErrNotEvaluated, desc.Digest, tag, err)
+		}
+		layers = append(layers, signatureLayer{annotations: desc.Annotations, payload: payload})
+	}
+	return digest, layers, nil
+}
+
+func readLayer(img v1.Image, digest v1.Hash) ([]byte, error) {
+	layer, err := img.LayerByDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxPayloadSize))
+}
+
+func hasBundle(referrers []v1.Descriptor) bool {
+	for _, desc := range referrers {
+		if strings.HasPrefix(desc.ArtifactType, bundleArtifactType) {
+			return true
+		}
+	}
+	return false
+}
+
+func noneValid(kind, image string, layers int, reasons []string) error {
+	if len(reasons) == 0 {
+		return fmt.Errorf("no %s found for %s", kind, image)
+	}
+	seen := map[string]bool{}
+	var distinct []string
+	for _, reason := range reasons {
+		if !seen[reason] {
+			seen[reason] = true
+			distinct = append(distinct, reason)
+		}
+	}
+	return fmt.Errorf("none of %d %s layers of %s is valid: %s", layers, kind, image, strings.Join(distinct, "; "))
+}
+
+// preAuthEncoding is the DSSE pre-authentication encoding that attestation
+// signatures are made over.
+func preAuthEncoding(payloadType string, payload []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// PredicateTypeURL expands the short predicate types cosign accepts.
+func PredicateTypeURL(predicateType string) string {
+	switch predicateType {
+	case "custom":
+		return "https://cosign.sigstore.dev/attestation/v1"
+	case "slsaprovenance", "slsaprovenance02":
+		return "https://slsa.dev/provenance/v0.2"
+	case "slsaprovenance1":
+		return "https://slsa.dev/provenance/v1"
+	case "spdx", "spdxjson":
+		return "https://spdx.dev/Document"
+	case "cyclonedx":
+		return "https://cyclonedx.org/bom"
+	case "link":
+		return "https://in-toto.io/Link/v1"
+	case "vuln":
+		return "https://cosign.sigstore.dev/attestation/vuln/v1"
+	case "openvex":
+		return "https://openvex.dev/ns"
+	default:
+		return predicateType
+	}
+}
+
+// signer verifies that signatures were made by a signer an authority
+// accepts, either with its key or with a certificate issued to one of its
+// keyless identities.
+type signer struct {
+	keyless *KeylessRef
+	trust   *TrustedMaterial
+	key     crypto.PublicKey
+	hash    crypto.Hash
+}
+
+func newSigner(authority Authority, trust *TrustedMaterial) (*signer, error) {
+	if authority.Keyless != nil {
+		if trust == nil {
+			return nil, fmt.Errorf("%w: no trusted certificate authorities", ErrNotEvaluated)
+		}
+		return &signer{keyless: authority.Keyless, trust: trust}, nil
+	}
+	if authority.Key == nil {
+		return nil, errors.New("authority has no key or keyless verifier")
+	}
+	pub, hash, err := ParsePublicKey(authority.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &signer{key: pub, hash: hash}, nil
+}
+
+// verifyMessage checks a signature over a simple signing payload.
+func (s *signer) verifyMessage(annotations map[string]string, payload, signature []byte) error {
+	if s.keyless == nil {
+		if err := VerifySignature(s.key, s.hash, payload, signature); err != nil {
+			return errors.New("signature does not match the key")
+		}
+		return nil
+	}
+	sum := sha256.Sum256(payload)
+	return verifyKeyless(s.keyless, s.trust, annotations, &protobundle.Bundle{
+		Content: &protobundle.Bundle_MessageSignature{MessageSignature: &protocommon.MessageSignature{
+			MessageDigest: &protocommon.HashOutput{Algorithm: protocommon.HashAlgorithm_SHA2_256, Digest: sum[:]},
+			Signature:     signature,
+		}},
+	}, verify.WithArtifact(bytes.NewReader(payload)))
+}
+
+// verifyEnvelope checks a signature of a DSSE envelope holding an in-toto
+// statement about the image with the hex encoded sha256 digest.
+func (s *signer) verifyEnvelope(annotations map[string]string, payloadType string, payload, signature []byte, digest string) error {
+	if s.keyless == nil {
+		if err := VerifySignature(s.key, s.hash, preAuthEncoding(payloadType, payload), signature); err != nil {
+			return errors.New("signature does not match the key")
+		}
+		return nil
+	}
+	artifactDigest, err := hex.DecodeString(digest)
+	if err != nil {
+		return err
+	}
+	return verifyKeyless(s.keyless, s.trust, annotations, &protobundle.Bundle{
+		Content: &protobundle.Bundle_DsseEnvelope{DsseEnvelope: &protodsse.Envelope{
+			Payload:     payload,
+			PayloadType: payloadType,
+			Signatures:  []*protodsse.Signature{{Sig: signature}},
+		}},
+	}, verify.WithArtifactDigest("sha256", artifactDigest))
+}
+
+// ParsePublicKey parses the PEM encoded public key of a key authority and the
+// hash algorithm its signatures are made with.
+func ParsePublicKey(key *KeyRef) (crypto.PublicKey, crypto.Hash, error) {
+	hash, err := HashAlgorithm(key.HashAlgorithm)
+	if err != nil {
+		return nil, 0, err
+	}
+	block, _ := pem.Decode([]byte(key.Data))
+	if block == nil {
+		return nil, 0, errors.New("public key is not PEM encoded")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid public key: %w", err)
+	}
+	return pub, hash, nil
+}
+
+// HashAlgorithm returns the hash of a hashAlgorithm, which defaults to sha256.
+func HashAlgorithm(algorithm string) (crypto.Hash, error) {
+	switch algorithm {
+	case "", "sha256":
+		return crypto.SHA256, nil
+	case "sha224":
+		return crypto.SHA224, nil
+	case "sha384":
+		return crypto.SHA384, nil
+	case "sha512":
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported hash algorithm %q", algorithm)
+	}
+}
+
+// VerifySignature checks a signature over payload made by the private key of
+// pub. ECDSA and RSA sign the hash of the payload, ed25519 the payload itself.
+func VerifySignature(pub crypto.PublicKey, hash crypto.Hash, payload, signature []byte) error {
+	if k, ok := pub.(ed25519.PublicKey); ok {
+		if !ed25519.Verify(k, payload, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	h := hash.New()
+	h.Write(payload)
+	digest := h.Sum(nil)
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, digest, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(k, hash, digest, signature) == nil {
+			return nil
+		}
+		return rsa.VerifyPSS(k, hash, digest, signature, nil)
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
@@ -0,0 +1,31 @@
+// Package testutil holds fixtures shared by the tests of several packages.
+package testutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// EncodePublicKey PEM encodes a public key the way cosign writes cosign.pub.
+func EncodePublicKey(t *testing.T, pub crypto.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// GeneratePublicKeyPEM returns the PEM encoded public key of a new ECDSA
+// P-256 key.
+func GeneratePublicKeyPEM(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return EncodePublicKey(t, &key.PublicKey)
+}
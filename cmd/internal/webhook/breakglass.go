@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// validateBreakGlass checks a break-glass annotation that is added or
+// changed. RequestedBy has to name the requesting user, so that the
+// recorded requester can be trusted. An unchanged annotation is not checked
+// again, as it may have expired in the meantime.
+func validateBreakGlass(ctx context.Context, oldObj, newObj *v1alpha1.PolicyController) (admission.Warnings, error) {
+	value, ok := newObj.Annotations[v1alpha1.BreakGlassAnnotation]
+	if !ok || (oldObj != nil && oldObj.Annotations[v1alpha1.BreakGlassAnnotation] == value) {
+		return nil, nil
+	}
+	bg, err := v1alpha1.ParseBreakGlass(newObj)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	switch bg.Mode {
+	case v1alpha1.BreakGlassIgnore, v1alpha1.BreakGlassWarn:
+	default:
+		errs = append(errs, fmt.Errorf("mode must be %s or %s", v1alpha1.BreakGlassIgnore, v1alpha1.BreakGlassWarn))
+	}
+	if bg.Reason == "" {
+		errs = append(errs, errors.New("reason must not be empty"))
+	}
+	now := time.Now()
+	switch {
+	case !bg.ExpiresAt.After(now):
+		errs = append(errs, errors.New("expiresAt must be in the future"))
+	case bg.ExpiresAt.Sub(now) > v1alpha1.MaxBreakGlassDuration:
+		errs = append(errs, fmt.Errorf("expiresAt must be within %s", v1alpha1.MaxBreakGlassDuration))
+	}
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("unable to determine the requesting user: %w", err))
+	} else if bg.RequestedBy != req.UserInfo.Username {
+		errs = append(errs, fmt.Errorf("requestedBy must be the requesting user %q", req.UserInfo.Username))
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid %s annotation: %w", v1alpha1.BreakGlassAnnotation, errors.Join(errs...))
+	}
+
+	return admission.Warnings{fmt.Sprintf("break-glass: image policy enforcement is suspended in %s mode until %s",
+		bg.Mode, bg.ExpiresAt.UTC().Format(time.RFC3339))}, nil
+}
@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// kmsSchemes are the KMS providers the policy-controller loads keys from.
+var kmsSchemes = []string{"awskms://", "gcpkms://", "azurekms://", "hashivault://", "k8s://"}
+
+// hashAlgorithms are the values of hashAlgorithm the policy-controller
+// accepts.
+var hashAlgorithms = []string{"sha224", "sha256", "sha384", "sha512"}
+
+// validateKey checks a key authority: inline keys and the keys of the
+// referenced Secret have to parse, match hashAlgorithm and be strong enough,
+// and KMS references have to name a known provider.
+func (v *ClusterImagePolicyValidator) validateKey(ctx context.Context, path *field.Path, key *policy.KeyRef) (admission.Warnings, field.ErrorList) {
+	var warnings admission.Warnings
+	var errs field.ErrorList
+
+	hashPath := path.Child("hashAlgorithm")
+	if _, err := policy.HashAlgorithm(key.HashAlgorithm); err != nil {
+		errs = append(errs, field.NotSupported(hashPath, key.HashAlgorithm, hashAlgorithms))
+	}
+	if key.Data != "" {
+		errs = append(errs, checkKey(path.Child("data"), hashPath, key.Data, key.HashAlgorithm)...)
+	}
+	if key.SecretRef != nil {
+		data, warning, err := v.secretKey(ctx, path.Child("secretRef"), key.SecretRef)
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		} else if data != "" {
+			errs = append(errs, checkKey(path.Child("secretRef"), hashPath, data, key.HashAlgorithm)...)
+		}
+	}
+	if key.KMS != "" && !knownKMS(key.KMS) {
+		errs = append(errs, field.NotSupported(path.Child("kms"), kmsScheme(key.KMS), kmsSchemes))
+	}
+	return warnings, errs
+}
+
+// checkKey parses a PEM public key and checks it against the hash
+// algorithm. Ed25519 keys sign the payload itself, so the hashAlgorithm the
+// policy-controller defaults every key authority to is ignored for them.
+func checkKey(path, hashPath *field.Path, data, algorithm string) field.ErrorList {
+	pub, err := parsePublicKeyPEM([]byte(data))
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, field.OmitValueType{}, err.Error())}
+	}
+	description, err := describeKey(pub)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, field.OmitValueType{}, err.Error())}
+	}
+	if _, ok := pub.(ed25519.PublicKey); ok || algorithm == "" {
+		return nil
+	}
+	if matching := matchingHashAlgorithms(pub); !slices.Contains(matching, algorithm) {
+		return field.ErrorList{field.Invalid(hashPath, algorithm, fmt.Sprintf("does not match the %s key, use %s", description, strings.Join(matching, " or ")))}
+	}
+	return nil
+}
+
+// matchingHashAlgorithms are the hash algorithms that may be configured for
+// an ECDSA or RSA key. Without one, signatures are verified with SHA-256,
+// which is what cosign signs with for every ECDSA and RSA key.
+func matchingHashAlgorithms(pub crypto.PublicKey) []string {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P384():
+			return []string{"sha256", "sha384"}
+		case elliptic.P521():
+			return []string{"sha256", "sha512"}
+		}
+		return []string{"sha256"}
+	case *rsa.PublicKey:
+		return []string{"sha256", "sha384", "sha512"}
+	}
+	return nil
+}
+
+// secretKey reads the key of a Secret reference. The policy-controller reads
+// the Secret from its own namespace and only accepts Secrets with a single
+// entry, the public key.
+func (v *ClusterImagePolicyValidator) secretKey(ctx context.Context, path *field.Path, ref *corev1.SecretReference) (string, string, *field.Error) {
+	var warning string
+	if ref.Namespace != "" && ref.Namespace != constants.PolicyControllerInstallNs {
+		warning = fmt.Sprintf("%s: namespace %q is ignored, the Secret is read from the %q namespace", path.Child("namespace"), ref.Namespace, constants.PolicyControllerInstallNs)
+	}
+	if v.Cache == nil {
+		return "", warning, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := v.Cache.Get(ctx, client.ObjectKey{Namespace: constants.PolicyControllerInstallNs, Name: ref.Name}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", warning, field.NotFound(path.Child("name"), ref.Name)
+		}
+		return "", warning, field.InternalError(path, fmt.Errorf("unable to read Secret %q: %w", ref.Name, err))
+	}
+	if len(secret.Data) != 1 {
+		keys := make([]string, 0, len(secret.Data))
+		for key := range secret.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return "", warning, field.Invalid(path.Child("name"), ref.Name,
+			fmt.Sprintf("Secret must contain exactly one entry with the public key, found %d: %s", len(keys), strings.Join(keys, ", ")))
+	}
+	for _, value := range secret.Data {
+		return string(value), warning, nil
+	}
+	return "", warning, nil
+}
+
+func knownKMS(reference string) bool {
+	for _, scheme := range kmsSchemes {
+		if strings.HasPrefix(reference, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// kmsScheme returns the scheme of a KMS reference for error messages.
+func kmsScheme(reference string) string {
+	if u, err := url.Parse(reference); err == nil && u.Scheme != "" {
+		return u.Scheme + "://"
+	}
+	return reference
+}
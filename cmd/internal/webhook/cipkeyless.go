@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"regexp/syntax"
+
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// validateKeyless checks the identities of a keyless authority. Every
+// identity needs an issuer and a subject, given exactly or as a regular
+// expression. An authority without identities accepts any signer, so it is
+// only allowed with the AllowAnyIdentityAnnotation.
+func validateKeyless(path *field.Path, keyless *policy.KeylessRef, allowAny bool) (admission.Warnings, field.ErrorList) {
+	var warnings admission.Warnings
+	var errs field.ErrorList
+
+	identities := path.Child("identities")
+	if len(keyless.Identities) == 0 {
+		if !allowAny {
+			return nil, field.ErrorList{field.Required(identities, fmt.Sprintf(
+				"an authority without identities accepts signatures of any identity; set the %s annotation to \"true\" to allow it", constants.AllowAnyIdentityAnnotation))}
+		}
+		return admission.Warnings{fmt.Sprintf("%s: no identities, signatures of any identity are accepted", identities)}, nil
+	}
+
+	for i, identity := range keyless.Identities {
+		path := identities.Index(i)
+		issuerWarnings, issuerErrs := validateIdentityField(path, "issuer", identity.Issuer, identity.IssuerRegExp)
+		subjectWarnings, subjectErrs := validateIdentityField(path, "subject", identity.Subject, identity.SubjectRegExp)
+		warnings = append(append(warnings, issuerWarnings...), subjectWarnings...)
+		errs = append(append(errs, issuerErrs...), subjectErrs...)
+
+		if identity.Issuer != "" {
+			warning, err := validateIssuerURL(path.Child("issuer"), identity.Issuer)
+			if warning != "" {
+				warnings = append(warnings, warning)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return warnings, errs
+}
+
+// validateIdentityField checks that exactly one of an identity field and its
+// RegExp variant is set, and that the regular expression compiles and does
+// not accept more than it looks like.
+func validateIdentityField(path *field.Path, name, exact, expr string) (admission.Warnings, field.ErrorList) {
+	exprName := name + "RegExp"
+	switch {
+	case exact == "" && expr == "":
+		return nil, field.ErrorList{field.Required(path.Child(name), fmt.Sprintf("one of %s and %s is required", name, exprName))}
+	case exact != "" && expr != "":
+		return nil, field.ErrorList{field.Forbidden(path.Child(exprName), fmt.Sprintf("may not be set together with %s", name))}
+	case expr == "":
+		return nil, nil
+	}
+
+	if _, err := regexp.Compile(expr); err != nil {
+		return nil, field.ErrorList{field.Invalid(path.Child(exprName), expr, err.Error())}
+	}
+	if reason := permissive(expr); reason != "" {
+		return admission.Warnings{fmt.Sprintf("%s: %q %s", path.Child(exprName), expr, reason)}, nil
+	}
+	return nil, nil
+}
+
+// permissive explains why a regular expression accepts more identities than
+// intended, or returns an empty string. Identities are matched anywhere in
+// the value, so expressions not anchored with ^ accept any value containing
+// a match.
+func permissive(expr string) string {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	re = re.Simplify()
+	alternatives := []*syntax.Regexp{re}
+	if re.Op == syntax.OpAlternate {
+		alternatives = re.Sub
+	}
+
+	unanchored := false
+	for _, alt := range alternatives {
+		subs := []*syntax.Regexp{alt}
+		if alt.Op == syntax.OpConcat {
+			subs = alt.Sub
+		}
+		anchored := len(subs) > 0 && (subs[0].Op == syntax.OpBeginText || subs[0].Op == syntax.OpBeginLine)
+		if anchored {
+			subs = subs[1:]
+		}
+		if n := len(subs); n > 0 && (subs[n-1].Op == syntax.OpEndText || subs[n-1].Op == syntax.OpEndLine) {
+			subs = subs[:n-1]
+		}
+		if matchesAnything(subs) {
+			return "matches any value"
+		}
+		unanchored = unanchored || !anchored
+	}
+	if unanchored {
+		return "is not anchored with ^ and matches any value that contains a match"
+	}
+	return ""
+}
+
+// matchesAnything reports whether a concatenation consists only of
+// repetitions of any character.
+func matchesAnything(subs []*syntax.Regexp) bool {
+	for _, sub := range subs {
+		switch sub.Op {
+		case syntax.OpEmptyMatch:
+		case syntax.OpStar, syntax.OpPlus:
+			if op := sub.Sub[0].Op; op != syntax.OpAnyChar && op != syntax.OpAnyCharNotNL {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validateIssuerURL checks that an issuer is an OIDC issuer URL, which is an
+// https URL without query or fragment.
+func validateIssuerURL(path *field.Path, issuer string) (string, *field.Error) {
+	u, err := url.Parse(issuer)
+	switch {
+	case err != nil:
+		return "", field.Invalid(path, issuer, err.Error())
+	case u.Scheme != "https" && u.Scheme != "http":
+		return "", field.Invalid(path, issuer, "must be an https URL")
+	case u.Host == "":
+		return "", field.Invalid(path, issuer, "must be an absolute URL with a host")
+	case u.RawQuery != "" || u.Fragment != "":
+		return "", field.Invalid(path, issuer, "may not have a query or fragment")
+	case u.Scheme == "http":
+		return fmt.Sprintf("%s: %q is not an https URL", path, issuer), nil
+	}
+	return "", nil
+}
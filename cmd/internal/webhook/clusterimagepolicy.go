@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// ClusterImagePolicyPath is the path the ClusterImagePolicyValidator is
+// served at.
+const ClusterImagePolicyPath = "/validate-clusterimagepolicy"
+
+// +kubebuilder:webhook:path=/validate-clusterimagepolicy,mutating=false,failurePolicy=fail,groups=policy.sigstore.dev,resources=clusterimagepolicies,verbs=create;update,versions=v1alpha1,name=clusterimagepolicies.rhtas.charts.redhat.com
+// ClusterImagePolicyValidator checks the parts of a ClusterImagePolicy that
+// the policy-controller only looks at when it compiles the policy, so that
+// mistakes are reported on admission rather than as a policy that silently
+// fails to load. ClusterImagePolicies are served by the chart CRD and read
+// as unstructured, so the validator is an admission.Handler.
+type ClusterImagePolicyValidator struct {
+	// Cache reads the Secrets that key authorities refer to. Without it
+	// secret references are not checked.
+	Cache client.Reader
+}
+
+func (v *ClusterImagePolicyValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	log := logf.FromContext(ctx)
+
+	obj, cip, err := decodeClusterImagePolicy(req.Object.Raw)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	// Updates that leave the spec, apart from its mode, and the opt-outs
+	// alone, such as the finalizers the policy-controller adds and removes or
+	// the mode a break-glass switches to warn and back, are not checked
+	// again.
+	if req.Operation == admissionv1.Update {
+		oldObj, old, err := decodeClusterImagePolicy(req.OldObject.Raw)
+		old.Spec.Mode = cip.Spec.Mode
+		if err == nil && equality.Semantic.DeepEqual(old.Spec, cip.Spec) &&
+			oldObj.GetAnnotations()[constants.AllowAnyIdentityAnnotation] == obj.GetAnnotations()[constants.AllowAnyIdentityAnnotation] {
+			return admission.Allowed("")
+		}
+	}
+
+	warnings, errs := v.validate(ctx, obj, cip)
+	if len(errs) > 0 {
+		log.Info("denying ClusterImagePolicy", "name", cip.Name, "errors", errs.ToAggregate().Error())
+		return admission.Denied(errs.ToAggregate().Error()).WithWarnings(warnings...)
+	}
+	return admission.Allowed("").WithWarnings(warnings...)
+}
+
+// validate checks the image globs and the authorities of a
+// ClusterImagePolicy.
+func (v *ClusterImagePolicyValidator) validate(ctx context.Context, obj *unstructured.Unstructured, cip policy.ClusterImagePolicy) (admission.Warnings, field.ErrorList) {
+	var warnings admission.Warnings
+	var errs field.ErrorList
+	for i, pattern := range cip.Spec.Images {
+		path := field.NewPath("spec", "images").Index(i).Child("glob")
+		if pattern.Glob == "" {
+			errs = append(errs, field.Required(path, ""))
+			continue
+		}
+		if err := policy.ValidateGlob(pattern.Glob); err != nil {
+			errs = append(errs, field.Invalid(path, pattern.Glob, err.Error()))
+			continue
+		}
+		if reason := policy.UnmatchableGlob(pattern.Glob); reason != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: %q %s", path, pattern.Glob, reason))
+		}
+	}
+
+	authorities := field.NewPath("spec", "authorities")
+	for i, authority := range cip.Spec.Authorities {
+		if authority.Key != nil {
+			keyWarnings, keyErrs := v.validateKey(ctx, authorities.Index(i).Child("key"), authority.Key)
+			warnings = append(warnings, keyWarnings...)
+			errs = append(errs, keyErrs...)
+		}
+		if authority.Keyless != nil {
+			allowAny := obj.GetAnnotations()[constants.AllowAnyIdentityAnnotation] == "true"
+			keylessWarnings, keylessErrs := validateKeyless(authorities.Index(i).Child("keyless"), authority.Keyless, allowAny)
+			warnings = append(warnings, keylessWarnings...)
+			errs = append(errs, keylessErrs...)
+		}
+	}
+	return warnings, errs
+}
+
+func decodeClusterImagePolicy(raw []byte) (*unstructured.Unstructured, policy.ClusterImagePolicy, error) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(raw); err != nil {
+		return nil, policy.ClusterImagePolicy{}, err
+	}
+	cip, err := policy.FromUnstructured(obj)
+	return obj, cip, err
+}
@@ -0,0 +1,389 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/api/v1beta1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// PolicyControllerConverter serves the conversion webhook of the PolicyController CRD.
+// v1alpha1 stays the storage version because the helm-operator hands
+// spec.policy-controller to the chart as-is, so conversion works on the raw
+// helm values rather than on the typed v1alpha1 API, which only models a subset.
+type PolicyControllerConverter struct{}
+
+func (c *PolicyControllerConverter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log := logf.Log.WithName("conversion")
+
+	review := &apiextensionsv1.ConversionReview{}
+	if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+		log.Error(err, "unable to decode conversion review")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "conversion review has no request", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = convertReview(review.Request)
+	review.Request = nil
+	if review.Response.Result.Status != metav1.StatusSuccess {
+		log.Info("conversion failed", "reason", review.Response.Result.Message)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		log.Error(err, "unable to encode conversion review")
+	}
+}
+
+func convertReview(req *apiextensionsv1.ConversionRequest) *apiextensionsv1.ConversionResponse {
+	resp := &apiextensionsv1.ConversionResponse{UID: req.UID}
+	for _, raw := range req.Objects {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(raw.Raw); err != nil {
+			resp.Result = conversionFailure(err)
+			return resp
+		}
+
+		converted, err := convertPolicyController(obj, req.DesiredAPIVersion)
+		if err != nil {
+			resp.Result = conversionFailure(fmt.Errorf("%s/%s: %w", obj.GetNamespace(), obj.GetName(), err))
+			return resp
+		}
+
+		data, err := converted.MarshalJSON()
+		if err != nil {
+			resp.Result = conversionFailure(err)
+			return resp
+		}
+		resp.ConvertedObjects = append(resp.ConvertedObjects, runtime.RawExtension{Raw: data})
+	}
+	resp.Result = metav1.Status{Status: metav1.StatusSuccess}
+	return resp
+}
+
+func conversionFailure(err error) metav1.Status {
+	return metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+}
+
+func convertPolicyController(obj *unstructured.Unstructured, desiredAPIVersion string) (*unstructured.Unstructured, error) {
+	from := obj.GetAPIVersion()
+	switch {
+	case from == desiredAPIVersion:
+		return obj, nil
+	case from == constants.PolicyControllerAPIVersion && desiredAPIVersion == v1beta1.GroupVersion.String():
+		return toV1beta1(obj)
+	case from == v1beta1.GroupVersion.String() && desiredAPIVersion == constants.PolicyControllerAPIVersion:
+		return toV1alpha1(obj)
+	default:
+		return nil, fmt.Errorf("unsupported conversion from %q to %q", from, desiredAPIVersion)
+	}
+}
+
+// toV1beta1 lifts the curated fields out of the helm values and keeps the
+// complete values in an annotation for the way back.
+func toV1beta1(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	values, _, err := unstructured.NestedMap(obj.Object, "spec", "policy-controller")
+	if err != nil {
+		return nil, err
+	}
+
+	curated := specFromValues(values)
+	if mode, _, err := unstructured.NestedString(obj.Object, "spec", "webhookDrift", "mode"); err == nil {
+		curated.Enforcement.WebhookDrift = v1alpha1.WebhookDriftMode(mode)
+	}
+	if profile, _, err := unstructured.NestedString(obj.Object, "spec", "profile"); err == nil {
+		curated.Profile = v1alpha1.DeploymentProfile(profile)
+	}
+	if raw, found, err := unstructured.NestedMap(obj.Object, "spec", "circuitBreaker"); err == nil && found {
+		curated.Enforcement.CircuitBreaker = &v1alpha1.CircuitBreakerSpec{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, curated.Enforcement.CircuitBreaker); err != nil {
+			return nil, fmt.Errorf("invalid spec.circuitBreaker: %w", err)
+		}
+	}
+	if raw, found, err := unstructured.NestedMap(obj.Object, "spec", "upgradeMaintenance"); err == nil && found {
+		curated.Enforcement.UpgradeMaintenance = &v1alpha1.UpgradeMaintenanceSpec{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, curated.Enforcement.UpgradeMaintenance); err != nil {
+			return nil, fmt.Errorf("invalid spec.upgradeMaintenance: %w", err)
+		}
+	}
+	spec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&curated)
+	if err != nil {
+		return nil, err
+	}
+
+	out := obj.DeepCopy()
+	out.SetAPIVersion(v1beta1.GroupVersion.String())
+	out.Object["spec"] = spec
+
+	if len(values) > 0 {
+		data, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		annotations := out.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[v1beta1.ValuesAnnotation] = string(data)
+		out.SetAnnotations(annotations)
+	}
+	return out, nil
+}
+
+// toV1alpha1 restores the helm values saved by toV1beta1 and applies the
+// curated fields that differ from what those values already express.
+func toV1alpha1(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	spec := v1beta1.PolicyControllerSpec{}
+	if raw, found, err := unstructured.NestedMap(obj.Object, "spec"); err != nil {
+		return nil, err
+	} else if found {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &spec); err != nil {
+			return nil, err
+		}
+	}
+
+	values := map[string]interface{}{}
+	annotations := obj.GetAnnotations()
+	if data, ok := annotations[v1beta1.ValuesAnnotation]; ok {
+		if err := utiljson.Unmarshal([]byte(data), &values); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", v1beta1.ValuesAnnotation, err)
+		}
+	}
+
+	if err := applySpec(values, spec, specFromValues(values)); err != nil {
+		return nil, err
+	}
+
+	out := obj.DeepCopy()
+	out.SetAPIVersion(constants.PolicyControllerAPIVersion)
+	delete(annotations, v1beta1.ValuesAnnotation)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	out.SetAnnotations(annotations)
+	out.Object["spec"] = map[string]interface{}{}
+	if len(values) > 0 {
+		out.Object["spec"] = map[string]interface{}{"policy-controller": values}
+	}
+	if spec.Enforcement.WebhookDrift != "" {
+		if err := unstructured.SetNestedField(out.Object, string(spec.Enforcement.WebhookDrift), "spec", "webhookDrift", "mode"); err != nil {
+			return nil, err
+		}
+	}
+	if spec.Profile != "" {
+		if err := unstructured.SetNestedField(out.Object, string(spec.Profile), "spec", "profile"); err != nil {
+			return nil, err
+		}
+	}
+	if err := setOperatorField(out, "circuitBreaker", spec.Enforcement.CircuitBreaker); err != nil {
+		return nil, err
+	}
+	if err := setOperatorField(out, "upgradeMaintenance", spec.Enforcement.UpgradeMaintenance); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// setOperatorField sets a v1alpha1 spec field that the operator reads next to
+// the helm values. Nil values are left out.
+func setOperatorField[T any](obj *unstructured.Unstructured, field string, value *T) error {
+	if value == nil {
+		return nil
+	}
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(value)
+	if err != nil {
+		return err
+	}
+	return unstructured.SetNestedMap(obj.Object, m, "spec", field)
+}
+
+// specFromValues reads the curated fields from helm values. Values that do not
+// fit the v1beta1 schema are left out; they survive in the values annotation.
+func specFromValues(values map[string]interface{}) v1beta1.PolicyControllerSpec {
+	spec := v1beta1.PolicyControllerSpec{}
+
+	if v, found, _ := unstructured.NestedFieldNoCopy(values, "webhook", "extraArgs", "disable-tuf"); found {
+		if disabled, ok := parseBool(v); ok {
+			spec.Trust.Mode = v1beta1.TrustModePublicGoodTUF
+			if disabled {
+				spec.Trust.Mode = v1beta1.TrustModeTrustRoots
+			}
+		}
+	}
+
+	if fp, found, _ := unstructured.NestedString(values, "webhook", "failurePolicy"); found && fp != "" {
+		policy := admissionregistrationv1.FailurePolicyType(fp)
+		spec.Enforcement.FailurePolicy = &policy
+	}
+
+	if m, found, _ := unstructured.NestedMap(values, "webhook", "namespaceSelector"); found {
+		selector := &metav1.LabelSelector{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, selector); err == nil {
+			spec.Enforcement.NamespaceSelector = selector
+		}
+	}
+
+	if v, found, _ := unstructured.NestedFieldNoCopy(values, "webhook", "replicaCount"); found {
+		if replicas, ok := parseInt32(v); ok {
+			spec.Webhook.Replicas = &replicas
+		}
+	}
+
+	if m, found, _ := unstructured.NestedMap(values, "webhook", "podDisruptionBudget"); found {
+		pdb := &v1beta1.PodDisruptionBudgetSpec{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, pdb); err == nil {
+			spec.Webhook.PodDisruptionBudget = pdb
+		}
+	}
+
+	if pc, found, _ := unstructured.NestedString(values, "webhook", "priorityClass"); found {
+		spec.Webhook.PriorityClassName = pc
+	}
+
+	return spec
+}
+
+func applySpec(values map[string]interface{}, spec, current v1beta1.PolicyControllerSpec) error {
+	if spec.Trust.Mode != current.Trust.Mode {
+		var disableTUF interface{}
+		if spec.Trust.Mode != "" {
+			disableTUF = spec.Trust.Mode == v1beta1.TrustModeTrustRoots
+		}
+		if err := setOrRemove(values, disableTUF, "webhook", "extraArgs", "disable-tuf"); err != nil {
+			return err
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(spec.Enforcement.FailurePolicy, current.Enforcement.FailurePolicy) {
+		var fp interface{}
+		if spec.Enforcement.FailurePolicy != nil {
+			fp = string(*spec.Enforcement.FailurePolicy)
+		}
+		if err := setOrRemove(values, fp, "webhook", "failurePolicy"); err != nil {
+			return err
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(spec.Enforcement.NamespaceSelector, current.Enforcement.NamespaceSelector) {
+		var selector interface{}
+		if spec.Enforcement.NamespaceSelector != nil {
+			m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(spec.Enforcement.NamespaceSelector)
+			if err != nil {
+				return err
+			}
+			selector = m
+		}
+		if err := setOrRemove(values, selector, "webhook", "namespaceSelector"); err != nil {
+			return err
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(spec.Webhook.Replicas, current.Webhook.Replicas) {
+		var replicas interface{}
+		if spec.Webhook.Replicas != nil {
+			replicas = int64(*spec.Webhook.Replicas)
+		}
+		if err := setOrRemove(values, replicas, "webhook", "replicaCount"); err != nil {
+			return err
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(spec.Webhook.PodDisruptionBudget, current.Webhook.PodDisruptionBudget) {
+		if err := setOrRemove(values, podDisruptionBudgetValues(spec.Webhook.PodDisruptionBudget), "webhook", "podDisruptionBudget"); err != nil {
+			return err
+		}
+	}
+
+	if spec.Webhook.PriorityClassName != current.Webhook.PriorityClassName {
+		var pc interface{}
+		if spec.Webhook.PriorityClassName != "" {
+			pc = spec.Webhook.PriorityClassName
+		}
+		if err := setOrRemove(values, pc, "webhook", "priorityClass"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// podDisruptionBudgetValues renders the budget the way the chart expects it,
+// with integer budgets as numbers rather than IntOrString objects.
+func podDisruptionBudgetValues(pdb *v1beta1.PodDisruptionBudgetSpec) interface{} {
+	if pdb == nil {
+		return nil
+	}
+	values := map[string]interface{}{"enabled": pdb.Enabled}
+	if pdb.MinAvailable != nil {
+		values["minAvailable"] = intOrStringValue(*pdb.MinAvailable)
+	}
+	if pdb.MaxUnavailable != nil {
+		values["maxUnavailable"] = intOrStringValue(*pdb.MaxUnavailable)
+	}
+	return values
+}
+
+func intOrStringValue(v intstr.IntOrString) interface{} {
+	if v.Type == intstr.Int {
+		return int64(v.IntVal)
+	}
+	return v.StrVal
+}
+
+func setOrRemove(values map[string]interface{}, value interface{}, fields ...string) error {
+	if value == nil {
+		unstructured.RemoveNestedField(values, fields...)
+		return nil
+	}
+	return unstructured.SetNestedField(values, value, fields...)
+}
+
+func parseBool(v interface{}) (bool, bool) {
+	switch b := v.(type) {
+	case bool:
+		return b, true
+	case string:
+		switch b {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// parseInt32 accepts an integer written as a number or, as helm renders both
+// the same way, as a quoted number.
+func parseInt32(v interface{}) (int32, bool) {
+	switch n := v.(type) {
+	case int64:
+		if n >= math.MinInt32 && n <= math.MaxInt32 {
+			return int32(n), true
+		}
+	case string:
+		if i, err := strconv.ParseInt(n, 10, 32); err == nil {
+			return int32(i), true
+		}
+	}
+	return 0, false
+}
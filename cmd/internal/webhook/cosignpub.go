@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// minRSAKeyBits is the smallest RSA modulus accepted for signing keys.
+const minRSAKeyBits = 2048
+
+// cosignPubWarnings validates cosign.cosignPub, which the chart expects to
+// be a base64 encoded PEM public key, when it is set or changed. Its
+// fingerprint is then returned as a warning so that it can be compared with
+// the fingerprint of the intended key.
+func cosignPubWarnings(oldObj, newObj *v1alpha1.PolicyController) (admission.Warnings, error) {
+	value := newObj.Spec.PolicyController.Cosign.CosignPub
+	if value == "" || (oldObj != nil && oldObj.Spec.PolicyController.Cosign.CosignPub == value) {
+		return nil, nil
+	}
+	pub, err := parseCosignPub(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cosign.cosignPub: %w", err)
+	}
+	description, err := describeKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cosign.cosignPub: %w", err)
+	}
+	fingerprint, err := keyFingerprint(pub)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cosign.cosignPub: %w", err)
+	}
+	return admission.Warnings{fmt.Sprintf("cosign.cosignPub: %s public key with SHA-256 fingerprint %s", description, fingerprint)}, nil
+}
+
+// parseCosignPub decodes a base64 encoded PEM public key.
+func parseCosignPub(value string) (crypto.PublicKey, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return nil, errors.New("must be base64 encoded, not PEM")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("must be base64 encoded: %w", err)
+	}
+	return parsePublicKeyPEM(data)
+}
+
+// parsePublicKeyPEM parses the first PEM block of data as a public key.
+func parsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("does not contain a PEM encoded public key")
+	}
+	if block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("must be a PUBLIC KEY, not a %s", block.Type)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	return pub, nil
+}
+
+// describeKey names the algorithm of a signing key and rejects algorithms
+// cosign does not sign with and keys too weak to rely on.
+func describeKey(pub crypto.PublicKey) (string, error) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+			return "ECDSA " + k.Curve.Params().Name, nil
+		}
+		return "", fmt.Errorf("unsupported ECDSA curve %s", k.Curve.Params().Name)
+	case *rsa.PublicKey:
+		if bits := k.N.BitLen(); bits < minRSAKeyBits {
+			return "", fmt.Errorf("RSA key of %d bits is too weak, at least %d bits are required", bits, minRSAKeyBits)
+		}
+		return fmt.Sprintf("RSA %d", k.N.BitLen()), nil
+	case ed25519.PublicKey:
+		return "Ed25519", nil
+	}
+	return "", fmt.Errorf("unsupported public key type %T", pub)
+}
+
+// keyFingerprint is the SHA-256 digest of the DER encoded public key, as
+// printed by `openssl pkey -pubin -outform DER | sha256sum`.
+func keyFingerprint(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,313 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	kauth "github.com/google/go-containerregistry/pkg/authn/kubernetes"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DryRunPath is the path the DryRunHandler is served at.
+const DryRunPath = "/dry-run"
+
+// maxDryRunRequestBytes bounds the size of a dry-run request body.
+const maxDryRunRequestBytes = 64 << 10
+
+// dryRunTimeout bounds the evaluation of a dry run. Verifications that do not
+// finish in time are reported as Unknown.
+const dryRunTimeout = 20 * time.Second
+
+// DryRunRequest asks whether an image would be admitted in a namespace.
+type DryRunRequest struct {
+	Image     string `json:"image"`
+	Namespace string `json:"namespace"`
+	// ServiceAccountName and ImagePullSecrets are the ones of the Pod that
+	// would run the image. The policy webhook pulls signatures with the
+	// image pull secrets of both. Defaults to the default ServiceAccount.
+	ServiceAccountName string   `json:"serviceAccountName,omitempty"`
+	ImagePullSecrets   []string `json:"imagePullSecrets,omitempty"`
+}
+
+// DryRunResponse is the decision the policy webhook would make. Allowed is
+// false for Unknown verdicts, since the webhook may decide either way.
+type DryRunResponse struct {
+	Image     string         `json:"image"`
+	Namespace string         `json:"namespace"`
+	Allowed   bool           `json:"allowed"`
+	Verdict   policy.Verdict `json:"verdict"`
+	// Enforced is false if the namespaceSelector of the policy webhook does
+	// not select the namespace.
+	Enforced    bool                `json:"enforced"`
+	Reasons     []string            `json:"reasons,omitempty"`
+	Explanation *policy.Explanation `json:"explanation,omitempty"`
+}
+
+// DryRunHandler evaluates an image against the live ClusterImagePolicies and
+// TrustRoots for a namespace. Callers authenticate with a bearer token, which
+// is checked with a TokenReview, and need to be allowed to create Pods in the
+// namespace, which is checked with a SubjectAccessReview.
+//
+// Only the registries that the globs of the ClusterImagePolicies name are
+// contacted, so that callers cannot make the operator reach arbitrary hosts.
+type DryRunHandler struct {
+	// Client reads the PolicyController, the policies and the webhook
+	// Deployment and creates the reviews.
+	Client client.Client
+	// APIReader reads the namespace and its ServiceAccounts and Secrets.
+	APIReader client.Reader
+	// Registry serves images and their signatures. Defaults to the remote
+	// registries, authenticated the way the policy webhook is.
+	Registry policy.Registry
+}
+
+func (h *DryRunHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log := logf.Log.WithName("dry-run")
+	ctx := r.Context()
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "a bearer token is required", http.StatusUnauthorized)
+		return
+	}
+	user, err := h.authenticate(ctx, token)
+	if err != nil {
+		log.Error(err, "unable to review token")
+		http.Error(w, "unable to review token", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	req := &DryRunRequest{}
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxDryRunRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := validateDryRunRequest(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	allowed, err := h.authorize(ctx, user, req.Namespace)
+	if err != nil {
+		log.Error(err, "unable to review access", "user", user.Username)
+		http.Error(w, "unable to review access", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		http.Error(w, fmt.Sprintf("%s cannot create pods in namespace %q", user.Username, req.Namespace), http.StatusForbidden)
+		return
+	}
+
+	resp, err := h.evaluate(ctx, req)
+	switch {
+	case apierrors.IsNotFound(err):
+		http.Error(w, fmt.Sprintf("namespace %q not found", req.Namespace), http.StatusNotFound)
+		return
+	case err != nil:
+		log.Error(err, "unable to evaluate image", "image", req.Image, "namespace", req.Namespace)
+		http.Error(w, fmt.Sprintf("unable to evaluate image: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Info("dry run", "user", user.Username, "image", req.Image, "namespace", req.Namespace, "verdict", resp.Verdict)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error(err, "unable to encode dry-run response")
+	}
+}
+
+func validateDryRunRequest(req *DryRunRequest) error {
+	var errs []error
+	if req.Image == "" {
+		errs = append(errs, errors.New("image: required"))
+	} else if _, err := name.ParseReference(req.Image); err != nil {
+		errs = append(errs, fmt.Errorf("image: %v", err))
+	}
+	if req.Namespace == "" {
+		errs = append(errs, errors.New("namespace: required"))
+	} else if msgs := validation.IsDNS1123Label(req.Namespace); len(msgs) > 0 {
+		errs = append(errs, fmt.Errorf("namespace: %s", strings.Join(msgs, ", ")))
+	}
+	if req.ServiceAccountName != "" {
+		if msgs := validation.IsDNS1123Subdomain(req.ServiceAccountName); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("serviceAccountName: %s", strings.Join(msgs, ", ")))
+		}
+	}
+	for i, secret := range req.ImagePullSecrets {
+		if msgs := validation.IsDNS1123Subdomain(secret); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("imagePullSecrets[%d]: %s", i, strings.Join(msgs, ", ")))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// authenticate returns the user the token belongs to, or nil if it is not
+// valid.
+func (h *DryRunHandler) authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	review := &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}
+	if err := h.Client.Create(ctx, review); err != nil {
+		return nil, err
+	}
+	if !review.Status.Authenticated {
+		return nil, nil
+	}
+	return &review.Status.User, nil
+}
+
+// authorize checks that the user may create Pods in the namespace, which is
+// what the policy webhook would admit.
+func (h *DryRunHandler) authorize(ctx context.Context, user *authenticationv1.UserInfo, namespace string) (bool, error) {
+	return reviewAccess(ctx, h.Client, *user, authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "create",
+		Version:   "v1",
+		Resource:  "pods",
+	})
+}
+
+func (h *DryRunHandler) evaluate(ctx context.Context, req *DryRunRequest) (*DryRunResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, dryRunTimeout)
+	defer cancel()
+	resp := &DryRunResponse{Image: req.Image, Namespace: req.Namespace, Allowed: true, Verdict: policy.Allowed}
+
+	ns := &corev1.Namespace{}
+	if err := h.APIReader.Get(ctx, client.ObjectKey{Name: req.Namespace}, ns); err != nil {
+		return nil, err
+	}
+	pcs := &v1alpha1.PolicyControllerList{}
+	if err := h.Client.List(ctx, pcs, client.InNamespace(constants.PolicyControllerInstallNs)); err != nil {
+		return nil, err
+	}
+	if len(pcs.Items) == 0 {
+		resp.Reasons = []string{"no PolicyController is installed"}
+		return resp, nil
+	}
+	declared := controller.DeclaredNamespaceSelector(&pcs.Items[0])
+	selector, err := metav1.LabelSelectorAsSelector(&declared)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespaceSelector: %w", err)
+	}
+	if !selector.Matches(labels.Set(ns.Labels)) {
+		resp.Reasons = []string{fmt.Sprintf("image policies are not enforced in namespace %s, which namespaceSelector %s does not select", ns.Name, selector)}
+		return resp, nil
+	}
+
+	evaluator, err := controller.LoadEvaluator(ctx, h.Client, constants.PolicyControllerInstallNs, nil)
+	if err != nil {
+		return nil, err
+	}
+	registry := h.Registry
+	if registry == nil {
+		keychain, err := h.keychain(ctx, &pcs.Items[0], req)
+		if err != nil {
+			return nil, err
+		}
+		registry = &policy.Remote{Keychain: keychain}
+	}
+	evaluator.Verifier = &policy.RegistryVerifier{Registry: newNamedRegistry(registry, evaluator.Policies)}
+	explanation := evaluator.Explain(ctx, req.Image, policy.Pods)
+	result := explanation.Result()
+	resp.Enforced = true
+	resp.Verdict = explanation.Verdict
+	resp.Allowed = explanation.Verdict == policy.Allowed || explanation.Verdict == policy.Warned
+	resp.Reasons = result.Messages
+	resp.Explanation = &explanation
+	return resp, nil
+}
+
+// keychain returns the credentials the policy webhook pulls the signatures of
+// a Pod with: the image pull secrets of the Pod and of its ServiceAccount,
+// followed by the ones of the webhook Pods and their ServiceAccount.
+func (h *DryRunHandler) keychain(ctx context.Context, pc *v1alpha1.PolicyController, req *DryRunRequest) (authn.Keychain, error) {
+	secrets, err := controller.PullSecrets(ctx, h.APIReader, req.Namespace, req.ServiceAccountName, req.ImagePullSecrets)
+	if err != nil {
+		return nil, err
+	}
+	webhookSecrets, err := controller.WebhookPullSecrets(ctx, h.Client, pc)
+	if err != nil {
+		return nil, err
+	}
+	return kauth.NewFromPullSecrets(ctx, append(secrets, webhookSecrets...))
+}
+
+// namedRegistry serves images only from the registries that the globs of
+// the ClusterImagePolicies name, which keeps dry runs from reaching hosts the
+// policies do not apply to, such as cluster-internal services.
+type namedRegistry struct {
+	policy.Registry
+	registries map[string]bool
+}
+
+func newNamedRegistry(registry policy.Registry, policies []policy.ClusterImagePolicy) *namedRegistry {
+	r := &namedRegistry{Registry: registry, registries: map[string]bool{}}
+	for _, cip := range policies {
+		for _, pattern := range cip.Spec.Images {
+			if name := policy.GlobRegistry(pattern.Glob); name != "" {
+				r.registries[name] = true
+			}
+		}
+	}
+	return r
+}
+
+func (r *namedRegistry) check(ref name.Reference) error {
+	if registry := ref.Context().RegistryStr(); !r.registries[registry] {
+		return fmt.Errorf("dry runs only contact registries that ClusterImagePolicy globs name, and none names %s", registry)
+	}
+	return nil
+}
+
+func (r *namedRegistry) Digest(ctx context.Context, ref name.Reference) (v1.Hash, error) {
+	if err := r.check(ref); err != nil {
+		return v1.Hash{}, err
+	}
+	return r.Registry.Digest(ctx, ref)
+}
+
+func (r *namedRegistry) Image(ctx context.Context, ref name.Reference) (v1.Image, error) {
+	if err := r.check(ref); err != nil {
+		return nil, err
+	}
+	return r.Registry.Image(ctx, ref)
+}
+
+func (r *namedRegistry) Referrers(ctx context.Context, digest name.Digest) ([]v1.Descriptor, error) {
+	if err := r.check(digest); err != nil {
+		return nil, err
+	}
+	return r.Registry.Referrers(ctx, digest)
+}
@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// flagType is the type of a command line flag of the webhook binary.
+type flagType string
+
+const (
+	stringFlag   flagType = "string"
+	boolFlag     flagType = "bool"
+	intFlag      flagType = "int"
+	durationFlag flagType = "duration"
+)
+
+// WebhookFlagsVersion is the policy-controller version webhookFlags are
+// taken from. It has to be the appVersion of the vendored chart.
+const WebhookFlagsVersion = "0.13.1"
+
+// webhookFlags are the flags the --help output of the policy-controller
+// webhook lists. The chart passes webhook.extraArgs as -key=value, and the
+// webhook exits on flags it does not know. It parses its flags before the
+// knative libraries register theirs, so flags such as -kubeconfig, -disable-ha
+// or the klog flags are not accepted either.
+var webhookFlags = map[string]flagType{
+	"webhook-name":            stringFlag,
+	"mutating-webhook-name":   stringFlag,
+	"validating-webhook-name": stringFlag,
+	"tuf-mirror":              stringFlag,
+	"tuf-root":                stringFlag,
+	"disable-tuf":             boolFlag,
+	"policy-resync-period":    durationFlag,
+	"trustroot-resync-period": durationFlag,
+	"secure-port":             intFlag,
+}
+
+// validateExtraArgs denies webhook.extraArgs the webhook binary would not
+// start with: unknown flags and values that do not parse as the type of
+// their flag. On updates only the arguments that differ from oldObj are
+// checked.
+func validateExtraArgs(oldObj, obj *v1alpha1.PolicyController) error {
+	args := obj.Spec.PolicyController.Webhook.ExtraArgs
+	var oldArgs map[string]apiextensionsv1.JSON
+	if oldObj != nil {
+		oldArgs = oldObj.Spec.PolicyController.Webhook.ExtraArgs
+	}
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		if old, ok := oldArgs[key]; ok && jsonEqual(old.Raw, args[key].Raw) {
+			continue
+		}
+		typ, ok := webhookFlags[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("webhook.extraArgs.%s: unknown flag", key))
+			continue
+		}
+		if err := checkFlagValue(typ, args[key].Raw); err != nil {
+			errs = append(errs, fmt.Errorf("webhook.extraArgs.%s: %w", key, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid webhook.extraArgs: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// checkFlagValue checks a value the way the flag package parses it after
+// helm rendered it as text. Values that are not scalars cannot be rendered
+// as a flag.
+func checkFlagValue(typ flagType, raw []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return err
+	}
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case bool:
+		text = strconv.FormatBool(v)
+	case float64:
+		text = string(raw)
+	default:
+		return fmt.Errorf("must be of type %s, not %s", typ, strings.TrimSpace(string(raw)))
+	}
+
+	var err error
+	switch typ {
+	case boolFlag:
+		_, err = strconv.ParseBool(text)
+	case intFlag:
+		_, err = strconv.ParseInt(text, 0, 64)
+	case durationFlag:
+		_, err = time.ParseDuration(text)
+	}
+	if err != nil {
+		return fmt.Errorf("must be of type %s, not %q", typ, text)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:path=/validate-imagepolicyexception,mutating=false,failurePolicy=fail,groups=rhtas.charts.redhat.com,resources=imagepolicyexceptions,verbs=create;update,versions=v1alpha1,name=imagepolicyexceptions.rhtas.charts.redhat.com
+// ImagePolicyExceptionValidator makes the requester and approver of an
+// ImagePolicyException trustworthy. An exception has to be created without
+// an approver by the user it names as requester, and is approved by another
+// user setting the approver to themselves, which requires the approve verb on
+// the exception.
+type ImagePolicyExceptionValidator struct {
+	// Client creates SubjectAccessReviews.
+	Client client.Client
+}
+
+func (v *ImagePolicyExceptionValidator) ValidateCreate(ctx context.Context, obj *v1alpha1.ImagePolicyException) (admission.Warnings, error) {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, apierrors.NewInternalError(err)
+	}
+
+	var errs []error
+	if obj.Spec.Requester != req.UserInfo.Username {
+		errs = append(errs, fmt.Errorf("requester must be the requesting user %q", req.UserInfo.Username))
+	}
+	if obj.Spec.Approver != "" {
+		errs = append(errs, errors.New("approver must not be set on creation; another user approves the exception by setting it"))
+	}
+	now := time.Now()
+	switch expiresAt := obj.Spec.ExpiresAt.Time; {
+	case !expiresAt.After(now):
+		errs = append(errs, errors.New("expiresAt must be in the future"))
+	case expiresAt.Sub(now) > v1alpha1.MaxImagePolicyExceptionDuration:
+		errs = append(errs, fmt.Errorf("expiresAt must be within %s", v1alpha1.MaxImagePolicyExceptionDuration))
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid ImagePolicyException: %w", errors.Join(errs...))
+	}
+	return admission.Warnings{fmt.Sprintf("ImagePolicyException %s has no effect until another user approves it", obj.Name)}, nil
+}
+
+func (v *ImagePolicyExceptionValidator) ValidateUpdate(ctx context.Context, oldObj, newObj *v1alpha1.ImagePolicyException) (admission.Warnings, error) {
+	if oldObj.Spec.Approver == newObj.Spec.Approver {
+		return nil, nil
+	}
+	log := logf.FromContext(ctx)
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, apierrors.NewInternalError(err)
+	}
+
+	var errs []error
+	switch {
+	case oldObj.Spec.Approver != "":
+		errs = append(errs, errors.New("approver cannot be changed once set"))
+	case newObj.Spec.Approver != req.UserInfo.Username:
+		errs = append(errs, fmt.Errorf("approver must be the approving user %q", req.UserInfo.Username))
+	case newObj.Spec.Approver == newObj.Spec.Requester:
+		errs = append(errs, errors.New("approver must differ from requester"))
+	}
+	if !newObj.Spec.ExpiresAt.After(time.Now()) {
+		errs = append(errs, errors.New("an expired exception cannot be approved"))
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid ImagePolicyException: %w", errors.Join(errs...))
+	}
+
+	allowed, err := reviewAccess(ctx, v.Client, req.UserInfo, authorizationv1.ResourceAttributes{
+		Namespace: newObj.Namespace,
+		Verb:      v1alpha1.ImagePolicyExceptionApproveVerb,
+		Group:     constants.PolicyControllerGroup,
+		Resource:  "imagepolicyexceptions",
+		Name:      newObj.Name,
+	})
+	if err != nil {
+		return nil, apierrors.NewInternalError(fmt.Errorf("unable to review access: %w", err))
+	}
+	if !allowed {
+		log.Info("denying approval of ImagePolicyException", "namespace", newObj.Namespace, "name", newObj.Name, "user", req.UserInfo.Username)
+		return nil, apierrors.NewForbidden(v1alpha1.GroupVersion.WithResource("imagepolicyexceptions").GroupResource(), newObj.Name, fmt.Errorf(
+			"approving an exception requires the %s verb on imagepolicyexceptions in the %s API group",
+			v1alpha1.ImagePolicyExceptionApproveVerb, constants.PolicyControllerGroup))
+	}
+	log.Info("ImagePolicyException approved", "namespace", newObj.Namespace, "name", newObj.Name, "requester", newObj.Spec.Requester, "approver", newObj.Spec.Approver)
+	return nil, nil
+}
+
+func (v *ImagePolicyExceptionValidator) ValidateDelete(ctx context.Context, obj *v1alpha1.ImagePolicyException) (admission.Warnings, error) {
+	return nil, nil
+}
@@ -0,0 +1,275 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// ImageDigestMirrorSetGVK is the OpenShift mirror configuration for images
+// pulled by digest. Mirrors of allowed registries are allowed as well.
+var ImageDigestMirrorSetGVK = schema.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: "ImageDigestMirrorSet"}
+
+// Keys of the image reference policy ConfigMap.
+const (
+	ImageReferenceModeKey              = "mode"
+	ImageReferenceRequireDigestKey     = "requireDigest"
+	ImageReferenceAllowedRegistriesKey = "allowedRegistries"
+	// The release signer of the webhook image: either a PEM public key, or
+	// the issuer and subject of a keyless signature whose certificate
+	// authority is read from a TrustRoot.
+	ReleaseSignatureKeyKey       = "signatureKey"
+	ReleaseSignatureIssuerKey    = "signatureIssuer"
+	ReleaseSignatureSubjectKey   = "signatureSubject"
+	ReleaseSignatureTrustRootKey = "signatureTrustRoot"
+)
+
+// ImageReferenceMode selects whether image references that violate the
+// policy are denied or only warned about.
+type ImageReferenceMode string
+
+const (
+	ImageReferenceDeny ImageReferenceMode = "Deny"
+	ImageReferenceWarn ImageReferenceMode = "Warn"
+)
+
+// DefaultAllowedRegistry is allowed when the policy ConfigMap does not list
+// any registries.
+const DefaultAllowedRegistry = "registry.redhat.io"
+
+// imageReferencePolicy restricts the images a PolicyController may deploy.
+type imageReferencePolicy struct {
+	mode          ImageReferenceMode
+	requireDigest bool
+	// allowed are registries or repository prefixes, including the mirrors
+	// of the configured ones.
+	allowed []string
+	// release is the authority the webhook image has to be signed by. The
+	// signature is not verified when it is nil.
+	release *policy.Authority
+}
+
+// imageReferenceWarnings checks the image references that are set or changed
+// by the PolicyController against the image reference policy. References
+// that did not change are not checked again, so that tightening the policy
+// does not block unrelated updates.
+func (v *PolicyControllerValidator) imageReferenceWarnings(ctx context.Context, oldObj, newObj *v1alpha1.PolicyController) (admission.Warnings, error) {
+	type image struct {
+		field      string
+		values     func(*v1alpha1.PolicyController) v1alpha1.ImageValues
+		repository string
+		digest     bool
+		signed     bool
+	}
+	images := []image{
+		{
+			field: "webhook.image",
+			values: func(pc *v1alpha1.PolicyController) v1alpha1.ImageValues {
+				return pc.Spec.PolicyController.Webhook.Image
+			},
+			repository: constants.DefaultWebhookImageRepository,
+			digest:     true,
+			signed:     true,
+		},
+		{
+			// The lease cleanup Job is no longer deployed, so its defaults are
+			// not checked.
+			field: "leasescleanup.image",
+			values: func(pc *v1alpha1.PolicyController) v1alpha1.ImageValues {
+				return pc.Spec.PolicyController.LeasesCleanup.Image
+			},
+		},
+	}
+
+	var changed []image
+	for _, img := range images {
+		values := img.values(newObj)
+		if values.Repository == "" && values.Version == "" {
+			continue
+		}
+		if oldObj != nil {
+			old := img.values(oldObj)
+			if old.Repository == values.Repository && old.Version == values.Version {
+				continue
+			}
+		}
+		changed = append(changed, img)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	rules, err := v.imageReferencePolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, img := range changed {
+		values := img.values(newObj)
+		if values.Repository == "" {
+			values.Repository = img.repository
+		}
+		if err := rules.check(values, img.digest); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", img.field, err))
+			continue
+		}
+		// Without a version the chart deploys the webhook image of the
+		// operator release, pinned by digest.
+		if img.signed && rules.release != nil && values.Version != "" {
+			if err := v.verifyReleaseSignature(ctx, reference(values), *rules.release); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", img.field, err))
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil, nil
+	}
+	err = fmt.Errorf("image references violate the image reference policy: %w", errors.Join(errs...))
+	if rules.mode == ImageReferenceWarn {
+		return admission.Warnings{err.Error()}, nil
+	}
+	return nil, err
+}
+
+// check validates an image reference. defaultDigest tells whether the
+// chart default for an empty version is a digest.
+func (p *imageReferencePolicy) check(values v1alpha1.ImageValues, defaultDigest bool) error {
+	ref := reference(values)
+	digest := strings.HasPrefix(values.Version, "sha256:") || (defaultDigest && values.Version == "")
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		return fmt.Errorf("invalid image reference %q: %w", ref, err)
+	}
+
+	var errs []error
+	if p.requireDigest && !digest {
+		errs = append(errs, fmt.Errorf("%q must be pinned by digest", ref))
+	}
+	if !p.allows(parsed.Context().Name()) {
+		errs = append(errs, fmt.Errorf("%q is not in an allowed registry (%s)", ref, strings.Join(p.allowed, ", ")))
+	}
+	return errors.Join(errs...)
+}
+
+// reference renders the image reference the way the chart does. Versions
+// starting with "sha256:" are digests.
+func reference(values v1alpha1.ImageValues) string {
+	switch {
+	case strings.HasPrefix(values.Version, "sha256:"):
+		return values.Repository + "@" + values.Version
+	case values.Version != "":
+		return values.Repository + ":" + values.Version
+	}
+	return values.Repository
+}
+
+func (p *imageReferencePolicy) allows(repository string) bool {
+	for _, allowed := range p.allowed {
+		if within(repository, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// within tells whether the repository is the prefix or below it.
+func within(repository, prefix string) bool {
+	return repository == prefix || strings.HasPrefix(repository, prefix+"/")
+}
+
+// imageReferencePolicy reads the policy from its ConfigMap and adds the
+// mirrors of the allowed registries. Without a client the defaults apply.
+func (v *PolicyControllerValidator) imageReferencePolicy(ctx context.Context) (*imageReferencePolicy, error) {
+	rules := &imageReferencePolicy{mode: ImageReferenceDeny, requireDigest: true, allowed: []string{DefaultAllowedRegistry}}
+	if v.Client == nil {
+		return rules, nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := v.Client.Get(ctx, client.ObjectKey{Namespace: constants.PolicyControllerInstallNs, Name: constants.ImageReferencePolicyConfigMap}, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("unable to read the image reference policy: %w", err)
+	}
+	if mode, ok := cm.Data[ImageReferenceModeKey]; ok {
+		switch ImageReferenceMode(mode) {
+		case ImageReferenceDeny, ImageReferenceWarn:
+			rules.mode = ImageReferenceMode(mode)
+		default:
+			return nil, fmt.Errorf("invalid %s in ConfigMap %s: must be %s or %s", ImageReferenceModeKey, constants.ImageReferencePolicyConfigMap, ImageReferenceDeny, ImageReferenceWarn)
+		}
+	}
+	if value, ok := cm.Data[ImageReferenceRequireDigestKey]; ok {
+		if rules.requireDigest, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("invalid %s in ConfigMap %s: %w", ImageReferenceRequireDigestKey, constants.ImageReferencePolicyConfigMap, err)
+		}
+	}
+	if registries := strings.FieldsFunc(cm.Data[ImageReferenceAllowedRegistriesKey], func(r rune) bool {
+		return r == ',' || r == '\n' || r == ' '
+	}); len(registries) > 0 {
+		rules.allowed = nil
+		for _, registry := range registries {
+			rules.allowed = append(rules.allowed, strings.TrimSuffix(registry, "/"))
+		}
+	}
+	if rules.release, err = releaseAuthority(cm.Data); err != nil {
+		return nil, fmt.Errorf("invalid release signer in ConfigMap %s: %w", constants.ImageReferencePolicyConfigMap, err)
+	}
+
+	mirrors, err := v.digestMirrors(ctx, rules.allowed)
+	if err != nil {
+		return nil, err
+	}
+	rules.allowed = append(rules.allowed, mirrors...)
+	return rules, nil
+}
+
+// digestMirrors returns the mirrors the ImageDigestMirrorSets configure for
+// the allowed registries. Clusters without the API have no mirrors.
+func (v *PolicyControllerValidator) digestMirrors(ctx context.Context, allowed []string) ([]string, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(ImageDigestMirrorSetGVK.GroupVersion().WithKind(ImageDigestMirrorSetGVK.Kind + "List"))
+	if err := v.Client.List(ctx, list); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to list ImageDigestMirrorSets: %w", err)
+	}
+
+	var mirrors []string
+	for _, idms := range list.Items {
+		entries, _, _ := unstructured.NestedSlice(idms.Object, "spec", "imageDigestMirrors")
+		for _, entry := range entries {
+			m, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			source, _, _ := unstructured.NestedString(m, "source")
+			targets, _, _ := unstructured.NestedStringSlice(m, "mirrors")
+			for _, registry := range allowed {
+				for _, mirror := range targets {
+					switch {
+					case within(source, registry):
+						mirrors = append(mirrors, mirror)
+					case within(registry, source):
+						mirrors = append(mirrors, mirror+strings.TrimPrefix(registry, source))
+					}
+				}
+			}
+		}
+	}
+	return mirrors, nil
+}
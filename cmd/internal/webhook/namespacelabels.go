@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// NamespaceEnforcementSubresource is the virtual subresource of namespaces
+// in the rhtas.charts.redhat.com group that users need the update verb on to
+// change the labels that decide whether image policies are enforced.
+const NamespaceEnforcementSubresource = "enforcement"
+
+// +kubebuilder:webhook:path=/validate-namespace,mutating=false,failurePolicy=fail,groups="",resources=namespaces,verbs=update,versions=v1,name=namespaces.rhtas.charts.redhat.com
+// NamespaceLabelValidator denies changes to the namespace labels used by the
+// namespaceSelector of the policy webhooks, so that namespace admins cannot
+// opt out of image verification. Users allowed to update the enforcement
+// subresource of the namespace, such as the operator itself, are exempt.
+type NamespaceLabelValidator struct {
+	// Client reads the PolicyController and creates SubjectAccessReviews.
+	Client client.Client
+}
+
+func (v *NamespaceLabelValidator) ValidateCreate(ctx context.Context, obj *corev1.Namespace) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *NamespaceLabelValidator) ValidateUpdate(ctx context.Context, oldObj, newObj *corev1.Namespace) (admission.Warnings, error) {
+	log := logf.FromContext(ctx)
+
+	keys, err := v.protectedKeys(ctx)
+	if err != nil {
+		return nil, apierrors.NewInternalError(fmt.Errorf("unable to read the namespaceSelector: %w", err))
+	}
+	var changed []string
+	for _, key := range keys {
+		before, hadKey := oldObj.Labels[key]
+		after, hasKey := newObj.Labels[key]
+		if hadKey != hasKey || before != after {
+			changed = append(changed, "label "+key)
+		}
+	}
+	if oldObj.Annotations[constants.ExemptedLabelsAnnotation] != newObj.Annotations[constants.ExemptedLabelsAnnotation] {
+		changed = append(changed, "annotation "+constants.ExemptedLabelsAnnotation)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, apierrors.NewInternalError(err)
+	}
+	allowed, err := reviewAccess(ctx, v.Client, req.UserInfo, authorizationv1.ResourceAttributes{
+		Verb:        "update",
+		Group:       constants.PolicyControllerGroup,
+		Resource:    "namespaces",
+		Subresource: NamespaceEnforcementSubresource,
+		Name:        newObj.Name,
+	})
+	if err != nil {
+		return nil, apierrors.NewInternalError(fmt.Errorf("unable to review access: %w", err))
+	}
+	if !allowed {
+		log.Info("denying namespace update: enforcement labels changed", "namespace", newObj.Name, "user", req.UserInfo.Username, "changed", changed)
+		return nil, apierrors.NewForbidden(corev1.Resource("namespaces"), newObj.Name, fmt.Errorf(
+			"changing %s decides whether image policies are enforced and requires the update verb on namespaces/%s in the %s API group; use an ImagePolicyException for a temporary exemption",
+			strings.Join(changed, ", "), NamespaceEnforcementSubresource, constants.PolicyControllerGroup))
+	}
+	log.Info("enforcement labels changed", "namespace", newObj.Name, "user", req.UserInfo.Username, "changed", changed)
+	return nil, nil
+}
+
+func (v *NamespaceLabelValidator) ValidateDelete(ctx context.Context, obj *corev1.Namespace) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// protectedKeys are the label keys the namespaceSelector of the installed
+// PolicyController looks at.
+func (v *NamespaceLabelValidator) protectedKeys(ctx context.Context) ([]string, error) {
+	pcs := &v1alpha1.PolicyControllerList{}
+	if err := v.Client.List(ctx, pcs, client.InNamespace(constants.PolicyControllerInstallNs)); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var keys []string
+	for i := range pcs.Items {
+		selector := controller.DeclaredNamespaceSelector(&pcs.Items[i])
+		for key := range selector.MatchLabels {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		for _, expr := range selector.MatchExpressions {
+			if !seen[expr.Key] {
+				seen[expr.Key] = true
+				keys = append(keys, expr.Key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// maxListedNamespaces bounds the namespaces named in a warning, as the API
+// server truncates long warnings.
+const maxListedNamespaces = 10
+
+// namespaceSelectorWarnings reports the namespaces that an update of
+// webhook.namespaceSelector newly enforces or stops enforcing image policies
+// in, together with the number of workloads the newly enforced namespaces
+// run. Failures are reported as a warning rather than denying the update.
+func (v *PolicyControllerValidator) namespaceSelectorWarnings(ctx context.Context, oldObj, newObj *v1alpha1.PolicyController) admission.Warnings {
+	if v.Client == nil {
+		return nil
+	}
+	oldSelector := controller.DeclaredNamespaceSelector(oldObj)
+	newSelector := controller.DeclaredNamespaceSelector(newObj)
+	if equality.Semantic.DeepEqual(oldSelector, newSelector) {
+		return nil
+	}
+
+	log := logf.FromContext(ctx)
+	before, err := metav1.LabelSelectorAsSelector(&oldSelector)
+	if err != nil {
+		before = labels.Nothing()
+	}
+	after, err := metav1.LabelSelectorAsSelector(&newSelector)
+	if err != nil {
+		// The invalid selector is rejected by the API server when the chart
+		// renders it, so there is no impact to report.
+		return nil
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := v.Client.List(ctx, namespaces); err != nil {
+		log.Error(err, "unable to list namespaces")
+		return admission.Warnings{fmt.Sprintf("unable to determine the namespaces affected by the namespaceSelector change: %v", err)}
+	}
+
+	var enforced, unenforced []string
+	for _, ns := range namespaces.Items {
+		was, is := before.Matches(labels.Set(ns.Labels)), after.Matches(labels.Set(ns.Labels))
+		switch {
+		case is && !was:
+			enforced = append(enforced, ns.Name)
+		case was && !is:
+			unenforced = append(unenforced, ns.Name)
+		}
+	}
+	sort.Strings(enforced)
+	sort.Strings(unenforced)
+
+	var warnings admission.Warnings
+	if len(enforced) > 0 {
+		listed := make([]string, 0, min(len(enforced), maxListedNamespaces))
+		total := 0
+		for i, ns := range enforced {
+			count, err := controller.CountWorkloads(ctx, v.Client, ns)
+			if err != nil {
+				log.Error(err, "unable to count workloads", "namespace", ns)
+				return append(warnings, fmt.Sprintf("namespaceSelector change enforces image policies in %d %s: %s; unable to count their workloads: %v",
+					len(enforced), plural(len(enforced), "namespace"), listNamespaces(enforced), err))
+			}
+			total += count
+			if i < maxListedNamespaces {
+				listed = append(listed, fmt.Sprintf("%s (%d)", ns, count))
+			}
+		}
+		if more := len(enforced) - len(listed); more > 0 {
+			listed = append(listed, fmt.Sprintf("and %d more", more))
+		}
+		warnings = append(warnings, fmt.Sprintf("namespaceSelector change enforces image policies in %d %s running %d %s: %s",
+			len(enforced), plural(len(enforced), "namespace"), total, plural(total, "workload"), strings.Join(listed, ", ")))
+	}
+	if len(unenforced) > 0 {
+		warnings = append(warnings, fmt.Sprintf("namespaceSelector change stops enforcing image policies in %d %s: %s",
+			len(unenforced), plural(len(unenforced), "namespace"), listNamespaces(unenforced)))
+	}
+	return warnings
+}
+
+func listNamespaces(namespaces []string) string {
+	if len(namespaces) <= maxListedNamespaces {
+		return strings.Join(namespaces, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(namespaces[:maxListedNamespaces], ", "), len(namespaces)-maxListedNamespaces)
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
@@ -0,0 +1,261 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	sigsjson "sigs.k8s.io/json"
+)
+
+// valuesPath is the path of the helm values in a PolicyController.
+var valuesPath = field.NewPath("spec", "policy-controller")
+
+// podValue is a subtree of the helm values that the chart copies into the
+// webhook pod spec, and the type it ends up as.
+type podValue struct {
+	path []string
+	new  func() interface{}
+}
+
+var podValues = []podValue{
+	{path: []string{"webhook", "affinity"}, new: func() interface{} { return &corev1.Affinity{} }},
+	{path: []string{"commonTolerations"}, new: func() interface{} { return &[]corev1.Toleration{} }},
+	{path: []string{"commonNodeSelector"}, new: func() interface{} { return &map[string]string{} }},
+	// Despite its name, the chart renders webhook.podSecurityContext as the
+	// securityContext of the webhook container, next to its enabled switch.
+	{path: []string{"webhook", "podSecurityContext"}, new: func() interface{} {
+		return &struct {
+			Enabled                bool `json:"enabled"`
+			corev1.SecurityContext `json:",inline"`
+		}{}
+	}},
+	{path: []string{"webhook", "volumes"}, new: func() interface{} { return &[]corev1.Volume{} }},
+	{path: []string{"webhook", "volumeMounts"}, new: func() interface{} { return &[]corev1.VolumeMount{} }},
+}
+
+// validatePodValues strictly decodes the helm values that end up in the
+// webhook pod spec, so that mistakes are reported on admission rather than
+// when the helm-operator fails to apply the Deployment. The values are read
+// from the raw admission request, since the PolicyController type drops
+// unknown fields. On updates only the values that differ from oldObj are
+// checked.
+func validatePodValues(ctx context.Context, oldObj, obj *v1alpha1.PolicyController) error {
+	values, err := rawValues(ctx, obj)
+	if err != nil {
+		return err
+	}
+	var oldValues json.RawMessage
+	if oldObj != nil {
+		if oldValues, err = rawOldValues(ctx, oldObj); err != nil {
+			return err
+		}
+	}
+
+	var errs field.ErrorList
+	for _, v := range podValues {
+		raw, ok := lookup(values, v.path)
+		if !ok {
+			continue
+		}
+		if old, ok := lookup(oldValues, v.path); ok && jsonEqual(old, raw) {
+			continue
+		}
+		path := valuesPath.Child(v.path[0], v.path[1:]...)
+		strictErrs, err := sigsjson.UnmarshalStrict(raw, v.new())
+		if err != nil {
+			errs = append(errs, field.Invalid(path, string(raw), err.Error()))
+			continue
+		}
+		for _, strictErr := range strictErrs {
+			errs = append(errs, strictError(path, strictErr))
+		}
+	}
+
+	var old v1alpha1.PolicyControllerValues
+	if oldObj != nil {
+		old = oldObj.Spec.PolicyController
+	}
+	if resources := obj.Spec.PolicyController.Webhook.Resources; oldObj == nil || !equality.Semantic.DeepEqual(old.Webhook.Resources, resources) {
+		errs = append(errs, validateResources(valuesPath.Child("webhook", "resources"), resources)...)
+	}
+	if resources := obj.Spec.PolicyController.LeasesCleanup.Resources; oldObj == nil || !equality.Semantic.DeepEqual(old.LeasesCleanup.Resources, resources) {
+		errs = append(errs, validateResources(valuesPath.Child("leasescleanup", "resources"), resources)...)
+	}
+	return errs.ToAggregate()
+}
+
+// validateSpecValues reports the values of the object in the admission
+// request that do not fit the type of their field, which the PolicyController
+// type drops when it is decoded. On updates values that were already invalid
+// in the old object are not reported, so that other values, or the invalid
+// value itself, can still be changed.
+func validateSpecValues(ctx context.Context) error {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil
+	}
+	spec, err := specOf(req.Object.Raw)
+	if err != nil || spec == nil {
+		return err
+	}
+	errs := v1alpha1.ValidateSpecValues(spec)
+	if oldSpec, err := specOf(req.OldObject.Raw); err == nil && oldSpec != nil {
+		existing := map[string]bool{}
+		for _, err := range v1alpha1.ValidateSpecValues(oldSpec) {
+			existing[err.Error()] = true
+		}
+		errs = errs.Filter(func(err error) bool { return existing[err.Error()] })
+	}
+	return errs.ToAggregate()
+}
+
+// specOf returns the spec of the serialized object, or nil if there is none.
+func specOf(data []byte) (json.RawMessage, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var object struct {
+		Spec json.RawMessage `json:"spec"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	if string(object.Spec) == "null" {
+		return nil, nil
+	}
+	return object.Spec, nil
+}
+
+// strictError turns an unknown or duplicate field reported by the strict
+// decoder into an error on the path of that field.
+func strictError(path *field.Path, err error) *field.Error {
+	fp, ok := err.(interface{ FieldPath() string })
+	if !ok {
+		return field.Invalid(path, nil, err.Error())
+	}
+	detail := strings.TrimSuffix(err.Error(), " "+strconv.Quote(fp.FieldPath()))
+	for _, name := range strings.Split(fp.FieldPath(), ".") {
+		name, indices, _ := strings.Cut(name, "[")
+		if name != "" {
+			path = path.Child(name)
+		}
+		if indices == "" {
+			continue
+		}
+		for _, index := range strings.Split(strings.TrimSuffix(indices, "]"), "][") {
+			if i, err := strconv.Atoi(index); err == nil {
+				path = path.Index(i)
+			} else {
+				path = path.Key(index)
+			}
+		}
+	}
+	return field.Forbidden(path, detail)
+}
+
+// rawValues returns the helm values of the object in the admission request.
+// Outside of an admission request the typed object is used.
+func rawValues(ctx context.Context, obj *v1alpha1.PolicyController) (json.RawMessage, error) {
+	var data []byte
+	if req, err := admission.RequestFromContext(ctx); err == nil {
+		data = req.Object.Raw
+	}
+	return valuesOf(data, obj)
+}
+
+// rawOldValues returns the helm values of the old object in the admission
+// request. Outside of an admission request the typed object is used.
+func rawOldValues(ctx context.Context, obj *v1alpha1.PolicyController) (json.RawMessage, error) {
+	var data []byte
+	if req, err := admission.RequestFromContext(ctx); err == nil {
+		data = req.OldObject.Raw
+	}
+	return valuesOf(data, obj)
+}
+
+// valuesOf returns the helm values of the serialized object, or of the typed
+// object if data is empty.
+func valuesOf(data []byte, obj *v1alpha1.PolicyController) (json.RawMessage, error) {
+	if len(data) == 0 {
+		var err error
+		if data, err = json.Marshal(obj); err != nil {
+			return nil, err
+		}
+	}
+	var object struct {
+		Spec struct {
+			Values json.RawMessage `json:"policy-controller"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	return object.Spec.Values, nil
+}
+
+// lookup returns the raw value at the path. Missing and null values are not
+// found, since the chart skips them.
+func lookup(values json.RawMessage, path []string) (json.RawMessage, bool) {
+	for _, key := range path {
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(values, &m); err != nil {
+			return nil, false
+		}
+		var ok bool
+		if values, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return values, len(values) > 0 && string(values) != "null"
+}
+
+// jsonEqual reports whether two JSON documents hold the same value,
+// regardless of their formatting and the order of object keys.
+func jsonEqual(a, b json.RawMessage) bool {
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// validateResources parses the resource quantities and checks that no
+// request exceeds its limit. Empty quantities are skipped by the chart.
+func validateResources(path *field.Path, resources v1alpha1.ResourceValues) field.ErrorList {
+	var errs field.ErrorList
+	parse := func(path *field.Path, list v1alpha1.ResourceListValues) map[corev1.ResourceName]resource.Quantity {
+		quantities := map[corev1.ResourceName]resource.Quantity{}
+		for name, value := range list {
+			if value == "" {
+				continue
+			}
+			q, err := resource.ParseQuantity(value)
+			if err != nil {
+				errs = append(errs, field.Invalid(path.Key(string(name)), value, err.Error()))
+				continue
+			}
+			quantities[name] = q
+		}
+		return quantities
+	}
+	limits := parse(path.Child("limits"), resources.Limits)
+	requests := parse(path.Child("requests"), resources.Requests)
+
+	for name, request := range requests {
+		if limit, ok := limits[name]; ok && request.Cmp(limit) > 0 {
+			errs = append(errs, field.Invalid(path.Child("requests").Key(string(name)), request.String(),
+				fmt.Sprintf("must be less than or equal to the %s limit %s", name, limit.String())))
+		}
+	}
+	return errs
+}
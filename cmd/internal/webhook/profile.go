@@ -0,0 +1,130 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+)
+
+// Resource requests defaulted by the production profile. They match the
+// chart defaults.
+const (
+	productionCPURequest    = "100m"
+	productionMemoryRequest = "128Mi"
+)
+
+// +kubebuilder:webhook:path=/mutate,mutating=true,failurePolicy=fail,groups=rhtas.charts.redhat.com,resources=policycontrollers,verbs=create;update,versions=v1alpha1,name=profile.policycontrollers.rhtas.charts.redhat.com
+// PolicyControllerDefaulter applies the defaults of the deployment profile.
+// Only values that are not set are defaulted; values that do not meet the
+// requirements of the profile are left to the validator to reject.
+type PolicyControllerDefaulter struct{}
+
+func (d *PolicyControllerDefaulter) Default(ctx context.Context, obj *v1alpha1.PolicyController) error {
+	if obj.Spec.Profile == "" {
+		obj.Spec.Profile = v1alpha1.ProfileDev
+	}
+	if obj.Spec.Profile != v1alpha1.ProfileProduction {
+		return nil
+	}
+
+	webhook := &obj.Spec.PolicyController.Webhook
+	if webhook.ReplicaCount == nil {
+		webhook.ReplicaCount = ptr.To(int32(2))
+	}
+	pdb := &webhook.PodDisruptionBudget
+	if !pdb.Enabled && pdb.MinAvailable == nil && pdb.MaxUnavailable == nil {
+		pdb.Enabled = true
+		pdb.MinAvailable = ptr.To(intstr.FromInt32(1))
+	}
+	if webhook.Affinity == nil {
+		webhook.Affinity = spreadAffinity(obj.Name)
+	}
+	if webhook.Resources.Requests == nil {
+		webhook.Resources.Requests = v1alpha1.ResourceListValues{}
+	}
+	if webhook.Resources.Requests[corev1.ResourceCPU] == "" {
+		webhook.Resources.Requests[corev1.ResourceCPU] = productionCPURequest
+	}
+	if webhook.Resources.Requests[corev1.ResourceMemory] == "" {
+		webhook.Resources.Requests[corev1.ResourceMemory] = productionMemoryRequest
+	}
+	return nil
+}
+
+// spreadAffinity prefers to schedule the webhook pods of the release on
+// different nodes and, where the cluster has them, in different zones.
+func spreadAffinity(release string) *corev1.Affinity {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{
+		"app.kubernetes.io/name":     "policy-controller",
+		"app.kubernetes.io/instance": release,
+	}}
+	return &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+		PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+			{Weight: 100, PodAffinityTerm: corev1.PodAffinityTerm{LabelSelector: selector, TopologyKey: corev1.LabelHostname}},
+			{Weight: 50, PodAffinityTerm: corev1.PodAffinityTerm{LabelSelector: selector, TopologyKey: corev1.LabelTopologyZone}},
+		},
+	}}
+}
+
+// validateProfile checks the requirements of the production profile. The
+// dev profile has none. While the profile stays the same, only the values
+// that differ from oldObj are checked.
+func validateProfile(oldObj, obj *v1alpha1.PolicyController) error {
+	if obj.Spec.Profile != v1alpha1.ProfileProduction {
+		return nil
+	}
+
+	webhook := obj.Spec.PolicyController.Webhook
+	var old v1alpha1.WebhookValues
+	sameProfile := oldObj != nil && oldObj.Spec.Profile == obj.Spec.Profile
+	if sameProfile {
+		old = oldObj.Spec.PolicyController.Webhook
+	}
+	changed := func(oldValue, value interface{}) bool {
+		return !sameProfile || !equality.Semantic.DeepEqual(oldValue, value)
+	}
+
+	var errs []error
+	if changed(old.ReplicaCount, webhook.ReplicaCount) && (webhook.ReplicaCount == nil || *webhook.ReplicaCount < 2) {
+		errs = append(errs, errors.New("webhook.replicaCount must be at least 2"))
+	}
+	if changed(old.PodDisruptionBudget.Enabled, webhook.PodDisruptionBudget.Enabled) && !webhook.PodDisruptionBudget.Enabled {
+		errs = append(errs, errors.New("webhook.podDisruptionBudget must be enabled"))
+	}
+	if changed(old.Affinity, webhook.Affinity) && !spreadsPods(webhook.Affinity) {
+		errs = append(errs, errors.New("webhook.affinity must spread the webhook pods with a podAntiAffinity term"))
+	}
+	for _, resource := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if changed(old.Resources.Requests[resource], webhook.Resources.Requests[resource]) && webhook.Resources.Requests[resource] == "" {
+			errs = append(errs, fmt.Errorf("webhook.resources.requests.%s must be set", resource))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("the %s profile requires a highly available webhook: %w", v1alpha1.ProfileProduction, errors.Join(errs...))
+	}
+	return nil
+}
+
+func spreadsPods(affinity *corev1.Affinity) bool {
+	if affinity == nil || affinity.PodAntiAffinity == nil {
+		return false
+	}
+	for _, term := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		if term.TopologyKey != "" {
+			return true
+		}
+	}
+	for _, term := range affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		if term.PodAffinityTerm.TopologyKey != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// objectReference is an object the helm values refer to by name.
+type objectReference struct {
+	field string
+	kind  string
+	name  string
+	// key is a key the ConfigMap has to contain.
+	key string
+	obj client.Object
+	// optional references only cause a warning when the object is missing,
+	// as the pods still start without it.
+	optional bool
+}
+
+// referenceWarnings looks up the objects the helm values refer to. Missing
+// objects the webhook pods cannot start without, which would leave them
+// Pending or in CreateContainerConfigError, deny the request. The others,
+// objects oldObj already referred to, and lookups that fail are reported as
+// warnings.
+func (v *PolicyControllerValidator) referenceWarnings(ctx context.Context, oldObj, obj *v1alpha1.PolicyController) (admission.Warnings, error) {
+	if v.Cache == nil {
+		return nil, nil
+	}
+	raw, err := rawValues(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	refs := references(obj, raw)
+	referenced := map[referenceKey]bool{}
+	if oldObj != nil {
+		oldRaw, err := rawOldValues(ctx, oldObj)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range references(oldObj, oldRaw) {
+			referenced[ref.referenceKey()] = true
+		}
+	}
+
+	var warnings admission.Warnings
+	var errs []error
+	for _, ref := range refs {
+		key := client.ObjectKey{Name: ref.name}
+		if ref.kind != "PriorityClass" {
+			key.Namespace = constants.PolicyControllerInstallNs
+		}
+		err := v.Cache.Get(ctx, key, ref.obj)
+		var missing string
+		switch {
+		case apierrors.IsNotFound(err):
+			missing = fmt.Sprintf("%s: %s %q not found", ref.field, ref.kind, ref.name)
+			if key.Namespace != "" {
+				missing += fmt.Sprintf(" in namespace %q", key.Namespace)
+			}
+		case err != nil:
+			warnings = append(warnings, fmt.Sprintf("%s: unable to check %s %q: %v", ref.field, ref.kind, ref.name, err))
+			continue
+		case ref.key != "":
+			cm := ref.obj.(*corev1.ConfigMap)
+			_, inData := cm.Data[ref.key]
+			_, inBinaryData := cm.BinaryData[ref.key]
+			if !inData && !inBinaryData {
+				missing = fmt.Sprintf("%s: key %q not found in %s %q", ref.field, ref.key, ref.kind, ref.name)
+			}
+		}
+		switch {
+		case missing == "":
+		case ref.optional || referenced[ref.referenceKey()]:
+			warnings = append(warnings, missing)
+		default:
+			errs = append(errs, errors.New(missing))
+		}
+	}
+	if len(errs) > 0 {
+		return warnings, fmt.Errorf("missing objects referenced by the values: %w", errors.Join(errs...))
+	}
+	return warnings, nil
+}
+
+// referenceKey identifies the object, and the key in it, that a reference
+// requires, regardless of the field it is referred to from.
+type referenceKey struct {
+	kind, name, key string
+}
+
+func (r objectReference) referenceKey() referenceKey {
+	return referenceKey{kind: r.kind, name: r.name, key: r.key}
+}
+
+// references lists the objects the chart refers to in the webhook pods. raw
+// are the helm values of obj, including those its type does not know.
+func references(obj *v1alpha1.PolicyController, raw json.RawMessage) []objectReference {
+	values := obj.Spec.PolicyController
+	var refs []objectReference
+
+	// imagePullSecrets are not part of the PolicyController type. Secrets the
+	// kubelet cannot find are skipped, so the image may still be pulled.
+	if secrets, ok := lookup(raw, []string{"imagePullSecrets"}); ok {
+		var pullSecrets []corev1.LocalObjectReference
+		if err := json.Unmarshal(secrets, &pullSecrets); err == nil {
+			for i, secret := range pullSecrets {
+				if secret.Name == "" {
+					continue
+				}
+				refs = append(refs, objectReference{
+					field: fmt.Sprintf("imagePullSecrets[%d]", i), kind: "Secret", name: secret.Name,
+					obj: &corev1.Secret{}, optional: true,
+				})
+			}
+		}
+	}
+
+	for i, name := range values.Webhook.EnvFrom.ConfigMaps {
+		refs = append(refs, objectReference{
+			field: fmt.Sprintf("webhook.envFrom.configmaps[%d]", i), kind: "ConfigMap", name: name, obj: &corev1.ConfigMap{},
+		})
+	}
+	for i, name := range values.Webhook.EnvFrom.Secrets {
+		refs = append(refs, objectReference{
+			field: fmt.Sprintf("webhook.envFrom.secrets[%d]", i), kind: "Secret", name: name, obj: &corev1.Secret{},
+		})
+	}
+	if bundle := values.Webhook.RegistryCaBundle; bundle.Name != "" {
+		refs = append(refs, objectReference{
+			field: "webhook.registryCaBundle", kind: "ConfigMap", name: bundle.Name, key: bundle.Key, obj: &corev1.ConfigMap{},
+		})
+	}
+	if name := values.Webhook.PriorityClass; name != "" {
+		refs = append(refs, objectReference{
+			field: "webhook.priorityClass", kind: "PriorityClass", name: name, obj: &schedulingv1.PriorityClass{},
+		})
+	}
+	// The leases cleanup Job is no longer run, so its priority class is only
+	// checked to point out a mistake.
+	if name := values.LeasesCleanup.PriorityClass; name != "" {
+		refs = append(refs, objectReference{
+			field: "leasescleanup.priorityClass", kind: "PriorityClass", name: name, obj: &schedulingv1.PriorityClass{},
+			optional: true,
+		})
+	}
+	// The chart creates the ServiceAccount unless told otherwise, and falls
+	// back to the default ServiceAccount when no name is given.
+	if sa := values.Webhook.ServiceAccount; sa.Create != nil && !*sa.Create && sa.Name != "" {
+		refs = append(refs, objectReference{
+			field: "webhook.serviceAccount.name", kind: "ServiceAccount", name: sa.Name, obj: &corev1.ServiceAccount{},
+		})
+	}
+	return refs
+}
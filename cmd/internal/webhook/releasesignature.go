@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// releaseSignatureTimeout bounds the verification of the webhook image, so
+// that a slow registry is reported before the 5 second timeout of the
+// validating webhook expires.
+const releaseSignatureTimeout = 4 * time.Second
+
+// releaseAuthority builds the authority the webhook image has to be signed
+// by from the image reference policy ConfigMap. It returns nil if no release
+// signer is configured.
+func releaseAuthority(data map[string]string) (*policy.Authority, error) {
+	key := data[ReleaseSignatureKeyKey]
+	issuer, subject := data[ReleaseSignatureIssuerKey], data[ReleaseSignatureSubjectKey]
+	trustRoot := data[ReleaseSignatureTrustRootKey]
+
+	switch {
+	case key != "" && (issuer != "" || subject != ""):
+		return nil, fmt.Errorf("%s and a keyless identity are mutually exclusive", ReleaseSignatureKeyKey)
+	case key != "":
+		ref := &policy.KeyRef{Data: key}
+		if _, _, err := policy.ParsePublicKey(ref); err != nil {
+			return nil, fmt.Errorf("%s: %w", ReleaseSignatureKeyKey, err)
+		}
+		return &policy.Authority{Name: "release-key", Key: ref}, nil
+	case issuer != "" || subject != "":
+		if issuer == "" || subject == "" {
+			return nil, fmt.Errorf("a keyless identity needs both %s and %s", ReleaseSignatureIssuerKey, ReleaseSignatureSubjectKey)
+		}
+		if trustRoot == "" {
+			return nil, fmt.Errorf("a keyless identity needs %s", ReleaseSignatureTrustRootKey)
+		}
+		return &policy.Authority{Name: "release-identity", Keyless: &policy.KeylessRef{
+			Identities:   []policy.Identity{{Issuer: issuer, Subject: subject}},
+			TrustRootRef: trustRoot,
+		}}, nil
+	}
+	return nil, nil
+}
+
+// verifyReleaseSignature verifies with sigstore-go that the image is signed
+// by the release signer. Images whose signature cannot be verified, for
+// example because the registry is unreachable or does not respond in time,
+// are denied as well, but with an error that says so.
+func (v *PolicyControllerValidator) verifyReleaseSignature(ctx context.Context, image string, authority policy.Authority) error {
+	ctx, cancel := context.WithTimeout(ctx, releaseSignatureTimeout)
+	defer cancel()
+
+	evaluator := &policy.Evaluator{
+		Policies: []policy.ClusterImagePolicy{{
+			Name: "release-signature",
+			Spec: policy.ClusterImagePolicySpec{
+				Images:      []policy.ImagePattern{{Glob: "**"}},
+				Authorities: []policy.Authority{authority},
+			},
+		}},
+		TrustRoots:    map[string]policy.TrustRoot{},
+		NoMatchPolicy: policy.NoMatchDeny,
+		Verifier:      v.Verifier,
+	}
+	if authority.Keyless != nil {
+		tr, err := v.trustRoot(ctx, authority.Keyless.TrustRootRef)
+		if err != nil {
+			return err
+		}
+		evaluator.TrustRoots[tr.Name] = tr
+	}
+
+	result := evaluator.Evaluate(ctx, image, policy.Pods)
+	switch {
+	case result.Verdict == policy.Allowed:
+		return nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("the signature of %q could not be verified within %s, retry once the registry responds", image, releaseSignatureTimeout)
+	case result.Verdict == policy.Unknown:
+		return fmt.Errorf("the signature of %q could not be verified: %s", image, strings.Join(result.Messages, "; "))
+	}
+	return fmt.Errorf("%q is not signed by the release signer: %s", image, strings.Join(result.Messages, "; "))
+}
+
+func (v *PolicyControllerValidator) trustRoot(ctx context.Context, name string) (policy.TrustRoot, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(controller.TrustRootGVK)
+	if err := v.Client.Get(ctx, client.ObjectKey{Name: name}, obj); err != nil {
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return policy.TrustRoot{}, fmt.Errorf("TrustRoot %q of the release signer not found", name)
+		}
+		return policy.TrustRoot{}, fmt.Errorf("unable to read TrustRoot %q: %w", name, err)
+	}
+	tr, err := policy.TrustRootFromUnstructured(obj, controller.IsReady(obj))
+	if err != nil {
+		return policy.TrustRoot{}, err
+	}
+	if tr.SigstoreKeys == nil {
+		return policy.TrustRoot{}, errors.New("the TrustRoot of the release signer has to list its keys in sigstoreKeys")
+	}
+	return tr, nil
+}
@@ -1,20 +1,40 @@
 package webhook_test
 
 import (
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
 	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
-func GeneratePolicyControllerObj(namespace string) *unstructured.Unstructured {
+func GeneratePolicyControllerObj(namespace string) *v1alpha1.PolicyController {
+	obj := &v1alpha1.PolicyController{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: constants.PolicyControllerAPIVersion,
+			Kind:       constants.PolicyControllerKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "policy-controller",
+			Namespace: namespace,
+		},
+	}
+	return obj
+}
+
+func GenerateV1alpha1PolicyController(values map[string]interface{}) *unstructured.Unstructured {
 	obj := &unstructured.Unstructured{
 		Object: map[string]interface{}{
 			"apiVersion": constants.PolicyControllerAPIVersion,
 			"kind":       constants.PolicyControllerKind,
 			"metadata": map[string]interface{}{
 				"name":      "policy-controller",
-				"namespace": namespace,
+				"namespace": constants.PolicyControllerInstallNs,
 			},
+			"spec": map[string]interface{}{},
 		},
 	}
+	if values != nil {
+		obj.Object["spec"] = map[string]interface{}{"policy-controller": values}
+	}
 	return obj
 }
@@ -2,19 +2,31 @@ package webhook_test
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
+	"time"
 
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
 	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
 	"github.com/securesign/policy-controller-operator/cmd/internal/webhook"
 	"github.com/stretchr/testify/require"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 func TestPolicyControllerValidator(t *testing.T) {
 	validator := webhook.PolicyControllerValidator{}
 	tests := []struct {
 		name      string
-		obj       *unstructured.Unstructured
+		obj       *v1alpha1.PolicyController
 		expectErr bool
 	}{
 		{
@@ -29,7 +41,7 @@ func TestPolicyControllerValidator(t *testing.T) {
 		},
 		{
 			name:      "wrong resource type",
-			obj:       &unstructured.Unstructured{},
+			obj:       &v1alpha1.PolicyController{},
 			expectErr: true,
 		},
 	}
@@ -55,3 +67,131 @@ func TestPolicyControllerValidator(t *testing.T) {
 		})
 	}
 }
+
+// TestPolicyControllerValidatorUnchangedValues checks that values accepted
+// before, and objects they refer to that have been deleted since, do not
+// block updates.
+func TestPolicyControllerValidatorUnchangedValues(t *testing.T) {
+	validator := webhook.PolicyControllerValidator{Cache: fake.NewClientBuilder().Build()}
+	oldObj := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+	values := &oldObj.Spec.PolicyController
+	values.Webhook.ExtraArgs = map[string]apiextensionsv1.JSON{"kubeconfig": {Raw: []byte(`"/kubeconfig"`)}}
+	values.Webhook.Resources.Limits = v1alpha1.ResourceListValues{corev1.ResourceMemory: "64Mi"}
+	values.Webhook.Resources.Requests = v1alpha1.ResourceListValues{corev1.ResourceMemory: "128Mi"}
+	values.Webhook.EnvFrom.ConfigMaps = []string{"proxy-env"}
+	values.Cosign.CosignPub = "not a key"
+	_, err := validator.ValidateCreate(context.Background(), oldObj)
+	require.Error(t, err)
+
+	newObj := oldObj.DeepCopy()
+	newObj.Finalizers = []string{"uninstall-helm-release"}
+	_, err = validator.ValidateUpdate(context.Background(), oldObj, newObj)
+	require.NoError(t, err, "unchanged spec")
+
+	newObj.Spec.PolicyController.Webhook.FailurePolicy = ptr.To(admissionregistrationv1.Ignore)
+	warnings, err := validator.ValidateUpdate(context.Background(), oldObj, newObj)
+	require.NoError(t, err, "unchanged values")
+	require.Equal(t, []string{`webhook.envFrom.configmaps[0]: ConfigMap "proxy-env" not found in namespace "policy-controller-operator"`}, []string(warnings))
+
+	newObj.Spec.PolicyController.Webhook.ExtraArgs["secure-port"] = apiextensionsv1.JSON{Raw: []byte(`"https"`)}
+	newObj.Spec.PolicyController.Webhook.EnvFrom.ConfigMaps = append(newObj.Spec.PolicyController.Webhook.EnvFrom.ConfigMaps, "aws-env")
+	_, err = validator.ValidateUpdate(context.Background(), oldObj, newObj)
+	require.ErrorContains(t, err, `webhook.extraArgs.secure-port: must be of type int, not "https"`)
+	require.NotContains(t, err.Error(), "kubeconfig")
+
+	newObj.DeletionTimestamp = ptr.To(metav1.Now())
+	_, err = validator.ValidateUpdate(context.Background(), oldObj, newObj)
+	require.NoError(t, err, "deletion")
+}
+
+func TestPolicyControllerValidatorNamespaceSelectorWarnings(t *testing.T) {
+	namespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	c := fake.NewClientBuilder().WithObjects(
+		namespace("included", map[string]string{"policy.rhtas.com/include": "true"}),
+		namespace("team-a", map[string]string{"team": "a"}),
+		namespace("team-b", map[string]string{"team": "b"}),
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "standalone"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "web"}},
+	).Build()
+	validator := webhook.PolicyControllerValidator{Client: c}
+
+	oldObj := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+	newObj := oldObj.DeepCopy()
+	newObj.Spec.PolicyController.Webhook.NamespaceSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: metav1.LabelSelectorOpExists}},
+	}
+
+	warnings, err := validator.ValidateUpdate(context.Background(), oldObj, newObj)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"namespaceSelector change enforces image policies in 2 namespaces running 2 workloads: team-a (2), team-b (0)",
+		"namespaceSelector change stops enforcing image policies in 1 namespace: included",
+	}, []string(warnings))
+
+	warnings, err = validator.ValidateUpdate(context.Background(), oldObj, oldObj)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+}
+
+func TestPolicyControllerValidatorBreakGlass(t *testing.T) {
+	validator := webhook.PolicyControllerValidator{}
+	ctx := admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		UserInfo: authenticationv1.UserInfo{Username: "oncall@example.com"},
+	}})
+	withBreakGlass := func(bg v1alpha1.BreakGlass) *v1alpha1.PolicyController {
+		obj := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+		value, err := json.Marshal(bg)
+		require.NoError(t, err)
+		obj.Annotations = map[string]string{v1alpha1.BreakGlassAnnotation: string(value)}
+		return obj
+	}
+	valid := v1alpha1.BreakGlass{
+		Mode:        v1alpha1.BreakGlassWarn,
+		Reason:      "INC-42",
+		RequestedBy: "oncall@example.com",
+		ExpiresAt:   metav1.NewTime(time.Now().Add(time.Hour)),
+	}
+
+	warnings, err := validator.ValidateUpdate(ctx, GeneratePolicyControllerObj(constants.PolicyControllerInstallNs), withBreakGlass(valid))
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	require.Contains(t, warnings[0], "suspended in Warn mode")
+
+	tests := []struct {
+		name     string
+		mutate   func(*v1alpha1.BreakGlass)
+		expected string
+	}{
+		{name: "other user", mutate: func(bg *v1alpha1.BreakGlass) { bg.RequestedBy = "someone-else" }, expected: `requestedBy must be the requesting user "oncall@example.com"`},
+		{name: "unknown mode", mutate: func(bg *v1alpha1.BreakGlass) { bg.Mode = "Off" }, expected: "mode must be Ignore or Warn"},
+		{name: "no reason", mutate: func(bg *v1alpha1.BreakGlass) { bg.Reason = "" }, expected: "reason must not be empty"},
+		{name: "expired", mutate: func(bg *v1alpha1.BreakGlass) { bg.ExpiresAt = metav1.NewTime(time.Now().Add(-time.Minute)) }, expected: "expiresAt must be in the future"},
+		{name: "too long", mutate: func(bg *v1alpha1.BreakGlass) { bg.ExpiresAt = metav1.NewTime(time.Now().Add(48 * time.Hour)) }, expected: "expiresAt must be within 24h0m0s"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			bg := valid
+			tc.mutate(&bg)
+			_, err := validator.ValidateCreate(ctx, withBreakGlass(bg))
+			require.ErrorContains(t, err, tc.expected)
+		})
+	}
+
+	t.Run("invalid json", func(t *testing.T) {
+		obj := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+		obj.Annotations = map[string]string{v1alpha1.BreakGlassAnnotation: "yes"}
+		_, err := validator.ValidateCreate(ctx, obj)
+		require.ErrorContains(t, err, "invalid "+v1alpha1.BreakGlassAnnotation+" annotation")
+	})
+
+	t.Run("unchanged expired annotation", func(t *testing.T) {
+		bg := valid
+		bg.ExpiresAt = metav1.NewTime(time.Now().Add(-time.Minute))
+		bg.RequestedBy = "someone-else"
+		obj := withBreakGlass(bg)
+		_, err := validator.ValidateUpdate(context.Background(), obj, obj)
+		require.NoError(t, err)
+	})
+}
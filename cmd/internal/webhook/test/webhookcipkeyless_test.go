@@ -0,0 +1,108 @@
+package webhook_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/webhook"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+func TestClusterImagePolicyValidatorKeyless(t *testing.T) {
+	const issuer = "https://token.actions.githubusercontent.com"
+	allowAny := map[string]interface{}{constants.AllowAnyIdentityAnnotation: "true"}
+
+	tests := []struct {
+		name        string
+		annotations map[string]interface{}
+		identities  []interface{}
+		errors      []string
+		warnings    []string
+	}{
+		{
+			name: "exact identity",
+			identities: []interface{}{
+				map[string]interface{}{"issuer": issuer, "subject": "https://github.com/securesign/policy-controller-operator/.github/workflows/release.yaml@refs/heads/main"},
+			},
+		},
+		{
+			name: "anchored subject expression",
+			identities: []interface{}{
+				map[string]interface{}{"issuer": issuer, "subjectRegExp": `^https://github\.com/securesign/.*$`},
+			},
+		},
+		{
+			name:   "no identities",
+			errors: []string{"spec.authorities[0].keyless.identities: Required value: an authority without identities accepts signatures of any identity; set the policy.rhtas.com/allow-any-identity annotation"},
+		},
+		{
+			name:        "no identities with opt-out",
+			annotations: allowAny,
+			warnings:    []string{"spec.authorities[0].keyless.identities: no identities, signatures of any identity are accepted"},
+		},
+		{
+			name: "invalid expressions",
+			identities: []interface{}{
+				map[string]interface{}{"issuerRegExp": "https://(accounts|oauth2.google.com", "subjectRegExp": "^[a-z+@example.com$"},
+			},
+			errors: []string{
+				`spec.authorities[0].keyless.identities[0].issuerRegExp: Invalid value: "https://(accounts|oauth2.google.com": error parsing regexp: missing closing )`,
+				`spec.authorities[0].keyless.identities[0].subjectRegExp: Invalid value: "^[a-z+@example.com$": error parsing regexp: missing closing ]`,
+			},
+		},
+		{
+			name: "incomplete and ambiguous identities",
+			identities: []interface{}{
+				map[string]interface{}{"subject": "jdoe@example.com"},
+				map[string]interface{}{"issuer": issuer, "issuerRegExp": ".*", "subject": "jdoe@example.com"},
+			},
+			errors: []string{
+				"spec.authorities[0].keyless.identities[0].issuer: Required value: one of issuer and issuerRegExp is required",
+				"spec.authorities[0].keyless.identities[1].issuerRegExp: Forbidden: may not be set together with issuer",
+			},
+		},
+		{
+			name: "invalid issuer URLs",
+			identities: []interface{}{
+				map[string]interface{}{"issuer": "accounts.google.com", "subject": "jdoe@example.com"},
+				map[string]interface{}{"issuer": "https://accounts.google.com?tenant=1", "subject": "jdoe@example.com"},
+			},
+			errors: []string{
+				`spec.authorities[0].keyless.identities[0].issuer: Invalid value: "accounts.google.com": must be an https URL`,
+				`spec.authorities[0].keyless.identities[1].issuer: Invalid value: "https://accounts.google.com?tenant=1": may not have a query or fragment`,
+			},
+		},
+		{
+			name: "permissive expressions",
+			identities: []interface{}{
+				map[string]interface{}{"issuer": "http://dex.example.com", "subjectRegExp": ".*"},
+				map[string]interface{}{"issuerRegExp": "^https://token.actions.githubusercontent.com$", "subjectRegExp": "github.com/securesign/"},
+			},
+			warnings: []string{
+				`spec.authorities[0].keyless.identities[0].subjectRegExp: ".*" matches any value`,
+				`spec.authorities[0].keyless.identities[0].issuer: "http://dex.example.com" is not an https URL`,
+				`spec.authorities[0].keyless.identities[1].subjectRegExp: "github.com/securesign/" is not anchored with ^ and matches any value that contains a match`,
+			},
+		},
+	}
+
+	validator := &webhook.ClusterImagePolicyValidator{}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := validator.Handle(context.Background(), clusterImagePolicyRequest(t, admissionv1.Create, tc.annotations, nil, map[string]interface{}{
+				"images": []interface{}{map[string]interface{}{"glob": "registry.example.com/**"}},
+				"authorities": []interface{}{map[string]interface{}{"keyless": map[string]interface{}{
+					"trustRootRef": "rhtas",
+					"identities":   tc.identities,
+				}}},
+			}))
+			require.Equal(t, len(tc.errors) == 0, resp.Allowed, resp.Result.Message)
+			for _, expected := range tc.errors {
+				require.Contains(t, resp.Result.Message, expected)
+			}
+			require.Equal(t, tc.warnings, resp.Warnings)
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package webhook_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/testutil"
+	"github.com/securesign/policy-controller-operator/cmd/internal/webhook"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestClusterImagePolicyValidatorKeys(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ns := constants.PolicyControllerInstallNs
+	cache := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "cosign-key"},
+			Data:       map[string][]byte{"cosign.pub": []byte(testutil.EncodePublicKey(t, &ecKey.PublicKey))},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "cosign-keypair"},
+			Data: map[string][]byte{
+				"cosign.pub": []byte(testutil.EncodePublicKey(t, &ecKey.PublicKey)),
+				"cosign.key": []byte("encrypted"),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "not-a-key"},
+			Data:       map[string][]byte{"cosign.pub": []byte("cosign.pub")},
+		},
+	).Build()
+
+	tests := []struct {
+		name     string
+		key      map[string]interface{}
+		errors   []string
+		warnings []string
+	}{
+		{
+			name: "inline key",
+			key:  map[string]interface{}{"data": testutil.EncodePublicKey(t, &ecKey.PublicKey)},
+		},
+		{
+			name: "inline key with matching hash algorithm",
+			key:  map[string]interface{}{"data": testutil.EncodePublicKey(t, &p384Key.PublicKey), "hashAlgorithm": "sha384"},
+		},
+		{
+			name: "secret key",
+			key:  map[string]interface{}{"secretRef": map[string]interface{}{"name": "cosign-key"}},
+		},
+		{
+			name: "kms key",
+			key:  map[string]interface{}{"kms": "awskms:///arn:aws:kms:us-east-1:111122223333:key/1234abcd", "hashAlgorithm": "sha512"},
+		},
+		{
+			name:   "invalid inline key",
+			key:    map[string]interface{}{"data": "cosign.pub"},
+			errors: []string{"spec.authorities[0].key.data: Invalid value: does not contain a PEM encoded public key"},
+		},
+		{
+			name: "inline key with the default hash algorithm",
+			key:  map[string]interface{}{"data": testutil.EncodePublicKey(t, &p384Key.PublicKey), "hashAlgorithm": "sha256"},
+		},
+		{
+			name:   "mismatched hash algorithm",
+			key:    map[string]interface{}{"data": testutil.EncodePublicKey(t, &ecKey.PublicKey), "hashAlgorithm": "sha512"},
+			errors: []string{`spec.authorities[0].key.hashAlgorithm: Invalid value: "sha512": does not match the ECDSA P-256 key, use sha256`},
+		},
+		{
+			name:   "mismatched hash algorithm for a P-384 key",
+			key:    map[string]interface{}{"data": testutil.EncodePublicKey(t, &p384Key.PublicKey), "hashAlgorithm": "sha512"},
+			errors: []string{`does not match the ECDSA P-384 key, use sha256 or sha384`},
+		},
+		{
+			name: "hash algorithm for an Ed25519 key",
+			key:  map[string]interface{}{"data": testutil.EncodePublicKey(t, edKey), "hashAlgorithm": "sha256"},
+		},
+		{
+			name:   "unsupported hash algorithm",
+			key:    map[string]interface{}{"kms": "gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k", "hashAlgorithm": "md5"},
+			errors: []string{`spec.authorities[0].key.hashAlgorithm: Unsupported value: "md5"`},
+		},
+		{
+			name:   "missing secret",
+			key:    map[string]interface{}{"secretRef": map[string]interface{}{"name": "cosign-pub"}},
+			errors: []string{`spec.authorities[0].key.secretRef.name: Not found: "cosign-pub"`},
+		},
+		{
+			name:   "secret with several entries",
+			key:    map[string]interface{}{"secretRef": map[string]interface{}{"name": "cosign-keypair"}},
+			errors: []string{"Secret must contain exactly one entry with the public key, found 2: cosign.key, cosign.pub"},
+		},
+		{
+			name:   "secret without a key",
+			key:    map[string]interface{}{"secretRef": map[string]interface{}{"name": "not-a-key"}},
+			errors: []string{"spec.authorities[0].key.secretRef: Invalid value: does not contain a PEM encoded public key"},
+		},
+		{
+			name:     "secret in another namespace",
+			key:      map[string]interface{}{"secretRef": map[string]interface{}{"name": "cosign-key", "namespace": "cosign-system"}},
+			warnings: []string{`spec.authorities[0].key.secretRef.namespace: namespace "cosign-system" is ignored, the Secret is read from the "policy-controller-operator" namespace`},
+		},
+		{
+			name:   "unknown kms",
+			key:    map[string]interface{}{"kms": "vault://transit/keys/cosign"},
+			errors: []string{`spec.authorities[0].key.kms: Unsupported value: "vault://"`},
+		},
+	}
+
+	validator := &webhook.ClusterImagePolicyValidator{Cache: cache}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := validator.Handle(context.Background(), clusterImagePolicyRequest(t, admissionv1.Create, nil, nil, map[string]interface{}{
+				"images":      []interface{}{map[string]interface{}{"glob": "registry.example.com/**"}},
+				"authorities": []interface{}{map[string]interface{}{"key": tc.key}},
+			}))
+			require.Equal(t, len(tc.errors) == 0, resp.Allowed, resp.Result.Message)
+			for _, expected := range tc.errors {
+				require.Contains(t, resp.Result.Message, expected)
+			}
+			require.Equal(t, tc.warnings, resp.Warnings)
+		})
+	}
+}
+
+func TestClusterImagePolicyValidatorUnchangedSpec(t *testing.T) {
+	spec := map[string]interface{}{
+		"images":      []interface{}{map[string]interface{}{"glob": "registry.example.com/**"}},
+		"authorities": []interface{}{map[string]interface{}{"key": map[string]interface{}{"secretRef": map[string]interface{}{"name": "deleted"}}}},
+	}
+	validator := &webhook.ClusterImagePolicyValidator{Cache: fake.NewClientBuilder().Build()}
+
+	resp := validator.Handle(context.Background(), clusterImagePolicyRequest(t, admissionv1.Update, nil, spec, spec))
+	require.True(t, resp.Allowed)
+
+	// A break-glass switches the mode of every ClusterImagePolicy.
+	warned := map[string]interface{}{"mode": "warn"}
+	for key, value := range spec {
+		warned[key] = value
+	}
+	resp = validator.Handle(context.Background(), clusterImagePolicyRequest(t, admissionv1.Update, nil, spec, warned))
+	require.True(t, resp.Allowed)
+
+	resp = validator.Handle(context.Background(), clusterImagePolicyRequest(t, admissionv1.Create, nil, nil, spec))
+	require.False(t, resp.Allowed)
+}
+
+func TestClusterImagePolicyValidatorGlobs(t *testing.T) {
+	validator := &webhook.ClusterImagePolicyValidator{}
+	resp := validator.Handle(context.Background(), clusterImagePolicyRequest(t, admissionv1.Create, nil, nil, map[string]interface{}{
+		"images": []interface{}{
+			map[string]interface{}{"glob": "registry.example.com/***"},
+			map[string]interface{}{"glob": "registry.example.com/[a-z]+"},
+			map[string]interface{}{"glob": "docker.io/nginx"},
+			map[string]interface{}{"glob": ""},
+		},
+		"authorities": []interface{}{map[string]interface{}{"static": map[string]interface{}{"action": "pass"}}},
+	}))
+	require.False(t, resp.Allowed)
+	require.Contains(t, resp.Result.Message, `spec.images[1].glob: Invalid value: "registry.example.com/[a-z]+": invalid glob`)
+	require.Contains(t, resp.Result.Message, "spec.images[3].glob: Required value")
+	require.Equal(t, []string{
+		`spec.images[2].glob: "docker.io/nginx" only matches the image written exactly as "docker.io/nginx", as it is normalized to "index.docker.io/library/nginx:latest" before it is matched`,
+	}, resp.Warnings)
+}
+
+func clusterImagePolicyRequest(t *testing.T, operation admissionv1.Operation, annotations map[string]interface{}, oldSpec, spec map[string]interface{}) admission.Request {
+	encode := func(spec map[string]interface{}) runtime.RawExtension {
+		if spec == nil {
+			return runtime.RawExtension{}
+		}
+		raw, err := json.Marshal(map[string]interface{}{
+			"apiVersion": constants.SigstorePolicyGroup + "/" + constants.SigstorePolicyVersion,
+			"kind":       constants.ClusterImagePolicyKind,
+			"metadata":   map[string]interface{}{"name": "signed-images", "annotations": annotations},
+			"spec":       spec,
+		})
+		require.NoError(t, err)
+		return runtime.RawExtension{Raw: raw}
+	}
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: operation,
+		Object:    encode(spec),
+		OldObject: encode(oldSpec),
+	}}
+}
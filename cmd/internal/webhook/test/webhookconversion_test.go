@@ -0,0 +1,165 @@
+package webhook_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/api/v1beta1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/webhook"
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func convert(t *testing.T, obj *unstructured.Unstructured, desiredAPIVersion string) (*unstructured.Unstructured, metav1.Status) {
+	t.Helper()
+	raw, err := obj.MarshalJSON()
+	require.NoError(t, err)
+
+	review := &apiextensionsv1.ConversionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "ConversionReview"},
+		Request: &apiextensionsv1.ConversionRequest{
+			UID:               "uid",
+			DesiredAPIVersion: desiredAPIVersion,
+			Objects:           []runtime.RawExtension{{Raw: raw}},
+		},
+	}
+	body, err := json.Marshal(review)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	(&webhook.PolicyControllerConverter{}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/convert", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	out := &apiextensionsv1.ConversionReview{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), out))
+	require.NotNil(t, out.Response)
+	require.Equal(t, review.Request.UID, out.Response.UID)
+	if out.Response.Result.Status != metav1.StatusSuccess {
+		return nil, out.Response.Result
+	}
+
+	require.Len(t, out.Response.ConvertedObjects, 1)
+	converted := &unstructured.Unstructured{}
+	require.NoError(t, converted.UnmarshalJSON(out.Response.ConvertedObjects[0].Raw))
+	return converted, out.Response.Result
+}
+
+func TestPolicyControllerConversionRoundTrip(t *testing.T) {
+	original := GenerateV1alpha1PolicyController(map[string]interface{}{
+		"loglevel": "debug",
+		"webhook": map[string]interface{}{
+			"replicaCount":  int64(2),
+			"failurePolicy": "Fail",
+			"extraArgs": map[string]interface{}{
+				"webhook-name": "policy.rhtas.com",
+				"disable-tuf":  true,
+			},
+			"namespaceSelector": map[string]interface{}{
+				"matchExpressions": []interface{}{
+					map[string]interface{}{
+						"key":      "policy.rhtas.com/include",
+						"operator": "In",
+						"values":   []interface{}{"true"},
+					},
+				},
+			},
+			"podDisruptionBudget": map[string]interface{}{
+				"enabled":      false,
+				"minAvailable": int64(1),
+			},
+		},
+	})
+
+	require.NoError(t, unstructured.SetNestedField(original.Object, string(v1alpha1.WebhookDriftReport), "spec", "webhookDrift", "mode"))
+	require.NoError(t, unstructured.SetNestedField(original.Object, "2m0s", "spec", "circuitBreaker", "gracePeriod"))
+	require.NoError(t, unstructured.SetNestedField(original.Object, map[string]interface{}{"warn": true, "replicaCount": int64(3)}, "spec", "upgradeMaintenance"))
+	require.NoError(t, unstructured.SetNestedField(original.Object, string(v1alpha1.ProfileProduction), "spec", "profile"))
+
+	beta, status := convert(t, original, v1beta1.GroupVersion.String())
+	require.Equal(t, metav1.StatusSuccess, status.Status, status.Message)
+	require.Equal(t, v1beta1.GroupVersion.String(), beta.GetAPIVersion())
+	require.Contains(t, beta.GetAnnotations(), v1beta1.ValuesAnnotation)
+
+	spec := v1beta1.PolicyControllerSpec{}
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(beta.Object["spec"].(map[string]interface{}), &spec))
+	require.Equal(t, v1beta1.TrustModeTrustRoots, spec.Trust.Mode)
+	require.Equal(t, "Fail", string(*spec.Enforcement.FailurePolicy))
+	require.Equal(t, "policy.rhtas.com/include", spec.Enforcement.NamespaceSelector.MatchExpressions[0].Key)
+	require.Equal(t, int32(2), *spec.Webhook.Replicas)
+	require.False(t, spec.Webhook.PodDisruptionBudget.Enabled)
+	require.Equal(t, v1alpha1.WebhookDriftReport, spec.Enforcement.WebhookDrift)
+	require.Equal(t, 2*time.Minute, spec.Enforcement.CircuitBreaker.GracePeriod.Duration)
+	require.True(t, spec.Enforcement.UpgradeMaintenance.Warn)
+	require.Equal(t, int32(3), *spec.Enforcement.UpgradeMaintenance.ReplicaCount)
+	require.Equal(t, v1alpha1.ProfileProduction, spec.Profile)
+
+	alpha, status := convert(t, beta, constants.PolicyControllerAPIVersion)
+	require.Equal(t, metav1.StatusSuccess, status.Status, status.Message)
+	require.Equal(t, original.Object, alpha.Object)
+}
+
+func TestPolicyControllerConversionAppliesV1beta1Changes(t *testing.T) {
+	original := GenerateV1alpha1PolicyController(map[string]interface{}{
+		"loglevel": "debug",
+		"webhook": map[string]interface{}{
+			"replicaCount": int64(1),
+			"extraArgs": map[string]interface{}{
+				"disable-tuf": "true",
+			},
+		},
+	})
+
+	beta, status := convert(t, original, v1beta1.GroupVersion.String())
+	require.Equal(t, metav1.StatusSuccess, status.Status, status.Message)
+	require.NoError(t, unstructured.SetNestedField(beta.Object, int64(3), "spec", "webhook", "replicas"))
+	require.NoError(t, unstructured.SetNestedField(beta.Object, string(v1beta1.TrustModePublicGoodTUF), "spec", "trust", "mode"))
+	require.NoError(t, unstructured.SetNestedField(beta.Object, "Ignore", "spec", "enforcement", "failurePolicy"))
+
+	alpha, status := convert(t, beta, constants.PolicyControllerAPIVersion)
+	require.Equal(t, metav1.StatusSuccess, status.Status, status.Message)
+	require.NotContains(t, alpha.GetAnnotations(), v1beta1.ValuesAnnotation)
+
+	values, _, err := unstructured.NestedMap(alpha.Object, "spec", "policy-controller")
+	require.NoError(t, err)
+	require.Equal(t, "debug", values["loglevel"])
+	replicas, _, _ := unstructured.NestedInt64(values, "webhook", "replicaCount")
+	require.Equal(t, int64(3), replicas)
+	disableTUF, _, _ := unstructured.NestedBool(values, "webhook", "extraArgs", "disable-tuf")
+	require.False(t, disableTUF)
+	failurePolicy, _, _ := unstructured.NestedString(values, "webhook", "failurePolicy")
+	require.Equal(t, "Ignore", failurePolicy)
+}
+
+func TestPolicyControllerConversionFromNewV1beta1Object(t *testing.T) {
+	beta := GenerateV1alpha1PolicyController(nil)
+	beta.SetAPIVersion(v1beta1.GroupVersion.String())
+	beta.Object["spec"] = map[string]interface{}{
+		"webhook": map[string]interface{}{
+			"podDisruptionBudget": map[string]interface{}{
+				"enabled":        true,
+				"maxUnavailable": "50%",
+			},
+		},
+	}
+
+	alpha, status := convert(t, beta, constants.PolicyControllerAPIVersion)
+	require.Equal(t, metav1.StatusSuccess, status.Status, status.Message)
+	pdb, _, err := unstructured.NestedMap(alpha.Object, "spec", "policy-controller", "webhook", "podDisruptionBudget")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"enabled": true, "maxUnavailable": "50%"}, pdb)
+}
+
+func TestPolicyControllerConversionUnsupportedVersion(t *testing.T) {
+	_, status := convert(t, GenerateV1alpha1PolicyController(nil), "rhtas.charts.redhat.com/v2")
+	require.Equal(t, metav1.StatusFailure, status.Status)
+	require.Contains(t, status.Message, "unsupported conversion")
+}
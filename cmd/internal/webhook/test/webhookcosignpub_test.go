@@ -0,0 +1,114 @@
+package webhook_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"testing"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/testutil"
+	"github.com/securesign/policy-controller-operator/cmd/internal/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyControllerValidatorCosignPub(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	weakEcKey, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	require.NoError(t, err)
+	sum := sha256.Sum256(der)
+	privateDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		value   string
+		error   string
+		warning string
+	}{
+		{
+			name:    "ECDSA key",
+			value:   encodeCosignPub(t, &ecKey.PublicKey),
+			warning: "cosign.cosignPub: ECDSA P-256 public key with SHA-256 fingerprint " + hex.EncodeToString(sum[:]),
+		},
+		{
+			name:    "Ed25519 key",
+			value:   encodeCosignPub(t, edKey),
+			warning: "cosign.cosignPub: Ed25519 public key with SHA-256 fingerprint ",
+		},
+		{
+			name:  "PEM without base64",
+			value: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+			error: "invalid cosign.cosignPub: must be base64 encoded, not PEM",
+		},
+		{
+			name:  "not base64",
+			value: "cosign.pub",
+			error: "invalid cosign.cosignPub: must be base64 encoded",
+		},
+		{
+			name:  "not PEM",
+			value: base64.StdEncoding.EncodeToString([]byte("cosign.pub")),
+			error: "invalid cosign.cosignPub: does not contain a PEM encoded public key",
+		},
+		{
+			name:  "private key",
+			value: base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privateDER})),
+			error: "invalid cosign.cosignPub: must be a PUBLIC KEY, not a EC PRIVATE KEY",
+		},
+		{
+			name:  "weak RSA key",
+			value: encodeCosignPub(t, &rsaKey.PublicKey),
+			error: "invalid cosign.cosignPub: RSA key of 1024 bits is too weak, at least 2048 bits are required",
+		},
+		{
+			name:  "unsupported curve",
+			value: encodeCosignPub(t, &weakEcKey.PublicKey),
+			error: "invalid cosign.cosignPub: unsupported ECDSA curve P-224",
+		},
+	}
+
+	validator := webhook.PolicyControllerValidator{}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pc := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+			pc.Spec.PolicyController.Cosign = v1alpha1.CosignValues{CosignPub: tc.value}
+
+			warnings, err := validator.ValidateCreate(context.Background(), pc)
+			if tc.error != "" {
+				require.ErrorContains(t, err, tc.error)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, warnings, 1)
+			require.Contains(t, warnings[0], tc.warning)
+
+			// The fingerprint is only reported when the key changes.
+			warnings, err = validator.ValidateUpdate(context.Background(), pc, pc.DeepCopy())
+			require.NoError(t, err)
+			require.Empty(t, warnings)
+		})
+	}
+}
+
+func encodeCosignPub(t *testing.T, pub crypto.PublicKey) string {
+	return base64.StdEncoding.EncodeToString([]byte(testutil.EncodePublicKey(t, pub)))
+}
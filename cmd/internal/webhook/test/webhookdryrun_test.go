@@ -0,0 +1,239 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	"github.com/securesign/policy-controller-operator/cmd/internal/testutil"
+	rhtas_webhook "github.com/securesign/policy-controller-operator/cmd/internal/webhook"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestDryRunHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	for _, gvk := range []schema.GroupVersionKind{controller.ClusterImagePolicyGVK, controller.TrustRootGVK} {
+		scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+	}
+
+	cip := &unstructured.Unstructured{}
+	cip.SetGroupVersionKind(controller.ClusterImagePolicyGVK)
+	cip.SetName("quay-io")
+	_ = unstructured.SetNestedField(cip.Object, map[string]interface{}{
+		"images":      []interface{}{map[string]interface{}{"glob": "quay.io/**"}},
+		"authorities": []interface{}{map[string]interface{}{"static": map[string]interface{}{"action": policy.StaticPass}}},
+	}, "spec")
+
+	// The globs name quay.io and registry.example.com, but no registry for
+	// images in *.internal.
+	keyed := &unstructured.Unstructured{}
+	keyed.SetGroupVersionKind(controller.ClusterImagePolicyGVK)
+	keyed.SetName("keyed")
+	_ = unstructured.SetNestedField(keyed.Object, map[string]interface{}{
+		"images": []interface{}{
+			map[string]interface{}{"glob": "registry.example.com/**"},
+			map[string]interface{}{"glob": "*.internal/**"},
+		},
+		"authorities": []interface{}{map[string]interface{}{"key": map[string]interface{}{"data": testutil.GeneratePublicKeyPEM(t)}}},
+	}, "spec")
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			GeneratePolicyControllerObj(constants.PolicyControllerInstallNs),
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "enforced", Labels: map[string]string{"policy.rhtas.com/include": "true"}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team"}},
+			cip,
+			keyed,
+		).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				switch review := obj.(type) {
+				case *authenticationv1.TokenReview:
+					if user, ok := strings.CutSuffix(review.Spec.Token, "-token"); ok {
+						review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: user}}
+					}
+					return nil
+				case *authorizationv1.SubjectAccessReview:
+					attrs := review.Spec.ResourceAttributes
+					review.Status.Allowed = review.Spec.User == "deployer" && attrs.Verb == "create" && attrs.Resource == "pods"
+					return nil
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	registry := &recordingRegistry{}
+	handler := &rhtas_webhook.DryRunHandler{Client: c, APIReader: c, Registry: registry}
+
+	tests := []struct {
+		name    string
+		method  string
+		token   string
+		body    string
+		code    int
+		verdict policy.Verdict
+		message string
+	}{
+		{name: "get", method: http.MethodGet, token: "deployer-token", code: http.StatusMethodNotAllowed},
+		{name: "no token", body: `{"image":"quay.io/app","namespace":"enforced"}`, code: http.StatusUnauthorized},
+		{name: "invalid token", token: "invalid", body: `{"image":"quay.io/app","namespace":"enforced"}`, code: http.StatusUnauthorized},
+		{name: "invalid request", token: "deployer-token", body: `{"image":"quay.io/App","namespace":"Team"}`, code: http.StatusBadRequest, message: "namespace: a lowercase RFC 1123 label"},
+		{name: "unknown field", token: "deployer-token", body: `{"image":"quay.io/app","ns":"team"}`, code: http.StatusBadRequest, message: `unknown field "ns"`},
+		{name: "forbidden", token: "viewer-token", body: `{"image":"quay.io/app","namespace":"enforced"}`, code: http.StatusForbidden, message: `viewer cannot create pods in namespace "enforced"`},
+		{name: "missing namespace", token: "deployer-token", body: `{"image":"quay.io/app","namespace":"missing"}`, code: http.StatusNotFound},
+		{name: "not enforced", token: "deployer-token", body: `{"image":"docker.io/app","namespace":"team"}`, code: http.StatusOK, verdict: policy.Allowed, message: "image policies are not enforced in namespace team"},
+		{name: "allowed", token: "deployer-token", body: `{"image":"quay.io/app:v1","namespace":"enforced"}`, code: http.StatusOK, verdict: policy.Allowed},
+		{name: "rejected", token: "deployer-token", body: `{"image":"docker.io/app","namespace":"enforced"}`, code: http.StatusOK, verdict: policy.Rejected, message: "no matching policies"},
+		{name: "named registry", token: "deployer-token", body: `{"image":"registry.example.com/app:v1","namespace":"enforced"}`, code: http.StatusOK, verdict: policy.Unknown, message: "unable to resolve registry.example.com/app:v1"},
+		{name: "unnamed registry", token: "deployer-token", body: `{"image":"metadata.internal/app:v1","namespace":"enforced"}`, code: http.StatusOK, verdict: policy.Unknown, message: "none names metadata.internal"},
+		{name: "invalid pull secret", token: "deployer-token", body: `{"image":"quay.io/app","namespace":"enforced","imagePullSecrets":["Pull"]}`, code: http.StatusBadRequest, message: "imagePullSecrets[0]: a lowercase RFC 1123 subdomain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, rhtas_webhook.DryRunPath, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.code, rec.Code, rec.Body.String())
+			if tt.code != http.StatusOK {
+				require.Contains(t, rec.Body.String(), tt.message)
+				return
+			}
+			resp := rhtas_webhook.DryRunResponse{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			require.Equal(t, tt.verdict, resp.Verdict)
+			require.Equal(t, tt.verdict == policy.Allowed, resp.Allowed)
+			if tt.message != "" {
+				require.Contains(t, strings.Join(resp.Reasons, "\n"), tt.message)
+			}
+		})
+	}
+	require.Equal(t, []string{"registry.example.com/app:v1"}, registry.requested)
+}
+
+// recordingRegistry records the references it is asked for and has none of
+// them.
+type recordingRegistry struct {
+	requested []string
+}
+
+func (r *recordingRegistry) Digest(_ context.Context, ref name.Reference) (v1.Hash, error) {
+	r.requested = append(r.requested, ref.String())
+	return v1.Hash{}, policy.ErrNotFound
+}
+
+func (r *recordingRegistry) Image(_ context.Context, ref name.Reference) (v1.Image, error) {
+	r.requested = append(r.requested, ref.String())
+	return nil, policy.ErrNotFound
+}
+
+func (r *recordingRegistry) Referrers(_ context.Context, digest name.Digest) ([]v1.Descriptor, error) {
+	r.requested = append(r.requested, digest.String())
+	return nil, policy.ErrNotFound
+}
+
+func TestDryRunHandlerPullSecrets(t *testing.T) {
+	// The registry only serves requests with the credentials of the pull
+	// secret of the default ServiceAccount of the namespace.
+	authorized := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "deployer" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		authorized = true
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	for _, gvk := range []schema.GroupVersionKind{controller.ClusterImagePolicyGVK, controller.TrustRootGVK} {
+		scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+	}
+	cip := &unstructured.Unstructured{}
+	cip.SetGroupVersionKind(controller.ClusterImagePolicyGVK)
+	cip.SetName("local")
+	_ = unstructured.SetNestedField(cip.Object, map[string]interface{}{
+		"images":      []interface{}{map[string]interface{}{"glob": host + "/**"}},
+		"authorities": []interface{}{map[string]interface{}{"key": map[string]interface{}{"data": testutil.GeneratePublicKeyPEM(t)}}},
+	}, "spec")
+	dockerConfig := fmt.Sprintf(`{"auths":{%q:{"username":"deployer","password":"secret"}}}`, host)
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			GeneratePolicyControllerObj(constants.PolicyControllerInstallNs),
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "enforced", Labels: map[string]string{"policy.rhtas.com/include": "true"}}},
+			&corev1.ServiceAccount{
+				ObjectMeta:       metav1.ObjectMeta{Name: "default", Namespace: "enforced"},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "pull"}},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "pull", Namespace: "enforced"},
+				Type:       corev1.SecretTypeDockerConfigJson,
+				Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(dockerConfig)},
+			},
+			cip,
+		).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				switch review := obj.(type) {
+				case *authenticationv1.TokenReview:
+					review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: "deployer"}}
+					return nil
+				case *authorizationv1.SubjectAccessReview:
+					review.Status.Allowed = true
+					return nil
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	handler := &rhtas_webhook.DryRunHandler{Client: c, APIReader: c}
+
+	body := fmt.Sprintf(`{"image":"%s/app:v1","namespace":"enforced"}`, host)
+	req := httptest.NewRequest(http.MethodPost, rhtas_webhook.DryRunPath, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer deployer-token")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.True(t, authorized, rec.Body.String())
+}
@@ -0,0 +1,146 @@
+package webhook_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/webhook"
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestPolicyControllerValidatorExtraArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   map[string]string
+		errors []string
+	}{
+		{
+			name: "chart defaults",
+			args: map[string]string{
+				"webhook-name":            `"policy.rhtas.com"`,
+				"mutating-webhook-name":   `"defaulting.clusterimagepolicy.rhtas.com"`,
+				"validating-webhook-name": `"validating.clusterimagepolicy.rhtas.com"`,
+				"disable-tuf":             `true`,
+			},
+		},
+		{
+			name: "typed values as strings",
+			args: map[string]string{
+				"disable-tuf":             `"false"`,
+				"policy-resync-period":    `"1h30m"`,
+				"trustroot-resync-period": `"48h"`,
+				"secure-port":             `"9443"`,
+			},
+		},
+		{
+			name:   "misspelled flag",
+			args:   map[string]string{"disable-tfu": `true`},
+			errors: []string{"webhook.extraArgs.disable-tfu: unknown flag"},
+		},
+		{
+			name: "flags of the knative libraries",
+			args: map[string]string{"kubeconfig": `"/kubeconfig"`, "disable-ha": `true`, "v": `4`},
+			errors: []string{
+				"webhook.extraArgs.disable-ha: unknown flag",
+				"webhook.extraArgs.kubeconfig: unknown flag",
+				"webhook.extraArgs.v: unknown flag",
+			},
+		},
+		{
+			name: "wrong types",
+			args: map[string]string{
+				"disable-tuf":          `"yes"`,
+				"policy-resync-period": `10`,
+				"secure-port":          `1.5`,
+				"tuf-mirror":           `{"url": "https://tuf.example.com"}`,
+			},
+			errors: []string{
+				`webhook.extraArgs.disable-tuf: must be of type bool, not "yes"`,
+				`webhook.extraArgs.policy-resync-period: must be of type duration, not "10"`,
+				`webhook.extraArgs.secure-port: must be of type int, not "1.5"`,
+				`webhook.extraArgs.tuf-mirror: must be of type string`,
+			},
+		},
+	}
+
+	validator := webhook.PolicyControllerValidator{}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pc := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+			pc.Spec.PolicyController.Webhook.ExtraArgs = map[string]apiextensionsv1.JSON{}
+			for key, raw := range tc.args {
+				pc.Spec.PolicyController.Webhook.ExtraArgs[key] = apiextensionsv1.JSON{Raw: []byte(raw)}
+			}
+
+			_, err := validator.ValidateCreate(context.Background(), pc)
+			if len(tc.errors) == 0 {
+				require.NoError(t, err)
+			}
+			for _, expected := range tc.errors {
+				require.ErrorContains(t, err, expected)
+			}
+			_, err = validator.ValidateUpdate(context.Background(), &v1alpha1.PolicyController{}, pc)
+			require.Equal(t, len(tc.errors) == 0, err == nil)
+		})
+	}
+}
+
+// TestWebhookFlagsMatchChart pins the flag table to the policy-controller
+// version of the vendored chart and checks it against the --help output of
+// that version, kept in testdata.
+func TestWebhookFlagsMatchChart(t *testing.T) {
+	charts, err := filepath.Glob(filepath.Join("..", "..", "..", "..", "helm-charts", "policy-controller-operator", "charts", "policy-controller-*.tgz"))
+	require.NoError(t, err)
+	require.Len(t, charts, 1)
+	archive, err := os.Open(charts[0])
+	require.NoError(t, err)
+	defer archive.Close()
+	gz, err := gzip.NewReader(archive)
+	require.NoError(t, err)
+	chart := struct {
+		AppVersion string `json:"appVersion"`
+	}{}
+	for tr := tar.NewReader(gz); ; {
+		header, err := tr.Next()
+		require.NoError(t, err, "policy-controller/Chart.yaml not found")
+		if header.Name == "policy-controller/Chart.yaml" {
+			data, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			require.NoError(t, yaml.Unmarshal(data, &chart))
+			break
+		}
+	}
+	require.Equal(t, chart.AppVersion, webhook.WebhookFlagsVersion,
+		"update webhookFlags and testdata from the --help output of policy-controller %s", chart.AppVersion)
+
+	help, err := os.ReadFile(filepath.Join("testdata", "policy-controller-"+webhook.WebhookFlagsVersion+"-help.txt"))
+	require.NoError(t, err)
+	values := map[string]string{"string": `"value"`, "int": `8443`, "duration": `"1h"`, "": `true`}
+	args := map[string]apiextensionsv1.JSON{}
+	for _, line := range strings.Split(string(help), "\n") {
+		if !strings.HasPrefix(line, "  -") {
+			continue
+		}
+		fields := append(strings.Fields(line), "")
+		raw, ok := values[fields[1]]
+		require.True(t, ok, "flag %s has an unknown type", fields[0])
+		args[strings.TrimPrefix(fields[0], "-")] = apiextensionsv1.JSON{Raw: []byte(raw)}
+	}
+	require.NotEmpty(t, args)
+
+	pc := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+	pc.Spec.PolicyController.Webhook.ExtraArgs = args
+	validator := webhook.PolicyControllerValidator{}
+	_, err = validator.ValidateCreate(context.Background(), pc)
+	require.NoError(t, err)
+}
@@ -0,0 +1,139 @@
+package webhook_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	rhtas_webhook "github.com/securesign/policy-controller-operator/cmd/internal/webhook"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestImagePolicyExceptionValidatorCreate(t *testing.T) {
+	validator := &rhtas_webhook.ImagePolicyExceptionValidator{}
+	exception := func(requester, approver string, expiresIn time.Duration) *v1alpha1.ImagePolicyException {
+		return &v1alpha1.ImagePolicyException{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "incident"},
+			Spec: v1alpha1.ImagePolicyExceptionSpec{
+				Reason:    "INC-1234",
+				Requester: requester,
+				Approver:  approver,
+				ExpiresAt: metav1.NewTime(time.Now().Add(expiresIn)),
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		exception *v1alpha1.ImagePolicyException
+		err       string
+	}{
+		{name: "valid", exception: exception("jdoe", "", time.Hour)},
+		{name: "other requester", exception: exception("asmith", "", time.Hour), err: `requester must be the requesting user "jdoe"`},
+		{name: "approved on creation", exception: exception("jdoe", "asmith", time.Hour), err: "approver must not be set on creation"},
+		{name: "expired", exception: exception("jdoe", "", -time.Minute), err: "expiresAt must be in the future"},
+		{name: "too long", exception: exception("jdoe", "", v1alpha1.MaxImagePolicyExceptionDuration+time.Hour), err: "expiresAt must be within 72h0m0s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: authenticationv1.UserInfo{Username: "jdoe"},
+			}})
+			warnings, err := validator.ValidateCreate(ctx, tt.exception)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Contains(t, warnings[0], "has no effect until another user approves it")
+		})
+	}
+}
+
+func TestImagePolicyExceptionValidatorApprove(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	var reviewed *authorizationv1.ResourceAttributes
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+					reviewed = review.Spec.ResourceAttributes
+					review.Status.Allowed = review.Spec.User != "team-admin"
+					return nil
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	validator := &rhtas_webhook.ImagePolicyExceptionValidator{Client: c}
+	exception := func(approver string, expiresIn time.Duration) *v1alpha1.ImagePolicyException {
+		return &v1alpha1.ImagePolicyException{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "incident"},
+			Spec: v1alpha1.ImagePolicyExceptionSpec{
+				Reason:    "INC-1234",
+				Requester: "jdoe",
+				Approver:  approver,
+				ExpiresAt: metav1.NewTime(time.Now().Add(expiresIn)),
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		user      string
+		old, new  *v1alpha1.ImagePolicyException
+		err       string
+		forbidden bool
+	}{
+		{name: "unchanged", user: "jdoe", old: exception("", time.Hour), new: exception("", time.Hour)},
+		{name: "approve", user: "asmith", old: exception("", time.Hour), new: exception("asmith", time.Hour)},
+		{name: "approve for someone else", user: "asmith", old: exception("", time.Hour), new: exception("bwayne", time.Hour), err: `approver must be the approving user "asmith"`},
+		{name: "self-approval", user: "jdoe", old: exception("", time.Hour), new: exception("jdoe", time.Hour), err: "approver must differ from requester"},
+		{name: "change approver", user: "bwayne", old: exception("asmith", time.Hour), new: exception("bwayne", time.Hour), err: "approver cannot be changed once set"},
+		{name: "expired", user: "asmith", old: exception("", -time.Minute), new: exception("asmith", -time.Minute), err: "an expired exception cannot be approved"},
+		{name: "without approve verb", user: "team-admin", old: exception("", time.Hour), new: exception("team-admin", time.Hour), forbidden: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reviewed = nil
+			ctx := admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: authenticationv1.UserInfo{Username: tt.user},
+			}})
+			_, err := validator.ValidateUpdate(ctx, tt.old, tt.new)
+			switch {
+			case tt.forbidden:
+				require.True(t, apierrors.IsForbidden(err), err)
+				require.ErrorContains(t, err, "requires the approve verb on imagepolicyexceptions")
+			case tt.err != "":
+				require.ErrorContains(t, err, tt.err)
+				return
+			default:
+				require.NoError(t, err)
+			}
+			if tt.old.Spec.Approver != tt.new.Spec.Approver {
+				require.Equal(t, &authorizationv1.ResourceAttributes{
+					Namespace: "team",
+					Verb:      v1alpha1.ImagePolicyExceptionApproveVerb,
+					Group:     constants.PolicyControllerGroup,
+					Resource:  "imagepolicyexceptions",
+					Name:      "incident",
+				}, reviewed)
+			}
+		})
+	}
+}
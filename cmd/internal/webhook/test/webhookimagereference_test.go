@@ -0,0 +1,231 @@
+package webhook_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	"github.com/securesign/policy-controller-operator/cmd/internal/testutil"
+	"github.com/securesign/policy-controller-operator/cmd/internal/webhook"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const digest = "sha256:3637dc531225a899df7d67c538ae30cad4be8871ad428701208fef2f3a7160b1"
+
+func TestPolicyControllerValidatorImageReferences(t *testing.T) {
+	withImage := func(repository, version string) *v1alpha1.PolicyController {
+		pc := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+		pc.Spec.PolicyController.Webhook.Image = v1alpha1.ImageValues{Repository: repository, Version: version}
+		return pc
+	}
+	idms := &unstructured.Unstructured{}
+	idms.SetGroupVersionKind(webhook.ImageDigestMirrorSetGVK)
+	idms.SetName("rhtas-mirror")
+	require.NoError(t, unstructured.SetNestedSlice(idms.Object, []interface{}{
+		map[string]interface{}{"source": "registry.redhat.io/rhtas", "mirrors": []interface{}{"mirror.example.com/rhtas"}},
+	}, "spec", "imageDigestMirrors"))
+
+	tests := []struct {
+		name     string
+		data     map[string]string
+		obj      *v1alpha1.PolicyController
+		errors   []string
+		warnings int
+	}{
+		{
+			name: "chart default",
+			obj:  GeneratePolicyControllerObj(constants.PolicyControllerInstallNs),
+		},
+		{
+			name: "pinned image from the default registry",
+			obj:  withImage("registry.redhat.io/rhtas/policy-controller-rhel9", digest),
+		},
+		{
+			name: "pinned image from a mirror",
+			obj:  withImage("mirror.example.com/rhtas/policy-controller-rhel9", digest),
+		},
+		{
+			name:   "mutable tag",
+			obj:    withImage("", "latest"),
+			errors: []string{`webhook.image: "registry.redhat.io/rhtas/policy-controller-rhel9:latest" must be pinned by digest`},
+		},
+		{
+			name:   "registry not allowed",
+			obj:    withImage("quay.io/evil/policy-controller", "v1"),
+			errors: []string{"must be pinned by digest", `"quay.io/evil/policy-controller:v1" is not in an allowed registry`},
+		},
+		{
+			name:     "warn mode",
+			data:     map[string]string{webhook.ImageReferenceModeKey: "Warn"},
+			obj:      withImage("quay.io/evil/policy-controller", digest),
+			warnings: 1,
+		},
+		{
+			name: "configured registries and tags",
+			data: map[string]string{
+				webhook.ImageReferenceAllowedRegistriesKey: "quay.io/securesign\nregistry.example.com",
+				webhook.ImageReferenceRequireDigestKey:     "false",
+			},
+			obj: withImage("quay.io/securesign/policy-controller", "v1"),
+		},
+		{
+			name:   "invalid mode",
+			data:   map[string]string{webhook.ImageReferenceModeKey: "Audit"},
+			obj:    withImage("quay.io/evil/policy-controller", digest),
+			errors: []string{"invalid mode in ConfigMap image-reference-policy"},
+		},
+		{
+			name: "leases cleanup image",
+			obj: func() *v1alpha1.PolicyController {
+				pc := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+				pc.Spec.PolicyController.LeasesCleanup.Image = v1alpha1.ImageValues{Repository: "cgr.dev/chainguard/kubectl", Version: "latest-dev"}
+				return pc
+			}(),
+			errors: []string{"leasescleanup.image:"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := []client.Object{idms}
+			if tc.data != nil {
+				objects = append(objects, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: constants.PolicyControllerInstallNs, Name: constants.ImageReferencePolicyConfigMap},
+					Data:       tc.data,
+				})
+			}
+			validator := webhook.PolicyControllerValidator{Client: newImageReferenceClient(t, objects...)}
+
+			warnings, err := validator.ValidateCreate(context.Background(), tc.obj)
+			if len(tc.errors) == 0 {
+				require.NoError(t, err)
+			}
+			for _, expected := range tc.errors {
+				require.ErrorContains(t, err, expected)
+			}
+			require.Len(t, warnings, tc.warnings)
+		})
+	}
+}
+
+func TestPolicyControllerValidatorUnchangedImageReference(t *testing.T) {
+	validator := webhook.PolicyControllerValidator{Client: newImageReferenceClient(t)}
+	oldObj := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+	oldObj.Spec.PolicyController.Webhook.Image = v1alpha1.ImageValues{Repository: "quay.io/evil/policy-controller", Version: "v1"}
+	newObj := oldObj.DeepCopy()
+	newObj.Spec.PolicyController.Webhook.ReplicaCount = ptr.To(int32(2))
+
+	_, err := validator.ValidateUpdate(context.Background(), oldObj, newObj)
+	require.NoError(t, err)
+
+	newObj.Spec.PolicyController.Webhook.Image.Version = "v2"
+	_, err = validator.ValidateUpdate(context.Background(), oldObj, newObj)
+	require.ErrorContains(t, err, `"quay.io/evil/policy-controller:v2" must be pinned by digest`)
+}
+
+func newImageReferenceClient(t *testing.T, objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(webhook.ImageDigestMirrorSetGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(webhook.ImageDigestMirrorSetGVK.GroupVersion().WithKind(webhook.ImageDigestMirrorSetGVK.Kind+"List"), &unstructured.UnstructuredList{})
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+// releaseVerifier accepts the images signed by a key, without looking at
+// any signatures.
+type releaseVerifier struct {
+	signed map[string]string
+}
+
+func (v *releaseVerifier) VerifySignature(_ context.Context, image string, authority policy.Authority, _ *policy.TrustedMaterial) error {
+	if authority.Key == nil || v.signed[image] != authority.Key.Data {
+		return errors.New("no matching signatures")
+	}
+	return nil
+}
+
+func (v *releaseVerifier) VerifyAttestation(context.Context, string, policy.Authority, *policy.TrustedMaterial, string) error {
+	return errors.New("no matching attestations")
+}
+
+// slowVerifier does not respond before the context is done.
+type slowVerifier struct{}
+
+func (slowVerifier) VerifySignature(ctx context.Context, _ string, _ policy.Authority, _ *policy.TrustedMaterial) error {
+	<-ctx.Done()
+	return fmt.Errorf("%w: %w", policy.ErrNotEvaluated, ctx.Err())
+}
+
+func (slowVerifier) VerifyAttestation(ctx context.Context, _ string, _ policy.Authority, _ *policy.TrustedMaterial, _ string) error {
+	<-ctx.Done()
+	return fmt.Errorf("%w: %w", policy.ErrNotEvaluated, ctx.Err())
+}
+
+func TestPolicyControllerValidatorReleaseSignature(t *testing.T) {
+	releaseKey := testutil.GeneratePublicKeyPEM(t)
+	otherKey := testutil.GeneratePublicKeyPEM(t)
+	signed := "registry.redhat.io/rhtas/policy-controller-rhel9@" + digest
+	tampered := "registry.redhat.io/rhtas/policy-controller-rhel9@sha256:" + strings.Repeat("0", 64)
+	withVersion := func(version string) *v1alpha1.PolicyController {
+		pc := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+		pc.Spec.PolicyController.Webhook.Image.Version = version
+		return pc
+	}
+	policyConfigMap := func(data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: constants.PolicyControllerInstallNs, Name: constants.ImageReferencePolicyConfigMap},
+			Data:       data,
+		}
+	}
+	verifier := &releaseVerifier{signed: map[string]string{signed: releaseKey, tampered: otherKey}}
+
+	validator := webhook.PolicyControllerValidator{
+		Client:   newImageReferenceClient(t, policyConfigMap(map[string]string{webhook.ReleaseSignatureKeyKey: releaseKey})),
+		Verifier: verifier,
+	}
+	_, err := validator.ValidateCreate(context.Background(), withVersion(digest))
+	require.NoError(t, err)
+	_, err = validator.ValidateCreate(context.Background(), GeneratePolicyControllerObj(constants.PolicyControllerInstallNs))
+	require.NoError(t, err, "the chart default image is not verified")
+	_, err = validator.ValidateCreate(context.Background(), withVersion(strings.TrimPrefix(tampered, "registry.redhat.io/rhtas/policy-controller-rhel9@")))
+	require.ErrorContains(t, err, "is not signed by the release signer")
+
+	validator.Verifier = nil
+	_, err = validator.ValidateCreate(context.Background(), withVersion(digest))
+	require.ErrorContains(t, err, "could not be verified", "unverifiable images are denied")
+
+	validator.Verifier = slowVerifier{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = validator.ValidateCreate(ctx, withVersion(digest))
+	require.ErrorContains(t, err, "could not be verified within", "verification is bounded")
+
+	validator.Client = newImageReferenceClient(t, policyConfigMap(map[string]string{
+		webhook.ReleaseSignatureIssuerKey:    "https://token.actions.githubusercontent.com",
+		webhook.ReleaseSignatureSubjectKey:   "https://github.com/securesign/policy-controller/.github/workflows/release.yaml@refs/heads/main",
+		webhook.ReleaseSignatureTrustRootKey: "rhtas",
+	}))
+	_, err = validator.ValidateCreate(context.Background(), withVersion(digest))
+	require.ErrorContains(t, err, `TrustRoot "rhtas" of the release signer not found`)
+
+	validator.Client = newImageReferenceClient(t, policyConfigMap(map[string]string{
+		webhook.ReleaseSignatureKeyKey:    releaseKey,
+		webhook.ReleaseSignatureIssuerKey: "https://token.actions.githubusercontent.com",
+	}))
+	_, err = validator.ValidateCreate(context.Background(), withVersion(digest))
+	require.ErrorContains(t, err, "mutually exclusive")
+}
@@ -0,0 +1,151 @@
+package webhook_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	rhtas_webhook "github.com/securesign/policy-controller-operator/cmd/internal/webhook"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+)
+
+func TestNamespaceLabelValidator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	pc := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+	pc.Spec.PolicyController.Webhook.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team-policy": "strict"}}
+	var reviewed *authorizationv1.ResourceAttributes
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(pc).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+					reviewed = review.Spec.ResourceAttributes
+					review.Status.Allowed = review.Spec.User == "security-admin"
+					return nil
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	validator := &rhtas_webhook.NamespaceLabelValidator{Client: c}
+
+	namespace := func(labels, annotations map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team", Labels: labels, Annotations: annotations}}
+	}
+	enforced := map[string]string{"policy.rhtas.com/include": "true", "team-policy": "strict"}
+
+	tests := []struct {
+		name     string
+		user     string
+		old, new *corev1.Namespace
+		denied   string
+	}{
+		{name: "other labels", user: "team-admin", old: namespace(enforced, nil), new: namespace(map[string]string{"policy.rhtas.com/include": "true", "team-policy": "strict", "owner": "a"}, nil)},
+		{name: "remove include label", user: "team-admin", old: namespace(enforced, nil), new: namespace(map[string]string{"team-policy": "strict"}, nil), denied: "label policy.rhtas.com/include"},
+		{name: "change matchLabels label", user: "team-admin", old: namespace(enforced, nil), new: namespace(map[string]string{"policy.rhtas.com/include": "true", "team-policy": "relaxed"}, nil), denied: "label team-policy"},
+		{name: "add include label", user: "team-admin", old: namespace(nil, nil), new: namespace(map[string]string{"policy.rhtas.com/include": "false"}, nil), denied: "label policy.rhtas.com/include"},
+		{name: "edit exempted labels", user: "team-admin", old: namespace(nil, map[string]string{constants.ExemptedLabelsAnnotation: `{"policy.rhtas.com/include":"true"}`}), new: namespace(nil, nil), denied: "annotation " + constants.ExemptedLabelsAnnotation},
+		{name: "allowed user", user: "security-admin", old: namespace(enforced, nil), new: namespace(nil, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reviewed = nil
+			ctx := admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: authenticationv1.UserInfo{Username: tt.user},
+			}})
+			_, err := validator.ValidateUpdate(ctx, tt.old, tt.new)
+			if tt.denied == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.True(t, apierrors.IsForbidden(err), err)
+			require.ErrorContains(t, err, "changing "+tt.denied)
+			require.Equal(t, &authorizationv1.ResourceAttributes{
+				Verb:        "update",
+				Group:       constants.PolicyControllerGroup,
+				Resource:    "namespaces",
+				Subresource: rhtas_webhook.NamespaceEnforcementSubresource,
+				Name:        "team",
+			}, reviewed)
+		})
+	}
+}
+
+// TestNamespaceWebhookMatchConditions evaluates the matchConditions of the
+// namespace webhook, which decide which updates reach the validator.
+func TestNamespaceWebhookMatchConditions(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "..", "..", "config", "webhook", "webhook.yaml"))
+	require.NoError(t, err)
+	config := admissionregistrationv1.ValidatingWebhookConfiguration{}
+	require.NoError(t, yaml.Unmarshal(bytes.Split(data, []byte("\n---\n"))[0], &config))
+	var conditions []admissionregistrationv1.MatchCondition
+	for _, webhook := range config.Webhooks {
+		if webhook.ClientConfig.Service != nil && webhook.ClientConfig.Service.Path != nil && *webhook.ClientConfig.Service.Path == "/validate-namespace" {
+			conditions = webhook.MatchConditions
+		}
+	}
+	require.NotEmpty(t, conditions)
+
+	env, err := cel.NewEnv(cel.Variable("object", cel.DynType), cel.Variable("oldObject", cel.DynType))
+	require.NoError(t, err)
+	var programs []cel.Program
+	for _, condition := range conditions {
+		ast, issues := env.Compile(condition.Expression)
+		require.NoError(t, issues.Err(), condition.Name)
+		program, err := env.Program(ast)
+		require.NoError(t, err)
+		programs = append(programs, program)
+	}
+	matches := func(name string, oldMeta, newMeta map[string]interface{}) bool {
+		oldMeta["name"], newMeta["name"] = name, name
+		for _, program := range programs {
+			out, _, err := program.Eval(map[string]interface{}{
+				"object":    map[string]interface{}{"metadata": newMeta},
+				"oldObject": map[string]interface{}{"metadata": oldMeta},
+			})
+			require.NoError(t, err)
+			if out.Value() != true {
+				return false
+			}
+		}
+		return true
+	}
+	labels := func(labels map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"labels": labels}
+	}
+	exempted := func(value string) map[string]interface{} {
+		return map[string]interface{}{"annotations": map[string]interface{}{constants.ExemptedLabelsAnnotation: value}}
+	}
+	include := map[string]interface{}{"policy.rhtas.com/include": "true"}
+
+	require.True(t, matches("team", labels(include), labels(nil)))
+	require.True(t, matches("team", map[string]interface{}{}, labels(include)))
+	require.True(t, matches("team", labels(include), labels(map[string]interface{}{"policy.rhtas.com/include": "false"})))
+	require.True(t, matches("team", exempted(`{"policy.rhtas.com/include":"true"}`), map[string]interface{}{}))
+	require.False(t, matches("team", labels(include), labels(include)))
+	require.False(t, matches("team", map[string]interface{}{}, map[string]interface{}{"annotations": map[string]interface{}{"owner": "a"}}))
+	require.False(t, matches("openshift-monitoring", labels(include), labels(nil)))
+}
@@ -0,0 +1,196 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/webhook"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestPolicyControllerValidatorPodValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		errors []string
+	}{
+		{
+			name: "chart defaults",
+			values: map[string]interface{}{
+				"commonNodeSelector": map[string]interface{}{},
+				"commonTolerations":  []interface{}{},
+				"webhook": map[string]interface{}{
+					"affinity": map[string]interface{}{},
+					"podSecurityContext": map[string]interface{}{
+						"enabled":                  false,
+						"allowPrivilegeEscalation": false,
+						"readOnlyRootFilesystem":   true,
+						"runAsUser":                int64(1000),
+						"capabilities":             map[string]interface{}{"drop": []interface{}{"ALL"}},
+					},
+					"volumeMounts": []interface{}{},
+					"volumes":      []interface{}{},
+					"resources": map[string]interface{}{
+						"limits":   map[string]interface{}{"cpu": "200m", "memory": "512Mi"},
+						"requests": map[string]interface{}{"cpu": "100m", "memory": "128Mi"},
+					},
+				},
+				"leasescleanup": map[string]interface{}{
+					"resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": ""}},
+				},
+			},
+		},
+		{
+			name: "numeric and boolean values",
+			values: map[string]interface{}{
+				"webhook": map[string]interface{}{
+					"env": map[string]interface{}{"GOMAXPROCS": 1, "DEBUG": true},
+					"resources": map[string]interface{}{
+						"limits":   map[string]interface{}{"cpu": 2, "memory": "1Gi"},
+						"requests": map[string]interface{}{"cpu": 0.5},
+					},
+				},
+			},
+		},
+		{
+			name: "unknown and mistyped fields",
+			values: map[string]interface{}{
+				"commonTolerations": []interface{}{map[string]interface{}{"key": "infra", "efect": "NoSchedule"}},
+				"webhook": map[string]interface{}{
+					"affinity": map[string]interface{}{"podAntiAfinity": map[string]interface{}{}},
+					"podSecurityContext": map[string]interface{}{
+						"enabled":   true,
+						"runAsUser": "nonroot",
+					},
+					"volumes":      []interface{}{map[string]interface{}{"name": "ca", "configmap": map[string]interface{}{"name": "ca"}}},
+					"volumeMounts": []interface{}{map[string]interface{}{"name": "ca", "mountpath": "/etc/ca"}},
+				},
+			},
+			errors: []string{
+				`spec.policy-controller.commonTolerations[0].efect: Forbidden: unknown field`,
+				`spec.policy-controller.webhook.affinity.podAntiAfinity: Forbidden: unknown field`,
+				`spec.policy-controller.webhook.podSecurityContext: Invalid value: `,
+				`cannot unmarshal string into Go struct field`,
+				`spec.policy-controller.webhook.volumes[0].configmap: Forbidden: unknown field`,
+				`spec.policy-controller.webhook.volumeMounts[0].mountpath: Forbidden: unknown field`,
+			},
+		},
+		{
+			name: "invalid quantities",
+			values: map[string]interface{}{
+				"webhook": map[string]interface{}{
+					"resources": map[string]interface{}{
+						"limits":   map[string]interface{}{"cpu": "200m", "memory": "512 MB"},
+						"requests": map[string]interface{}{"cpu": "1"},
+					},
+				},
+			},
+			errors: []string{
+				`spec.policy-controller.webhook.resources.limits[memory]: Invalid value: "512 MB"`,
+				`spec.policy-controller.webhook.resources.requests[cpu]: Invalid value: "1": must be less than or equal to the cpu limit 200m`,
+			},
+		},
+	}
+
+	validator := webhook.PolicyControllerValidator{}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			u := GenerateV1alpha1PolicyController(tc.values)
+			raw, err := json.Marshal(u.Object)
+			require.NoError(t, err)
+			pc := &v1alpha1.PolicyController{}
+			require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, pc))
+			ctx := admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Object: runtime.RawExtension{Raw: raw},
+			}})
+
+			_, err = validator.ValidateCreate(ctx, pc)
+			if len(tc.errors) == 0 {
+				require.NoError(t, err)
+			}
+			for _, expected := range tc.errors {
+				require.ErrorContains(t, err, expected)
+			}
+		})
+	}
+}
+
+// TestPolicyControllerScalarValues checks that scalars written the way helm
+// renders them the same, such as quoted numbers, decode into the typed
+// fields, and that values that do not fit are reported without blocking
+// updates that leave them alone.
+func TestPolicyControllerScalarValues(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	decode := func(t *testing.T, spec string) (*v1alpha1.PolicyController, []byte) {
+		data := []byte(`{"apiVersion": "rhtas.charts.redhat.com/v1alpha1", "kind": "PolicyController",
+			"metadata": {"name": "policy-controller", "namespace": "policy-controller-operator"}, "spec": ` + spec + `}`)
+		pc := &v1alpha1.PolicyController{}
+		_, _, err := decoder.Decode(data, nil, pc)
+		require.NoError(t, err)
+		return pc, data
+	}
+
+	pc, _ := decode(t, `{"upgradeMaintenance": {"warn": "true", "replicaCount": "3", "timeoutSeconds": 5.0}, "policy-controller": {
+		"commonNodeSelector": {"gpu": true},
+		"cosign": {"webhookTimeoutSeconds": {"mutating": "10", "validating": 10}},
+		"webhook": {
+			"replicaCount": "2",
+			"automountServiceAccountToken": "true",
+			"image": {"version": 1.10},
+			"env": {"GOMAXPROCS": 2},
+			"podDisruptionBudget": {"enabled": "true", "minAvailable": 1},
+			"serviceAccount": {"create": "false"},
+			"webhookTimeoutSeconds": {"defaulting": "5"}
+		},
+		"leasescleanup": {"automountServiceAccountToken": "false"}
+	}}`)
+	values := pc.Spec.PolicyController
+	require.Equal(t, v1alpha1.UpgradeMaintenanceSpec{Warn: true, ReplicaCount: ptr.To(int32(3)), TimeoutSeconds: ptr.To(int32(5))}, *pc.Spec.UpgradeMaintenance)
+	require.Equal(t, map[string]string{"gpu": "true"}, values.CommonNodeSelector)
+	require.Equal(t, v1alpha1.CosignWebhookTimeoutSeconds{Mutating: ptr.To(int32(10)), Validating: ptr.To(int32(10))}, values.Cosign.WebhookTimeoutSeconds)
+	require.Equal(t, ptr.To(int32(2)), values.Webhook.ReplicaCount)
+	require.Equal(t, ptr.To(true), values.Webhook.AutomountServiceAccountToken)
+	require.Equal(t, "1.10", values.Webhook.Image.Version)
+	require.Equal(t, v1alpha1.EnvValues{"GOMAXPROCS": "2"}, values.Webhook.Env)
+	require.True(t, values.Webhook.PodDisruptionBudget.Enabled)
+	require.Equal(t, ptr.To(false), values.Webhook.ServiceAccount.Create)
+	require.Equal(t, ptr.To(int32(5)), values.Webhook.WebhookTimeoutSeconds.Defaulting)
+	require.Equal(t, ptr.To(false), values.LeasesCleanup.AutomountServiceAccountToken)
+
+	// Values that do not fit are dropped, so that the object still decodes.
+	invalid := `{"policy-controller": {"webhook": {"replicaCount": "two", "env": {"FOO": ["bar"]}, "failurePolicy": "Fail"}}}`
+	oldObj, oldData := decode(t, invalid)
+	require.Nil(t, oldObj.Spec.PolicyController.Webhook.ReplicaCount)
+	require.Empty(t, oldObj.Spec.PolicyController.Webhook.Env)
+
+	validator := webhook.PolicyControllerValidator{}
+	ctx := admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Object: runtime.RawExtension{Raw: oldData},
+	}})
+	_, err := validator.ValidateCreate(ctx, oldObj)
+	require.ErrorContains(t, err, `spec.policy-controller.webhook.replicaCount: Invalid value: "two": must be an integer`)
+	require.ErrorContains(t, err, `spec.policy-controller.webhook.env[FOO]: Invalid value: ["bar"]: must be a string, number or boolean`)
+
+	update := func(t *testing.T, spec string) error {
+		newObj, data := decode(t, spec)
+		ctx := admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Object:    runtime.RawExtension{Raw: data},
+			OldObject: runtime.RawExtension{Raw: oldData},
+		}})
+		_, err := validator.ValidateUpdate(ctx, oldObj, newObj)
+		return err
+	}
+	require.NoError(t, update(t, strings.Replace(invalid, `"Fail"`, `"Ignore"`, 1)), "invalid values left alone")
+	require.NoError(t, update(t, strings.Replace(invalid, `"two"`, `2`, 1)), "invalid value corrected")
+	require.ErrorContains(t, update(t, strings.Replace(invalid, `"two"`, `"three"`, 1)), `spec.policy-controller.webhook.replicaCount: Invalid value: "three"`)
+}
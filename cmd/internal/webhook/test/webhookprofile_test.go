@@ -0,0 +1,98 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/webhook"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestPolicyControllerDefaulter(t *testing.T) {
+	defaulter := webhook.PolicyControllerDefaulter{}
+
+	dev := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+	require.NoError(t, defaulter.Default(context.Background(), dev))
+	require.Equal(t, v1alpha1.ProfileDev, dev.Spec.Profile)
+	require.Nil(t, dev.Spec.PolicyController.Webhook.ReplicaCount)
+	require.False(t, dev.Spec.PolicyController.Webhook.PodDisruptionBudget.Enabled)
+	require.Nil(t, dev.Spec.PolicyController.Webhook.Affinity)
+
+	production := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+	production.Spec.Profile = v1alpha1.ProfileProduction
+	production.Spec.PolicyController.Webhook.ReplicaCount = ptr.To(int32(3))
+	production.Spec.PolicyController.Webhook.Resources.Requests = v1alpha1.ResourceListValues{corev1.ResourceCPU: "500m"}
+	require.NoError(t, defaulter.Default(context.Background(), production))
+	webhookValues := production.Spec.PolicyController.Webhook
+	require.Equal(t, int32(3), *webhookValues.ReplicaCount)
+	require.True(t, webhookValues.PodDisruptionBudget.Enabled)
+	require.Equal(t, 1, webhookValues.PodDisruptionBudget.MinAvailable.IntValue())
+	terms := webhookValues.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+	require.Len(t, terms, 2)
+	require.Equal(t, corev1.LabelHostname, terms[0].PodAffinityTerm.TopologyKey)
+	require.Equal(t, production.Name, terms[0].PodAffinityTerm.LabelSelector.MatchLabels["app.kubernetes.io/instance"])
+	require.Equal(t, "500m", webhookValues.Resources.Requests[corev1.ResourceCPU])
+	require.Equal(t, "128Mi", webhookValues.Resources.Requests[corev1.ResourceMemory])
+
+	_, err := (&webhook.PolicyControllerValidator{}).ValidateCreate(context.Background(), production)
+	require.NoError(t, err)
+}
+
+func TestPolicyControllerDefaulterNumericValues(t *testing.T) {
+	pc := &v1alpha1.PolicyController{}
+	require.NoError(t, json.Unmarshal([]byte(`{"spec": {"profile": "production", "policy-controller": {"webhook": {
+		"env": {"GOMAXPROCS": 2, "DEBUG": true, "EMPTY": null},
+		"resources": {"requests": {"cpu": 1}}
+	}}}}`), pc))
+	webhookValues := &pc.Spec.PolicyController.Webhook
+	require.Equal(t, v1alpha1.EnvValues{"GOMAXPROCS": "2", "DEBUG": "true", "EMPTY": ""}, webhookValues.Env)
+
+	require.NoError(t, (&webhook.PolicyControllerDefaulter{}).Default(context.Background(), pc))
+	require.Equal(t, v1alpha1.ResourceListValues{corev1.ResourceCPU: "1", corev1.ResourceMemory: "128Mi"}, webhookValues.Resources.Requests)
+
+	invalid := &v1alpha1.PolicyController{}
+	require.NoError(t, json.Unmarshal([]byte(`{"spec": {"policy-controller": {"webhook": {"env": {"FOO": ["bar"]}}}}}`), invalid))
+	require.Empty(t, invalid.Spec.PolicyController.Webhook.Env)
+}
+
+func TestPolicyControllerValidatorProfile(t *testing.T) {
+	validator := webhook.PolicyControllerValidator{}
+
+	dev := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+	dev.Spec.Profile = v1alpha1.ProfileDev
+	_, err := validator.ValidateCreate(context.Background(), dev)
+	require.NoError(t, err)
+
+	production := GeneratePolicyControllerObj(constants.PolicyControllerInstallNs)
+	production.Spec.Profile = v1alpha1.ProfileProduction
+	production.Spec.PolicyController.Webhook.ReplicaCount = ptr.To(int32(1))
+	production.Spec.PolicyController.Webhook.Affinity = &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{}}
+	_, err = validator.ValidateCreate(context.Background(), production)
+	require.ErrorContains(t, err, "webhook.replicaCount must be at least 2")
+	require.ErrorContains(t, err, "webhook.podDisruptionBudget must be enabled")
+	require.ErrorContains(t, err, "webhook.affinity must spread the webhook pods")
+	require.ErrorContains(t, err, "webhook.resources.requests.cpu must be set")
+	require.ErrorContains(t, err, "webhook.resources.requests.memory must be set")
+
+	_, err = validator.ValidateUpdate(context.Background(), dev, production)
+	require.ErrorContains(t, err, "webhook.replicaCount must be at least 2")
+
+	// Values that do not change are not checked again.
+	_, err = validator.ValidateUpdate(context.Background(), production, production)
+	require.NoError(t, err)
+	updated := production.DeepCopy()
+	updated.Spec.PolicyController.Webhook.ReplicaCount = ptr.To(int32(0))
+	updated.Spec.PolicyController.Webhook.FailurePolicy = ptr.To(admissionregistrationv1.Ignore)
+	_, err = validator.ValidateUpdate(context.Background(), production, updated)
+	require.ErrorContains(t, err, "webhook.replicaCount must be at least 2")
+	require.NotContains(t, err.Error(), "webhook.podDisruptionBudget")
+	updated.Spec.PolicyController.Webhook.ReplicaCount = production.Spec.PolicyController.Webhook.ReplicaCount
+	_, err = validator.ValidateUpdate(context.Background(), production, updated)
+	require.NoError(t, err)
+}
@@ -0,0 +1,118 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	"github.com/securesign/policy-controller-operator/cmd/internal/webhook"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestPolicyControllerValidatorReferences(t *testing.T) {
+	ns := constants.PolicyControllerInstallNs
+	objects := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "webhook-env"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "registry-ca"}, Data: map[string]string{"ca.crt": "pem"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "webhook-credentials"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "pull-secret"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "webhook"}},
+		&schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "system-cluster-critical"}},
+	).Build()
+
+	tests := []struct {
+		name     string
+		values   map[string]interface{}
+		errors   []string
+		warnings []string
+	}{
+		{
+			name: "existing objects",
+			values: map[string]interface{}{
+				"imagePullSecrets": []interface{}{map[string]interface{}{"name": "pull-secret"}},
+				"webhook": map[string]interface{}{
+					"envFrom": map[string]interface{}{
+						"configmaps": []interface{}{"webhook-env"},
+						"secrets":    []interface{}{"webhook-credentials"},
+					},
+					"registryCaBundle": map[string]interface{}{"name": "registry-ca", "key": "ca.crt"},
+					"priorityClass":    "system-cluster-critical",
+					"serviceAccount":   map[string]interface{}{"create": false, "name": "webhook"},
+				},
+			},
+		},
+		{
+			name: "created service account",
+			values: map[string]interface{}{
+				"webhook": map[string]interface{}{
+					"serviceAccount": map[string]interface{}{"create": true, "name": "policy-controller-webhook"},
+				},
+			},
+		},
+		{
+			name: "missing objects",
+			values: map[string]interface{}{
+				"webhook": map[string]interface{}{
+					"envFrom": map[string]interface{}{
+						"configmaps": []interface{}{"webhook-env", "proxy-env"},
+						"secrets":    []interface{}{"aws-credentials"},
+					},
+					"registryCaBundle": map[string]interface{}{"name": "registry-ca", "key": "ca-bundle.crt"},
+					"priorityClass":    "high-priority",
+					"serviceAccount":   map[string]interface{}{"create": false, "name": "policy-controller"},
+				},
+			},
+			errors: []string{
+				`webhook.envFrom.configmaps[1]: ConfigMap "proxy-env" not found in namespace "policy-controller-operator"`,
+				`webhook.envFrom.secrets[0]: Secret "aws-credentials" not found in namespace "policy-controller-operator"`,
+				`webhook.registryCaBundle: key "ca-bundle.crt" not found in ConfigMap "registry-ca"`,
+				`webhook.priorityClass: PriorityClass "high-priority" not found`,
+				`webhook.serviceAccount.name: ServiceAccount "policy-controller" not found in namespace "policy-controller-operator"`,
+			},
+		},
+		{
+			name: "missing optional objects",
+			values: map[string]interface{}{
+				"imagePullSecrets": []interface{}{map[string]interface{}{"name": "pull-secret"}, map[string]interface{}{"name": "quay-pull-secret"}},
+				"leasescleanup":    map[string]interface{}{"priorityClass": "high-priority"},
+			},
+			warnings: []string{
+				`imagePullSecrets[1]: Secret "quay-pull-secret" not found in namespace "policy-controller-operator"`,
+				`leasescleanup.priorityClass: PriorityClass "high-priority" not found`,
+			},
+		},
+	}
+
+	validator := webhook.PolicyControllerValidator{Cache: objects}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			u := GenerateV1alpha1PolicyController(tc.values)
+			raw, err := json.Marshal(u.Object)
+			require.NoError(t, err)
+			pc := &v1alpha1.PolicyController{}
+			require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, pc))
+			ctx := admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Object: runtime.RawExtension{Raw: raw},
+			}})
+
+			warnings, err := validator.ValidateCreate(ctx, pc)
+			if len(tc.errors) == 0 {
+				require.NoError(t, err)
+			}
+			for _, expected := range tc.errors {
+				require.ErrorContains(t, err, expected)
+			}
+			require.Equal(t, tc.warnings, []string(warnings))
+		})
+	}
+}
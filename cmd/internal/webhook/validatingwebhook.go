@@ -4,39 +4,138 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
 	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
-// +kubebuilder:webhook:path=/validate,mutating=false,failurePolicy=fail,groups=rhtas.charts.redhat.com,resources=policycontrollers,verbs=create,versions=v1alpha1,name=policycontrollers.rhtas.charts.redhat.com
+// +kubebuilder:webhook:path=/validate,mutating=false,failurePolicy=fail,groups=rhtas.charts.redhat.com,resources=policycontrollers,verbs=create;update,versions=v1alpha1,name=policycontrollers.rhtas.charts.redhat.com
 // PolicyControllerValidator validates PolicyControllerResources
-type PolicyControllerValidator struct{}
-
-// validate validates PolicyControllerResources namespace
-func (v *PolicyControllerValidator) validate(ctx context.Context, obj *unstructured.Unstructured) (admission.Warnings, error) {
-	log := logf.FromContext(ctx)
+type PolicyControllerValidator struct {
+	// Client reads the namespaces and workloads affected by an update. Without
+	// it no impact warnings are returned.
+	Client client.Reader
+	// Verifier verifies the signature of the webhook image when the image
+	// reference policy configures a release signer.
+	Verifier policy.Verifier
+	// Cache reads the objects the helm values refer to. Without it the
+	// references are not checked.
+	Cache client.Reader
+}
 
+// validateNamespace validates PolicyControllerResources namespace
+func validateNamespace(ctx context.Context, obj *v1alpha1.PolicyController) error {
 	if ns := obj.GetNamespace(); ns != constants.PolicyControllerInstallNs {
-		err := fmt.Errorf("%s objects may only be created in the %q namespace (got %q)", obj.GetKind(), constants.PolicyControllerInstallNs, ns)
-		log.Info("denying creation: wrong namespace", "namespace", ns)
+		logf.FromContext(ctx).Info("denying creation: wrong namespace", "namespace", ns)
+		return fmt.Errorf("%s objects may only be created in the %q namespace (got %q)", constants.PolicyControllerKind, constants.PolicyControllerInstallNs, ns)
+	}
+	return nil
+}
+
+// validate checks the values of a new object, or the values of an updated
+// object that differ from oldObj.
+func (v *PolicyControllerValidator) validate(ctx context.Context, oldObj, obj *v1alpha1.PolicyController) (admission.Warnings, error) {
+	if err := validateNamespace(ctx, obj); err != nil {
+		return nil, err
+	}
+	if err := validateSpecValues(ctx); err != nil {
 		return nil, err
 	}
+	if err := validateProfile(oldObj, obj); err != nil {
+		return nil, err
+	}
+	if err := validateExtraArgs(oldObj, obj); err != nil {
+		return nil, err
+	}
+	return nil, validatePodValues(ctx, oldObj, obj)
+}
 
-	return nil, nil
+func (v *PolicyControllerValidator) ValidateCreate(ctx context.Context, obj *v1alpha1.PolicyController) (admission.Warnings, error) {
+	warnings, err := v.validate(ctx, nil, obj)
+	if err != nil {
+		return warnings, err
+	}
+	breakGlass, err := validateBreakGlass(ctx, nil, obj)
+	if err != nil {
+		return warnings, err
+	}
+	warnings = append(warnings, breakGlass...)
+	images, err := v.imageReferenceWarnings(ctx, nil, obj)
+	if err != nil {
+		return warnings, err
+	}
+	warnings = append(warnings, images...)
+	cosignPub, err := cosignPubWarnings(nil, obj)
+	if err != nil {
+		return warnings, err
+	}
+	warnings = append(warnings, cosignPub...)
+	references, err := v.referenceWarnings(ctx, nil, obj)
+	return append(warnings, references...), err
 }
 
-func (v *PolicyControllerValidator) ValidateCreate(ctx context.Context, obj *unstructured.Unstructured) (admission.Warnings, error) {
-	return v.validate(ctx, obj)
+// ValidateUpdate only denies values that the update changes, so that values
+// accepted before, or objects they refer to that have been deleted since, do
+// not block later updates. Updates of a PolicyController that is being
+// deleted, such as the removal of its finalizers, and updates that leave the
+// spec alone are not checked again.
+func (v *PolicyControllerValidator) ValidateUpdate(ctx context.Context, oldObj, newObj *v1alpha1.PolicyController) (admission.Warnings, error) {
+	if !newObj.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	if err := validateNamespace(ctx, newObj); err != nil {
+		return nil, err
+	}
+	if specUnchanged(ctx, oldObj, newObj) {
+		return validateBreakGlass(ctx, oldObj, newObj)
+	}
+
+	warnings, err := v.validate(ctx, oldObj, newObj)
+	if err != nil {
+		return warnings, err
+	}
+	breakGlass, err := validateBreakGlass(ctx, oldObj, newObj)
+	if err != nil {
+		return warnings, err
+	}
+	warnings = append(warnings, breakGlass...)
+	images, err := v.imageReferenceWarnings(ctx, oldObj, newObj)
+	if err != nil {
+		return warnings, err
+	}
+	warnings = append(warnings, images...)
+	cosignPub, err := cosignPubWarnings(oldObj, newObj)
+	if err != nil {
+		return warnings, err
+	}
+	warnings = append(warnings, cosignPub...)
+	references, err := v.referenceWarnings(ctx, oldObj, newObj)
+	if err != nil {
+		return warnings, err
+	}
+	warnings = append(warnings, references...)
+	return append(warnings, v.namespaceSelectorWarnings(ctx, oldObj, newObj)...), nil
 }
 
-func (v *PolicyControllerValidator) ValidateUpdate(ctx context.Context, oldObj, newObj *unstructured.Unstructured) (admission.Warnings, error) {
-	return v.validate(ctx, newObj)
+// specUnchanged reports whether the update leaves the spec, including the
+// helm values the PolicyController type does not know, alone.
+func specUnchanged(ctx context.Context, oldObj, newObj *v1alpha1.PolicyController) bool {
+	if !equality.Semantic.DeepEqual(oldObj.Spec, newObj.Spec) {
+		return false
+	}
+	oldValues, err := rawOldValues(ctx, oldObj)
+	if err != nil {
+		return false
+	}
+	values, err := rawValues(ctx, newObj)
+	return err == nil && jsonEqual(oldValues, values)
 }
 
-func (v *PolicyControllerValidator) ValidateDelete(ctx context.Context, obj *unstructured.Unstructured) (admission.Warnings, error) {
+func (v *PolicyControllerValidator) ValidateDelete(ctx context.Context, obj *v1alpha1.PolicyController) (admission.Warnings, error) {
 	// Allow all delete operations
 	return nil, nil
 }
@@ -1,20 +1,31 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"time"
 
+	"github.com/securesign/policy-controller-operator/api/v1alpha1"
+	"github.com/securesign/policy-controller-operator/api/v1beta1"
+	"github.com/securesign/policy-controller-operator/cmd/internal/cli"
 	"github.com/securesign/policy-controller-operator/cmd/internal/constants"
+	rhtas_controller "github.com/securesign/policy-controller-operator/cmd/internal/controller"
+	"github.com/securesign/policy-controller-operator/cmd/internal/policy"
 	rhtas_webhook "github.com/securesign/policy-controller-operator/cmd/internal/webhook"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
@@ -23,9 +34,29 @@ func init() {
 }
 
 func main() {
+	// Subcommands run instead of the manager.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "explain":
+			os.Exit(cli.Explain(context.Background(), os.Args[2:], os.Stdout, os.Stderr))
+		case "policy":
+			os.Exit(cli.Policy(context.Background(), os.Args[2:], os.Stdout, os.Stderr))
+		case "break-glass":
+			c, err := cli.NewClient()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(cli.ExitError)
+			}
+			os.Exit(cli.BreakGlass(context.Background(), c, os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	var (
-		certDir = flag.String("cert-dir", "/tmp/k8s-webhook-server/serving-certs", "CertDir is the directory that contains the server key and certificate. Defaults to <temp-dir>/k8s-webhook-server/serving-certs.")
-		port    = flag.Int("port", 9443, "Port is the port number that the server will serve. It will be defaulted to 9443 if unspecified.")
+		certDir       = flag.String("cert-dir", "/tmp/k8s-webhook-server/serving-certs", "CertDir is the directory that contains the server key and certificate. Defaults to <temp-dir>/k8s-webhook-server/serving-certs.")
+		port          = flag.Int("port", 9443, "Port is the port number that the server will serve. It will be defaulted to 9443 if unspecified.")
+		leaderElect   = flag.Bool("leader-elect", false, "Enable leader election for the controllers. Webhooks are served by every replica.")
+		auditInterval = flag.Duration("audit-interval", time.Hour, "Interval between two audits of the running workloads against the installed policies. 0 disables the audit.")
+		metricsAddr   = flag.String("metrics-bind-address", ":8080", "The address the metrics endpoint binds to. 0 disables it.")
 	)
 	flag.Parse()
 
@@ -33,7 +64,22 @@ func main() {
 
 	// Setup a Manager
 	entryLog.Info("setting up manager")
+	// The chart resources live in the install namespace, where the operator
+	// has namespaced RBAC only.
+	installNs := map[string]cache.Config{constants.PolicyControllerInstallNs: {}}
 	mgr, err := manager.New(config.GetConfigOrDie(), manager.Options{
+		LeaderElection:   *leaderElect,
+		LeaderElectionID: constants.WebhookControllerLeaderElectionID,
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				&v1alpha1.PolicyController{}: {Namespaces: installNs},
+				&appsv1.Deployment{}:         {Namespaces: installNs},
+				&corev1.ConfigMap{}:          {Namespaces: installNs},
+				&corev1.Secret{}:             {Namespaces: installNs},
+				&corev1.ServiceAccount{}:     {Namespaces: installNs},
+			},
+		},
+		Metrics: metricsserver.Options{BindAddress: *metricsAddr},
 		WebhookServer: webhook.NewServer(webhook.Options{
 			Port:    *port,
 			CertDir: *certDir,
@@ -44,22 +90,107 @@ func main() {
 		os.Exit(1)
 	}
 
-	policyControllerGVK := schema.GroupVersionKind{
-		Group:   constants.PolicyControllerGroup,
-		Version: constants.PolicyControllerVersion,
-		Kind:    constants.PolicyControllerKind,
+	if err := v1alpha1.AddToScheme(mgr.GetScheme()); err != nil {
+		entryLog.Error(err, "unable to add PolicyController types to scheme")
+		os.Exit(1)
+	}
+	if err := v1beta1.AddToScheme(mgr.GetScheme()); err != nil {
+		entryLog.Error(err, "unable to add PolicyController types to scheme")
+		os.Exit(1)
 	}
-	mgr.GetScheme().AddKnownTypeWithName(policyControllerGVK, &unstructured.Unstructured{})
 
-	policyController := &unstructured.Unstructured{}
-	policyController.SetGroupVersionKind(policyControllerGVK)
-	if err := builder.WebhookManagedBy(mgr, policyController).
-		WithValidator(&rhtas_webhook.PolicyControllerValidator{}).
+	if err := builder.WebhookManagedBy(mgr, &v1alpha1.PolicyController{}).
+		WithValidator(&rhtas_webhook.PolicyControllerValidator{
+			Client:   mgr.GetAPIReader(),
+			Verifier: &policy.RegistryVerifier{},
+			Cache:    mgr.GetCache(),
+		}).
 		WithValidatorCustomPath("/validate").
+		WithDefaulter(&rhtas_webhook.PolicyControllerDefaulter{}).
+		WithDefaulterCustomPath("/mutate").
 		Complete(); err != nil {
 		entryLog.Error(err, "unable to create webhook for PolicyController")
 		os.Exit(1)
 	}
+	if err := builder.WebhookManagedBy(mgr, &corev1.Namespace{}).
+		WithValidator(&rhtas_webhook.NamespaceLabelValidator{Client: mgr.GetClient()}).
+		WithValidatorCustomPath("/validate-namespace").
+		Complete(); err != nil {
+		entryLog.Error(err, "unable to create webhook for Namespace")
+		os.Exit(1)
+	}
+	if err := builder.WebhookManagedBy(mgr, &v1alpha1.ImagePolicyException{}).
+		WithValidator(&rhtas_webhook.ImagePolicyExceptionValidator{Client: mgr.GetClient()}).
+		WithValidatorCustomPath("/validate-imagepolicyexception").
+		Complete(); err != nil {
+		entryLog.Error(err, "unable to create webhook for ImagePolicyException")
+		os.Exit(1)
+	}
+	mgr.GetWebhookServer().Register("/convert", &rhtas_webhook.PolicyControllerConverter{})
+	mgr.GetWebhookServer().Register(rhtas_webhook.ClusterImagePolicyPath, &webhook.Admission{
+		Handler: &rhtas_webhook.ClusterImagePolicyValidator{Cache: mgr.GetCache()},
+	})
+	mgr.GetWebhookServer().Register(rhtas_webhook.DryRunPath, &rhtas_webhook.DryRunHandler{
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+	})
+
+	if err := (&rhtas_controller.StatusReconciler{Client: mgr.GetClient()}).SetupWithManager(mgr); err != nil {
+		entryLog.Error(err, "unable to create status controller for PolicyController")
+		os.Exit(1)
+	}
+	if err := (&rhtas_controller.WebhookDriftReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorder("policycontroller-webhookdrift"),
+	}).SetupWithManager(mgr); err != nil {
+		entryLog.Error(err, "unable to create webhook drift controller for PolicyController")
+		os.Exit(1)
+	}
+	if err := (&rhtas_controller.BreakGlassReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorder("policycontroller-breakglass"),
+	}).SetupWithManager(mgr); err != nil {
+		entryLog.Error(err, "unable to create break-glass controller for PolicyController")
+		os.Exit(1)
+	}
+	if err := (&rhtas_controller.CircuitBreakerReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorder("policycontroller-circuitbreaker"),
+	}).SetupWithManager(mgr); err != nil {
+		entryLog.Error(err, "unable to create circuit breaker controller for PolicyController")
+		os.Exit(1)
+	}
+	if err := (&rhtas_controller.UpgradeMaintenanceReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorder("policycontroller-upgrademaintenance"),
+	}).SetupWithManager(mgr); err != nil {
+		entryLog.Error(err, "unable to create upgrade maintenance controller for PolicyController")
+		os.Exit(1)
+	}
+	if err := (&rhtas_controller.CleanupReconciler{
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+	}).SetupWithManager(mgr); err != nil {
+		entryLog.Error(err, "unable to create cleanup controller for PolicyController")
+		os.Exit(1)
+	}
+	if err := (&rhtas_controller.ExceptionReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorder("imagepolicyexception"),
+	}).SetupWithManager(mgr); err != nil {
+		entryLog.Error(err, "unable to create controller for ImagePolicyException")
+		os.Exit(1)
+	}
+	if *auditInterval > 0 {
+		if err := (&rhtas_controller.AuditReconciler{
+			Client:    mgr.GetClient(),
+			APIReader: mgr.GetAPIReader(),
+			Interval:  *auditInterval,
+		}).SetupWithManager(mgr); err != nil {
+			entryLog.Error(err, "unable to create audit controller for PolicyController")
+			os.Exit(1)
+		}
+	}
 
 	entryLog.Info("starting manager")
 	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
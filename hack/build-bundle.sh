@@ -42,7 +42,7 @@ fi
 # Add related images
 FILE="helm-charts/policy-controller-operator/values.yaml"
 RELATED_IMAGE_POLICY_CONTROLLER_DIGEST="$(
-  sed -n '/^[[:space:]]*webhook:/,/^[[:space:]]*leasescleanup:/{
+  sed -n '/^[[:space:]]*webhook:/,/^[[:space:]]*commonNodeSelector:/{
     /^[[:space:]]*version:/{
       s/^[[:space:]]*version:[[:space:]]*//
       p
@@ -51,17 +51,7 @@ RELATED_IMAGE_POLICY_CONTROLLER_DIGEST="$(
   }' "$FILE"
 )"
 
-RELATED_IMAGE_OSE_CLI_DIGEST="$(
-  sed -n '/^[[:space:]]*leasescleanup:/,/^[[:space:]]*commonNodeSelector:/{
-    /^[[:space:]]*version:/{
-      s/^[[:space:]]*version:[[:space:]]*//
-      p
-      q
-    }
-  }' "$FILE"
-)"
 echo "RELATED_IMAGE_POLICY_CONTROLLER=registry.redhat.io/rhtas/policy-controller-rhel9@${RELATED_IMAGE_POLICY_CONTROLLER_DIGEST}" > config/manager/images.env
-echo "RELATED_IMAGE_OSE_CLI=registry.redhat.io/openshift4/ose-cli-rhel9@sha256:${RELATED_IMAGE_OSE_CLI_DIGEST}" >> config/manager/images.env
 
 # Generate and validate the Operator bundle
 oc kustomize config/manifests | operator-sdk generate bundle ${BUNDLE_GEN_FLAGS} && operator-sdk bundle validate ./bundle